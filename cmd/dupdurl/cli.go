@@ -93,13 +93,13 @@ func ParseFlags() *CLIConfig {
 // Validate checks if the configuration is valid
 func (c *CLIConfig) Validate() error {
 	// Validate mode
-	validModes := []string{"url", "path", "host", "raw", "params"}
+	validModes := []string{"url", "path", "host", "raw", "params", "presence", "hash"}
 	if !contains(validModes, c.Mode) {
 		return fmt.Errorf("invalid mode: %s (valid: %s)", c.Mode, strings.Join(validModes, ", "))
 	}
 
 	// Validate output format
-	validFormats := []string{"text", "json", "csv"}
+	validFormats := []string{"text", "json", "csv", "ndjson", "html"}
 	if !contains(validFormats, c.OutputFormat) {
 		return fmt.Errorf("invalid output format: %s (valid: %s)", c.OutputFormat, strings.Join(validFormats, ", "))
 	}
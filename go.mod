@@ -2,6 +2,8 @@ module github.com/lcalzada-xor/dupdurl
 
 go 1.24.9
 
-require github.com/mattn/go-sqlite3 v1.14.32
-
-require gopkg.in/yaml.v3 v3.0.1 // indirect
+require (
+	github.com/mattn/go-sqlite3 v1.14.32
+	golang.org/x/text v0.30.0
+	gopkg.in/yaml.v3 v3.0.1
+)
@@ -17,176 +17,384 @@
 package main
 
 import (
+	"context"
+	"encoding/json"
+	"errors"
 	"flag"
 	"fmt"
-	"net/url"
+	"io"
 	"os"
+	"os/signal"
+	"regexp"
 	"runtime"
 	"strings"
+	"syscall"
 	"time"
 
 	"github.com/lcalzada-xor/dupdurl/pkg/config"
 	"github.com/lcalzada-xor/dupdurl/pkg/deduplicator"
 	"github.com/lcalzada-xor/dupdurl/pkg/diff"
+	"github.com/lcalzada-xor/dupdurl/pkg/locale"
 	"github.com/lcalzada-xor/dupdurl/pkg/normalizer"
 	"github.com/lcalzada-xor/dupdurl/pkg/output"
 	"github.com/lcalzada-xor/dupdurl/pkg/processor"
 	"github.com/lcalzada-xor/dupdurl/pkg/scope"
+	"github.com/lcalzada-xor/dupdurl/pkg/stats"
+	"github.com/lcalzada-xor/dupdurl/pkg/storage"
 )
 
+// templateExamples is how many concrete URLs --group-output-by-template
+// lists under each template
+const templateExamples = 5
+
 // CLIConfig holds all command-line flags
 type CLIConfig struct {
 	// Core options
 	Mode             string
+	Inputs           listFlag
+	Gzip             bool
 	IgnoreParams     string
+	IgnoreState      bool
+	StateParams      string
+	SemicolonQuery   bool
 	SortParams       bool
+	DedupValues      bool
+	KeepValues       bool
+	IgnoreValueCase  bool
+	KeepQueryOrder   bool
+	StripOrderNoise  bool
 	IgnoreFragment   bool
+	KeepFragment     bool
 	CaseSensitive    bool
 	KeepWWW          bool
 	KeepScheme       bool
+	KeepFQDNDot      bool
+	WWWApexOnly      bool
+	CrossScheme      bool
+	RegDomainPath    bool
 	TrimSpaces       bool
 
 	// Output options
 	PrintCounts      bool
 	OutputFormat     string
+	Sort             string
 	ShowStats        bool
 	ShowStatsDetailed bool
+	ShowStatsOneLine  bool
+	StatsTemplate     string
+	StatsJSON         string
 	Verbose          bool
+	Explain          string
+	Fingerprint      bool
+	CanonicalOutput  bool
+	GroupByTemplate  bool
+	SummarizeDomains string
+	CountsFile       string
+	OutputFile       string
+	OutputAppend     bool
+	CSVColumns       string
+	Keep             string
+	LocaleCoverage   string
+	LocaleShortest   bool
+	ReportDuplicates bool
 
 	// Advanced normalization
 	FuzzyMode        bool
 	FuzzyPatterns    string
+	FuzzyCustom      listFlag
 	PathIncludeQuery bool
+	PathNoHost       bool
+	DropEmptyQuery   bool
+	MaxHostsPerPath  int
 	IgnoreExtensions string
 	FilterExtensions string
+	FuzzyHostNumbers bool
+	CollapseIDRuns   bool
+	StructuralPath   bool
+	CollapseAMP      bool
+	KeyRegex         string
+	KeyTemplate      string
+	RegexTimeout     string
+	LocaleScanAll    bool
+	LocaleAliases    string
+	LocaleParamL     bool
+	LocaleParams     string
+	LocalePriority   string
+	LocaleDedup      bool
 
 	// Filtering
 	AllowDomains     string
 	BlockDomains     string
+	MaxPathSegments  int
+	MinPathSegments  int
+	ExcludeStatus    string
+	KeepStatus       bool
+	KeepMethod       bool
+	SchemeBreakdown  bool
+	DistinctVariants bool
 
 	// Performance
 	Workers          int
 	BatchSize        int
+	MaxUnique        int
+	MaxPerHost       int
+	InputLimitBytes  int64
+	NearDedup        bool
+	NearDistance     int
+	NearMax          int
 
 	// Storage
 	StorageBackend   string
 	DBPath           string
+	SQLiteJournal    string
+	SQLiteSync       string
+	SQLiteCacheSize  int
+	ExportSQLite     string
+	ImportBaseline   string
 
 	// Config file
 	ConfigFile string
+	Profile    string
 	SaveConfig string
 
 	// Diff mode
-	DiffBaseline string
-	SaveBaseline string
+	DiffBaseline     string
+	DiffIgnoreCounts bool
+	DiffSummaryOnly  bool
+	OnlyNewAcross    string
+	SaveBaseline     string
+	WebhookJSON      string
 
 	// Streaming mode
 	Streaming              bool
 	StreamingFlushInterval string
 	StreamingMaxBuffer     int
+	StreamingFlushMode     string
+	StreamingGlobalDedup   bool
 
 	// Scope checking
 	ScopeFile      string
 	OutOfScope     bool
 	ScopeStats     bool
+	ScopeHost      string
+
+	// setFlags holds the names of flags set explicitly on the command line
+	setFlags map[string]bool
 }
 
 // ParseFlags parses command-line flags and returns configuration
 func ParseFlags() *CLIConfig {
-	config := &CLIConfig{}
-
 	// Override default Usage to show custom help
 	flag.Usage = printUsage
 
-	// === CORE NORMALIZATION OPTIONS ===
-	flag.StringVar(&config.Mode, "mode", "url", "")
-	flag.StringVar(&config.Mode, "m", "url", "")
-
-	flag.BoolVar(&config.FuzzyMode, "fuzzy", false, "")
-	flag.BoolVar(&config.FuzzyMode, "f", false, "")
+	// The default flag set exits on parse errors
+	config, _ := parseArgs(flag.CommandLine, os.Args[1:])
+	return config
+}
 
-	flag.StringVar(&config.FuzzyPatterns, "fuzzy-patterns", "numeric", "")
-	flag.StringVar(&config.FuzzyPatterns, "fp", "numeric", "")
+// parseArgs registers every option on fs and parses args into a new
+// configuration, recording which flags were set explicitly
+func parseArgs(fs *flag.FlagSet, args []string) (*CLIConfig, error) {
+	config := &CLIConfig{}
 
-	flag.BoolVar(&config.IgnoreFragment, "ignore-fragment", true, "")
-	flag.BoolVar(&config.CaseSensitive, "case-sensitive", false, "")
-	flag.BoolVar(&config.KeepWWW, "keep-www", false, "")
-	flag.BoolVar(&config.KeepScheme, "keep-scheme", false, "")
-	flag.BoolVar(&config.TrimSpaces, "trim", true, "")
-	flag.BoolVar(&config.TrimSpaces, "t", true, "")
+	// === CORE NORMALIZATION OPTIONS ===
+	fs.StringVar(&config.Mode, "mode", "url", "")
+	fs.StringVar(&config.Mode, "m", "url", "")
+
+	fs.Var(&config.Inputs, "input", "")
+	fs.Var(&config.Inputs, "i", "")
+	fs.BoolVar(&config.Gzip, "gzip", false, "")
+
+	fs.BoolVar(&config.FuzzyMode, "fuzzy", false, "")
+	fs.BoolVar(&config.FuzzyMode, "f", false, "")
+
+	fs.StringVar(&config.FuzzyPatterns, "fuzzy-patterns", "numeric", "")
+	fs.StringVar(&config.FuzzyPatterns, "fp", "numeric", "")
+	fs.Var(&config.FuzzyCustom, "fuzzy-custom", "")
+
+	fs.BoolVar(&config.FuzzyHostNumbers, "dedup-ignore-trailing-numbers-in-host", false, "")
+	fs.BoolVar(&config.CollapseIDRuns, "collapse-id-runs", false, "")
+	fs.BoolVar(&config.StructuralPath, "dedup-by-structural-path", false, "")
+
+	fs.BoolVar(&config.IgnoreFragment, "ignore-fragment", true, "")
+	fs.BoolVar(&config.KeepFragment, "keep-fragment", false, "")
+	fs.BoolVar(&config.CaseSensitive, "case-sensitive", false, "")
+	fs.BoolVar(&config.KeepWWW, "keep-www", false, "")
+	fs.BoolVar(&config.KeepScheme, "keep-scheme", false, "")
+	fs.BoolVar(&config.KeepFQDNDot, "keep-fqdn-dot", false, "")
+	fs.BoolVar(&config.WWWApexOnly, "www-apex-only", false, "")
+	fs.BoolVar(&config.CrossScheme, "dedup-cross-scheme-and-trailing-slash", false, "")
+	fs.BoolVar(&config.RegDomainPath, "dedup-by-registered-domain-and-path", false, "")
+	fs.BoolVar(&config.TrimSpaces, "trim", true, "")
+	fs.BoolVar(&config.TrimSpaces, "t", true, "")
 
 	// === PARAMETER & QUERY HANDLING ===
-	flag.StringVar(&config.IgnoreParams, "ignore-params", "", "")
-	flag.StringVar(&config.IgnoreParams, "ip", "", "")
-
-	flag.BoolVar(&config.SortParams, "sort-params", false, "")
-	flag.BoolVar(&config.SortParams, "sp", false, "")
-
-	flag.BoolVar(&config.PathIncludeQuery, "path-include-query", false, "")
+	fs.StringVar(&config.IgnoreParams, "ignore-params", "", "")
+	fs.StringVar(&config.IgnoreParams, "ip", "", "")
+
+	fs.BoolVar(&config.IgnoreState, "ignore-state-params", false, "")
+	fs.StringVar(&config.StateParams, "state-params", normalizer.DefaultStateParams, "")
+	fs.BoolVar(&config.SemicolonQuery, "semicolon-query", false, "")
+
+	fs.BoolVar(&config.SortParams, "sort-params", false, "")
+	fs.BoolVar(&config.SortParams, "sp", false, "")
+
+	fs.BoolVar(&config.DedupValues, "dedup-param-values", false, "")
+	fs.BoolVar(&config.KeepValues, "keep-param-values", false, "")
+	fs.BoolVar(&config.IgnoreValueCase, "dedup-ignore-case-in-query-values", false, "")
+	fs.BoolVar(&config.KeepQueryOrder, "dedup-ignore-query-order-only", false, "")
+	fs.BoolVar(&config.StripOrderNoise, "strip-query-fragment-order-noise", false, "")
+
+	fs.BoolVar(&config.PathIncludeQuery, "path-include-query", false, "")
+
+	fs.StringVar(&config.KeyRegex, "key-regex", "", "")
+	fs.StringVar(&config.KeyTemplate, "key-template", "", "")
+	fs.StringVar(&config.RegexTimeout, "regex-timeout", "", "")
+	fs.BoolVar(&config.DropEmptyQuery, "collapse-empty-query", false, "")
+	fs.BoolVar(&config.PathNoHost, "path-no-host", false, "")
+	fs.IntVar(&config.MaxHostsPerPath, "max-hosts-per-path", 0, "")
+	fs.BoolVar(&config.LocaleScanAll, "locale-scan-all-segments", false, "")
+	fs.StringVar(&config.LocaleAliases, "locale-aliases", "", "")
+	fs.BoolVar(&config.LocaleParamL, "locale-param-l", false, "")
+	fs.StringVar(&config.LocaleParams, "locale-params", "", "")
+	fs.StringVar(&config.LocalePriority, "locale-priority", "en", "")
+	fs.BoolVar(&config.LocaleDedup, "locale-dedup", false, "")
+	fs.BoolVar(&config.CollapseAMP, "collapse-amp", false, "")
 
 	// === FILTERING OPTIONS ===
-	flag.StringVar(&config.IgnoreExtensions, "ignore-extensions", "", "")
-	flag.StringVar(&config.IgnoreExtensions, "ie", "", "")
+	fs.StringVar(&config.IgnoreExtensions, "ignore-extensions", "", "")
+	fs.StringVar(&config.IgnoreExtensions, "ie", "", "")
 
-	flag.StringVar(&config.FilterExtensions, "filter-extensions", "", "")
-	flag.StringVar(&config.FilterExtensions, "fe", "", "")
+	fs.StringVar(&config.FilterExtensions, "filter-extensions", "", "")
+	fs.StringVar(&config.FilterExtensions, "fe", "", "")
 
-	flag.StringVar(&config.AllowDomains, "allow-domains", "", "")
-	flag.StringVar(&config.AllowDomains, "ad", "", "")
+	fs.StringVar(&config.AllowDomains, "allow-domains", "", "")
+	fs.StringVar(&config.AllowDomains, "ad", "", "")
 
-	flag.StringVar(&config.BlockDomains, "block-domains", "", "")
-	flag.StringVar(&config.BlockDomains, "bd", "", "")
+	fs.StringVar(&config.BlockDomains, "block-domains", "", "")
+	fs.StringVar(&config.BlockDomains, "bd", "", "")
 
-	// === OUTPUT OPTIONS ===
-	flag.StringVar(&config.OutputFormat, "output", "text", "")
-	flag.StringVar(&config.OutputFormat, "o", "text", "")
+	fs.IntVar(&config.MaxPathSegments, "max-path-segments", 0, "")
+	fs.IntVar(&config.MinPathSegments, "min-path-segments", 0, "")
+	fs.StringVar(&config.ExcludeStatus, "exclude-status", "", "")
+	fs.BoolVar(&config.KeepStatus, "keep-status", false, "")
+	fs.BoolVar(&config.KeepMethod, "keep-method", false, "")
+	fs.BoolVar(&config.SchemeBreakdown, "scheme-breakdown", false, "")
+	fs.BoolVar(&config.DistinctVariants, "distinct-variants", false, "")
 
-	flag.BoolVar(&config.PrintCounts, "counts", false, "")
-	flag.BoolVar(&config.PrintCounts, "c", false, "")
-
-	flag.BoolVar(&config.ShowStats, "stats", false, "")
-	flag.BoolVar(&config.ShowStats, "s", false, "")
-
-	flag.BoolVar(&config.ShowStatsDetailed, "stats-detailed", false, "")
-	flag.BoolVar(&config.ShowStatsDetailed, "sd", false, "")
-
-	flag.BoolVar(&config.Verbose, "verbose", false, "")
-	flag.BoolVar(&config.Verbose, "v", false, "")
+	// === OUTPUT OPTIONS ===
+	fs.StringVar(&config.OutputFormat, "output", "text", "")
+	fs.StringVar(&config.OutputFormat, "o", "text", "")
+	fs.StringVar(&config.Sort, "sort", "", "")
+
+	fs.BoolVar(&config.PrintCounts, "counts", false, "")
+	fs.BoolVar(&config.PrintCounts, "c", false, "")
+
+	fs.BoolVar(&config.ShowStats, "stats", false, "")
+	fs.BoolVar(&config.ShowStats, "s", false, "")
+
+	fs.BoolVar(&config.ShowStatsDetailed, "stats-detailed", false, "")
+	fs.BoolVar(&config.ShowStatsDetailed, "sd", false, "")
+
+	fs.BoolVar(&config.ShowStatsOneLine, "stats-oneline", false, "")
+	fs.StringVar(&config.StatsTemplate, "stats-template", "", "")
+	fs.StringVar(&config.StatsJSON, "stats-json", "", "")
+
+	fs.BoolVar(&config.Verbose, "verbose", false, "")
+	fs.BoolVar(&config.Verbose, "v", false, "")
+	fs.StringVar(&config.Explain, "explain", "", "")
+
+	fs.BoolVar(&config.Fingerprint, "fingerprint", false, "")
+	fs.BoolVar(&config.CanonicalOutput, "canonical-output", false, "")
+	fs.BoolVar(&config.GroupByTemplate, "group-output-by-template", false, "")
+	fs.StringVar(&config.SummarizeDomains, "summarize-domains", "", "")
+	fs.StringVar(&config.CountsFile, "counts-file", "", "")
+	fs.StringVar(&config.OutputFile, "output-file", "", "")
+	fs.BoolVar(&config.OutputAppend, "output-append", false, "")
+	fs.StringVar(&config.CSVColumns, "csv-columns", "", "")
+	fs.StringVar(&config.Keep, "keep", "first", "")
+	fs.StringVar(&config.LocaleCoverage, "locale-coverage", "", "")
+	fs.BoolVar(&config.LocaleShortest, "locale-prefer-shortest-path", false, "")
+	fs.BoolVar(&config.ReportDuplicates, "dedup-report-duplicates", false, "")
 
 	// === PERFORMANCE OPTIONS ===
-	flag.IntVar(&config.Workers, "workers", 1, "")
-	flag.IntVar(&config.Workers, "w", 1, "")
+	fs.IntVar(&config.Workers, "workers", 1, "")
+	fs.IntVar(&config.Workers, "w", 1, "")
 
-	flag.IntVar(&config.BatchSize, "batch-size", 1000, "")
+	fs.IntVar(&config.BatchSize, "batch-size", 1000, "")
+	fs.IntVar(&config.MaxUnique, "max-unique", 0, "")
+	fs.IntVar(&config.MaxPerHost, "max-per-host", 0, "")
+	fs.Int64Var(&config.InputLimitBytes, "input-limit-bytes", 0, "")
+	fs.BoolVar(&config.NearDedup, "near-dedup", false, "")
+	fs.IntVar(&config.NearDistance, "near-dedup-distance", 2, "")
+	fs.IntVar(&config.NearMax, "near-dedup-max", 5000, "")
 
 	// === STREAMING MODE ===
-	flag.BoolVar(&config.Streaming, "stream", false, "")
-	flag.StringVar(&config.StreamingFlushInterval, "stream-interval", "5s", "")
-	flag.IntVar(&config.StreamingMaxBuffer, "stream-buffer", 10000, "")
+	fs.BoolVar(&config.Streaming, "stream", false, "")
+	fs.StringVar(&config.StreamingFlushInterval, "stream-interval", "5s", "")
+	fs.IntVar(&config.StreamingMaxBuffer, "stream-buffer", 10000, "")
+	fs.StringVar(&config.StreamingFlushMode, "stream-flush-mode", processor.FlushBoth, "")
+	fs.BoolVar(&config.StreamingGlobalDedup, "stream-global-dedup", false, "")
 
 	// === DIFF MODE ===
-	flag.StringVar(&config.DiffBaseline, "diff", "", "")
-	flag.StringVar(&config.DiffBaseline, "d", "", "")
+	fs.StringVar(&config.DiffBaseline, "diff", "", "")
+	fs.StringVar(&config.DiffBaseline, "d", "", "")
+	fs.BoolVar(&config.DiffIgnoreCounts, "diff-ignore-counts", false, "")
+	fs.BoolVar(&config.DiffSummaryOnly, "diff-summary-only", false, "")
+	fs.StringVar(&config.OnlyNewAcross, "only-new-across", "", "")
+
+	fs.StringVar(&config.SaveBaseline, "save-baseline", "", "")
+	fs.StringVar(&config.SaveBaseline, "sb", "", "")
 
-	flag.StringVar(&config.SaveBaseline, "save-baseline", "", "")
-	flag.StringVar(&config.SaveBaseline, "sb", "", "")
+	fs.StringVar(&config.WebhookJSON, "webhook-json", "", "")
 
 	// === CONFIG FILE ===
-	flag.StringVar(&config.ConfigFile, "config", "", "")
-	flag.StringVar(&config.SaveConfig, "save-config", "", "")
+	fs.StringVar(&config.ConfigFile, "config", "", "")
+	fs.StringVar(&config.Profile, "profile", "", "")
+	fs.StringVar(&config.Profile, "p", "", "")
+	fs.StringVar(&config.SaveConfig, "save-config", "", "")
 
 	// === STORAGE OPTIONS ===
-	flag.StringVar(&config.StorageBackend, "storage", "memory", "")
-	flag.StringVar(&config.DBPath, "db-path", ":memory:", "")
+	fs.StringVar(&config.StorageBackend, "storage", "memory", "")
+	fs.StringVar(&config.DBPath, "db-path", ":memory:", "")
+	fs.StringVar(&config.SQLiteJournal, "sqlite-journal-mode", "wal", "")
+	fs.StringVar(&config.SQLiteSync, "sqlite-synchronous", "", "")
+	fs.IntVar(&config.SQLiteCacheSize, "sqlite-cache-size", 0, "")
+	fs.StringVar(&config.ExportSQLite, "export-sqlite", "", "")
+	fs.StringVar(&config.ImportBaseline, "import-baseline-into-storage", "", "")
 
 	// === SCOPE CHECKING ===
-	flag.StringVar(&config.ScopeFile, "scope", "", "")
-	flag.StringVar(&config.ScopeFile, "S", "", "")
-	flag.BoolVar(&config.OutOfScope, "out-of-scope", false, "")
-	flag.BoolVar(&config.ScopeStats, "scope-stats", false, "")
+	fs.StringVar(&config.ScopeFile, "scope", "", "")
+	fs.StringVar(&config.ScopeFile, "S", "", "")
+	fs.BoolVar(&config.OutOfScope, "out-of-scope", false, "")
+	fs.BoolVar(&config.ScopeStats, "scope-stats", false, "")
+	fs.StringVar(&config.ScopeHost, "scope-host", "", "")
+
+	if err := fs.Parse(args); err != nil {
+		return nil, err
+	}
 
-	flag.Parse()
-	return config
+	config.setFlags = make(map[string]bool)
+	fs.Visit(func(f *flag.Flag) {
+		config.setFlags[f.Name] = true
+	})
+
+	// The registered-domain preset is a variant of path mode
+	if config.RegDomainPath && config.Mode == "url" {
+		config.Mode = "path"
+	}
+	return config, nil
+}
+
+// flagSet reports whether any of names (a flag and its aliases) was set
+// explicitly on the command line
+func (c *CLIConfig) flagSet(names ...string) bool {
+	for _, name := range names {
+		if c.setFlags[name] {
+			return true
+		}
+	}
+	return false
 }
 
 // printUsage prints a professional, categorized help message
@@ -196,50 +404,188 @@ func printUsage() {
 USAGE:
   dupdurl [OPTIONS] < urls.txt
   cat urls.txt | dupdurl [OPTIONS]
+  dupdurl [OPTIONS] -i a.txt -i b.txt
 
 BASIC OPTIONS:
-  -m, --mode <mode>              Mode: url, path, host, params, raw (default: url)
+  -i, --input <file>             Read URLs from file instead of stdin (repeatable;
+                                 unreadable files are skipped; gzipped files are
+                                 decompressed automatically)
+  --gzip                         Also decompress gzipped stdin
+  -m, --mode <mode>              Mode: url, path, host, params, presence, hash, raw
+                                 (default: url); hash reads URL<TAB>hash lines
   -f, --fuzzy                    Replace IDs with {id} placeholder
   -fp, --fuzzy-patterns <list>   Patterns: numeric, uuid, hash, token (default: numeric)
+  --fuzzy-custom <regex=name>    With --fuzzy, also replace path segments matching regex
+                                 with {name}, e.g. 'v[0-9]+=ver' (repeatable)
+  --collapse-id-runs             With --fuzzy, merge /{id}/{id}/ runs into /{ids}/
+  --dedup-by-structural-path     Replace every dynamic-looking segment (IDs, UUIDs, hashes,
+                                 tokens, ab12cd) with {dyn}; coarser than --fuzzy
+  --dedup-ignore-trailing-numbers-in-host
+                                 Treat web01/web02-style hosts as one (output keeps host)
   --case-sensitive               Consider case when comparing
   --keep-www                     Don't strip www. prefix
+  --www-apex-only                Strip www. only before the registered domain
+                                 (www.example.com, not www.blog.example.com)
   --keep-scheme                  Keep http/https distinction
+  --keep-fqdn-dot                Keep a trailing dot on hosts (example.com. != example.com)
+  --dedup-cross-scheme-and-trailing-slash
+                                 Preset: http://x/a/ and https://x/a are one URL
+                                 (output uses https, no trailing slash)
+  --dedup-by-registered-domain-and-path
+                                 Preset: path mode keyed on the registered domain, so
+                                 api.example.com/users = www.example.com/users
+  --keep-fragment                Keep #fragments in the dedup key and output
+  --collapse-amp                 Treat /amp/article and /article/amp as /article
+  --locale-scan-all-segments     Detect locales anywhere in the path (/docs/en/page),
+                                 not just in the first two segments
+  --locale-aliases <list>        Map nonstandard locale codes to canonical ones
+                                 (e.g., us=en,br=pt)
+  --locale-param-l               Also read the locale from ?l= (off by default: ?l= is
+                                 often a limit)
+  --locale-params <list>         Extra query params holding the locale, on top of lang,
+                                 locale, language, hl (e.g., culture,ui-lang,lc)
+  --locale-priority <list>       Preferred locales, most preferred first (default: en)
+  --locale-dedup                 Print each URL in its most preferred locale seen,
+                                 instead of the first variant seen
+
+CUSTOM KEYS:
+  --key-regex <pattern>          Build the dedup key by applying this regex to the raw URL
+                                 (bypasses built-in normalization for the key)
+  --key-template <template>      Replacement for --key-regex matches ($1, ${name})
+  --regex-timeout <duration>     Give up on a URL (counted as an error) when --key-regex or
+                                 --fuzzy-custom takes longer than this (e.g. 100ms)
 
 URL PARAMETERS:
   -ip, --ignore-params <list>    Remove specific params (e.g., utm_source,fbclid)
+  --ignore-state-params          Ignore UI state params (tab, modal, page, ...) when
+                                 deduping; url-mode output keeps them
+  --state-params <list>          Override the state params used by --ignore-state-params
+  --semicolon-query              Treat ';' as a query separator (?a=1;b=2 = ?a=1&b=2)
   -sp, --sort-params             Sort parameters alphabetically
+  --dedup-param-values           Sort repeated param values and drop duplicates
+                                 (?tag=b&tag=a&tag=b -> ?tag=a&tag=b)
+  --keep-param-values            Different param values make different URLs
+                                 (?q=foo != ?q=bar; many more unique URLs)
+  --dedup-ignore-case-in-query-values
+                                 With --keep-param-values, ?color=Red = ?color=red
+                                 (dedup key only; output keeps the first case seen)
+  --dedup-ignore-query-order-only
+                                 Dedupe regardless of param order, keep source order in output
+  --strip-query-fragment-order-noise
+                                 Preset: param order and #fragments never split a URL,
+                                 and output uses sorted params without fragment
   --path-include-query           In path mode, include query string
+  --collapse-empty-query         With --path-include-query, treat /x?utm=y (all params
+                                 ignored) the same as /x
+  --path-no-host                 In path mode, drop the host so paths collapse across hosts
+  --max-hosts-per-path <n>       With --path-no-host, keep paths seen on more than n hosts
+                                 separate per host (coincidental collisions)
 
 FILTERS:
   -ie, --ignore-extensions <ext> Skip these extensions (e.g., jpg,png,css)
   -fe, --filter-extensions <ext> Only process these extensions (e.g., js,html,php)
   -ad, --allow-domains <list>    Only these domains (whitelist)
   -bd, --block-domains <list>    Skip these domains (blacklist)
+  --max-path-segments <n>        Skip URLs with more than n path segments
+  --min-path-segments <n>        Skip URLs with fewer than n path segments
+  --max-per-host <n>             Keep only the first n unique URLs per host (dropped
+                                 URLs reported in --stats)
+  --exclude-status <codes>       Skip lines annotated with these statuses (e.g., 404,500)
+                                 Accepts "url [404]" and "[404] url" input
+  --keep-status                  Keep status annotations in the output ([404] url, or
+                                 a JSON "status" field); duplicates report the most
+                                 common status
+  --keep-method                  Strip a leading HTTP method ("POST url") before deduping
+                                 and list the methods seen per URL (GET,POST url, or
+                                 a JSON "methods" field)
+  --scheme-breakdown             Count occurrences per scheme (url http=1 https=2, or a
+                                 JSON "schemes" field); needs a scheme-insensitive key
+                                 (-m path/host/params or --dedup-cross-scheme-and-trailing-slash)
+  --distinct-variants            Count the distinct query value combinations seen per URL
+                                 (url variants=3, or a JSON "distinct_variants" field)
 
 OUTPUT:
-  -o, --output <format>          Format: text, json, csv (default: text)
+  -o, --output <format>          Format: text, json, ndjson, csv, html (default: text)
   -c, --counts                   Show occurrence counts
+  --sort <order>                 Order output: count (most first), count-asc, alpha
+                                 (default: first-seen order)
+  --output-file <file>           Write results to file instead of stdout
+  --output-append                Append to --output-file instead of truncating it
+                                 (text, csv, ndjson; csv skips the repeated header)
+  --csv-columns <list>           Extra CSV columns per entry: host, path, scheme,
+                                 params (count), extension
+  --counts-file <file>           Also write url,count CSV to file (stdout unchanged)
+  --locale-coverage <file>       Write JSON listing the locales found per endpoint to file,
+                                 or to stderr with '-' (spot missing translations)
+  --locale-prefer-shortest-path  Without a priority locale, report the variant with the
+                                 shortest path instead of the unlocalized one
   -s, --stats                    Show statistics
   -sd, --stats-detailed          Show detailed statistics
+  --stats-oneline                Show statistics as a single key=value line
+  --stats-template <tmpl>        Show statistics with a Go template, e.g.
+                                 '{{.UniqueURLs}}/{{.TotalProcessed}} unique'
+  --stats-json <file>            Write statistics as one JSON object to file, or to
+                                 stderr with '-' (replaces the other stats formats)
   -v, --verbose                  Show errors and warnings
+  --explain <url>                Trace one URL through each normalization stage, with
+                                 its final dedup key, instead of reading input
+  --fingerprint                  Print a stable hash of the dedup keys that would be printed
+  --canonical-output             Emit the locale-free base URL for each group
+  --group-output-by-template     With --fuzzy, print JSON {template, count, examples} groups
+  --keep <which>                 URL printed per group: first, most-common (the concrete
+                                 variant seen most often), newest (the variant with the
+                                 latest leading timestamp, e.g. "2024-05-01T10:00:00Z url"
+                                 or a Wayback "20240501100000 url") (default: first)
+  --dedup-report-duplicates      Print only the removed duplicates, each with the URL
+                                 it collapsed into (text: "line<TAB>representative")
+  --summarize-domains <file>     Also write unique URL counts per registered domain
+                                 (eTLD+1) to file, or to stderr with '-'
 
 PERFORMANCE:
   -w, --workers <n>              Parallel workers (default: 1, 0=auto)
   --batch-size <n>               Batch size (default: 1000)
+  --max-unique <n>               Cap unique URLs in memory, evicting the lowest counts
+                                 first (lossy; reported in --stats)
+  --input-limit-bytes <n>        Stop reading input after n bytes, at the last complete
+                                 line (truncation reported in --stats; with --stream,
+                                 waits for one more byte once exactly n are read)
 
 ADVANCED:
   --stream                       Process infinite streams
   --stream-interval <duration>   Flush interval (default: 5s)
   --stream-buffer <n>            Max buffer before flush (default: 10000)
-  -d, --diff <file>              Compare with baseline JSON
+  --stream-flush-mode <mode>     Flush triggers: size, time, both (default: both)
+  --stream-global-dedup          Never re-emit a URL flushed in an earlier window
+                                 (keeps a hash per distinct URL in memory)
+  -d, --diff <file>              Compare with baseline (JSON, CSV output, or one URL per line)
+  --diff-ignore-counts           Only report added/removed URLs, not count changes
+  --diff-summary-only            Print only the one-line diff summary, to stdout
+  --only-new-across <files>      Print only URLs absent from every listed baseline
+                                 (comma-separated; JSON, CSV or one URL per line)
   -sb, --save-baseline <file>    Save results as baseline JSON
+  --webhook-json <file>          With --diff, write {"added":[...],"count":N} payload
   --config <path>                Load config file (~/.config/dupdurl/config.yml)
+  -p, --profile <name>           Apply a config profile (built in: aggressive, bugbounty,
+                                 conservative); explicit flags still win
   --save-config <path>           Save current settings to config file
   -S, --scope <file>             Scope file with domain patterns (*.example.com)
   --out-of-scope                 Show only out-of-scope URLs
   --scope-stats                  Show scope statistics
+  --scope-host <host>            Host to scope-check entries that carry none
+                                 (--mode params, --path-no-host)
   --storage <backend>            Backend: memory, sqlite (default: memory)
   --db-path <path>               SQLite database path
+  --sqlite-journal-mode <mode>   SQLite journal: wal, delete, truncate, persist, memory, off
+                                 (default: wal)
+  --sqlite-synchronous <mode>    SQLite synchronous: off, normal, full, extra
+  --sqlite-cache-size <n>        SQLite cache size (pages, or KiB if negative)
+  --export-sqlite <path>         Save the final results into a fresh SQLite database
+  --near-dedup                   Cluster unique URLs within a few edits (Levenshtein) and
+                                 keep the first of each cluster (slow: O(n^2))
+  --near-dedup-distance <n>      Max edit distance within a cluster (default: 2)
+  --near-dedup-max <n>           Skip --near-dedup above n unique URLs (default: 5000)
+  --import-baseline-into-storage <file>
+                                 Seed the storage backend with a baseline before reading stdin
 
 EXAMPLES:
   Basic deduplication:
@@ -264,23 +610,47 @@ MORE INFO:
 // Validate checks if the configuration is valid
 func (c *CLIConfig) Validate() error {
 	// Validate mode
-	validModes := []string{"url", "path", "host", "raw", "params"}
+	validModes := []string{"url", "path", "host", "raw", "params", "presence", "hash"}
 	if !contains(validModes, c.Mode) {
 		return fmt.Errorf("invalid mode: %s (valid: %s)", c.Mode, strings.Join(validModes, ", "))
 	}
 
 	// Validate output format
-	validFormats := []string{"text", "json", "csv"}
+	validFormats := []string{"text", "json", "csv", "ndjson", "html"}
 	if !contains(validFormats, c.OutputFormat) {
 		return fmt.Errorf("invalid output format: %s (valid: %s)", c.OutputFormat, strings.Join(validFormats, ", "))
 	}
 
+	// Validate output order
+	if c.Sort != "" && !contains(output.SortModes, c.Sort) {
+		return fmt.Errorf("invalid sort: %s (valid: %s)", c.Sort, strings.Join(output.SortModes, ", "))
+	}
+
 	// Validate storage backend
 	validBackends := []string{"memory", "sqlite"}
 	if !contains(validBackends, c.StorageBackend) {
 		return fmt.Errorf("invalid storage backend: %s (valid: %s)", c.StorageBackend, strings.Join(validBackends, ", "))
 	}
 
+	if err := c.ToSQLiteOptions().Validate(); err != nil {
+		return err
+	}
+
+	// Validate streaming flush mode
+	validFlushModes := []string{processor.FlushSize, processor.FlushTime, processor.FlushBoth}
+	if !contains(validFlushModes, c.StreamingFlushMode) {
+		return fmt.Errorf("invalid stream flush mode: %s (valid: %s)", c.StreamingFlushMode, strings.Join(validFlushModes, ", "))
+	}
+
+	if c.StreamingGlobalDedup && !c.Streaming {
+		return fmt.Errorf("--stream-global-dedup requires --stream")
+	}
+
+	// Fail before reading input rather than on the first database call
+	if (c.StorageBackend == "sqlite" || c.ExportSQLite != "") && !storage.SQLiteAvailable() {
+		return storage.ErrSQLiteUnavailable
+	}
+
 	// Validate workers
 	if c.Workers < 0 {
 		return fmt.Errorf("workers must be >= 0")
@@ -296,6 +666,282 @@ func (c *CLIConfig) Validate() error {
 		return fmt.Errorf("cannot use --ignore-extensions and --filter-extensions together (choose blacklist or whitelist)")
 	}
 
+	if c.MaxPathSegments < 0 {
+		return fmt.Errorf("max-path-segments must be >= 0")
+	}
+
+	if c.MinPathSegments < 0 {
+		return fmt.Errorf("min-path-segments must be >= 0")
+	}
+
+	if c.MaxPathSegments > 0 && c.MinPathSegments > c.MaxPathSegments {
+		return fmt.Errorf("min-path-segments (%d) cannot exceed max-path-segments (%d)", c.MinPathSegments, c.MaxPathSegments)
+	}
+
+	if c.WWWApexOnly && c.KeepWWW {
+		return fmt.Errorf("cannot use --www-apex-only with --keep-www")
+	}
+
+	if c.CrossScheme && c.KeepScheme {
+		return fmt.Errorf("cannot use --dedup-cross-scheme-and-trailing-slash with --keep-scheme")
+	}
+
+	if c.KeepQueryOrder && c.SortParams {
+		return fmt.Errorf("cannot use --dedup-ignore-query-order-only with --sort-params")
+	}
+
+	if c.StripOrderNoise && (c.KeepQueryOrder || c.KeepFragment || !c.IgnoreFragment) {
+		return fmt.Errorf("cannot use --strip-query-fragment-order-noise with --dedup-ignore-query-order-only, --keep-fragment or --ignore-fragment=false")
+	}
+
+	// A breakdown is only useful when http and https share an entry
+	if c.SchemeBreakdown && !c.CrossScheme && c.Mode != "path" && c.Mode != "host" && c.Mode != "params" {
+		return fmt.Errorf("--scheme-breakdown requires --mode path, host or params, or --dedup-cross-scheme-and-trailing-slash")
+	}
+
+	if c.KeepValues && c.Mode != "url" {
+		return fmt.Errorf("--keep-param-values requires --mode url")
+	}
+
+	if c.IgnoreValueCase && !c.KeepValues {
+		return fmt.Errorf("--dedup-ignore-case-in-query-values requires --keep-param-values (values are not part of the key otherwise)")
+	}
+
+	if c.KeepQueryOrder && c.DedupValues {
+		return fmt.Errorf("cannot use --dedup-ignore-query-order-only with --dedup-param-values")
+	}
+
+	if c.KeyRegex != "" {
+		if _, err := regexp.Compile(c.KeyRegex); err != nil {
+			return fmt.Errorf("invalid --key-regex: %w", err)
+		}
+		if err := normalizer.CheckRegex(c.KeyRegex); err != nil {
+			return fmt.Errorf("invalid --key-regex: %w", err)
+		}
+	} else if c.KeyTemplate != "" {
+		return fmt.Errorf("--key-template requires --key-regex")
+	}
+
+	if c.RegexTimeout != "" {
+		if d, err := time.ParseDuration(c.RegexTimeout); err != nil || d <= 0 {
+			return fmt.Errorf("invalid --regex-timeout: %q (use a positive duration such as 100ms)", c.RegexTimeout)
+		}
+	}
+
+	if c.DropEmptyQuery && !c.PathIncludeQuery {
+		return fmt.Errorf("--collapse-empty-query requires --path-include-query")
+	}
+
+	if c.RegDomainPath && c.Mode != "path" {
+		return fmt.Errorf("--dedup-by-registered-domain-and-path requires --mode path")
+	}
+
+	if c.RegDomainPath && c.PathNoHost {
+		return fmt.Errorf("cannot use --dedup-by-registered-domain-and-path with --path-no-host")
+	}
+
+	if c.PathNoHost && c.Mode != "path" {
+		return fmt.Errorf("--path-no-host requires --mode path")
+	}
+
+	if c.MaxUnique < 0 {
+		return fmt.Errorf("max-unique must be >= 0")
+	}
+
+	if c.MaxPerHost < 0 {
+		return fmt.Errorf("max-per-host must be >= 0")
+	}
+
+	if c.MaxUnique > 0 && c.Streaming {
+		return fmt.Errorf("cannot use --max-unique with --stream")
+	}
+
+	if c.MaxPerHost > 0 && c.Streaming {
+		return fmt.Errorf("cannot use --max-per-host with --stream")
+	}
+
+	if c.InputLimitBytes < 0 {
+		return fmt.Errorf("input-limit-bytes must be >= 0")
+	}
+
+	if c.NearDedup && c.NearDistance < 1 {
+		return fmt.Errorf("near-dedup-distance must be >= 1")
+	}
+
+	if c.NearMax < 0 {
+		return fmt.Errorf("near-dedup-max must be >= 0")
+	}
+
+	if c.MaxHostsPerPath < 0 {
+		return fmt.Errorf("max-hosts-per-path must be >= 0")
+	}
+
+	if c.MaxHostsPerPath > 0 && !c.PathNoHost {
+		return fmt.Errorf("--max-hosts-per-path requires --path-no-host")
+	}
+
+	if c.MaxHostsPerPath > 0 && c.Streaming {
+		return fmt.Errorf("cannot use --max-hosts-per-path with --stream")
+	}
+
+	if c.CollapseIDRuns && !c.FuzzyMode {
+		return fmt.Errorf("--collapse-id-runs requires --fuzzy")
+	}
+
+	if c.GroupByTemplate && !c.FuzzyMode {
+		return fmt.Errorf("--group-output-by-template requires --fuzzy")
+	}
+
+	if len(c.FuzzyCustom) > 0 && !c.FuzzyMode {
+		return fmt.Errorf("--fuzzy-custom requires --fuzzy")
+	}
+
+	for _, spec := range c.FuzzyCustom {
+		if _, err := normalizer.ParseCustomPattern(spec); err != nil {
+			return fmt.Errorf("invalid --fuzzy-custom: %w", err)
+		}
+	}
+
+	if _, err := locale.ParseAliases(c.LocaleAliases); err != nil {
+		return fmt.Errorf("invalid --locale-aliases: %w", err)
+	}
+
+	if _, err := processor.ParseStatusSet(c.ExcludeStatus); err != nil {
+		return fmt.Errorf("invalid --exclude-status: %w", err)
+	}
+
+	if c.StatsTemplate != "" {
+		if _, err := stats.ParseTemplate(c.StatsTemplate); err != nil {
+			return fmt.Errorf("invalid --stats-template: %w", err)
+		}
+	}
+
+	if c.ScopeHost != "" && c.ScopeFile == "" {
+		return fmt.Errorf("--scope-host requires --scope")
+	}
+
+	if c.DiffIgnoreCounts && c.DiffBaseline == "" {
+		return fmt.Errorf("--diff-ignore-counts requires --diff")
+	}
+
+	if c.DiffSummaryOnly && c.DiffBaseline == "" {
+		return fmt.Errorf("--diff-summary-only requires --diff")
+	}
+
+	if c.WebhookJSON != "" && c.DiffBaseline == "" {
+		return fmt.Errorf("--webhook-json requires --diff")
+	}
+
+	if c.OnlyNewAcross != "" && (c.DiffBaseline != "" || c.Streaming || c.ReportDuplicates) {
+		return fmt.Errorf("cannot use --only-new-across with --diff, --stream or --dedup-report-duplicates")
+	}
+
+	if c.ExportSQLite != "" && c.Streaming {
+		return fmt.Errorf("cannot use --export-sqlite with --stream")
+	}
+
+	if c.ImportBaseline != "" && c.Streaming {
+		return fmt.Errorf("cannot use --import-baseline-into-storage with --stream")
+	}
+
+	// Validate representative choice
+	validKeeps := []string{"first", "most-common", "newest"}
+	if !contains(validKeeps, c.Keep) {
+		return fmt.Errorf("invalid keep: %s (valid: %s)", c.Keep, strings.Join(validKeeps, ", "))
+	}
+
+	if c.Keep == "most-common" && c.GroupByTemplate {
+		return fmt.Errorf("cannot use --keep most-common with --group-output-by-template")
+	}
+
+	// Storage backends bypass the in-memory deduplicator
+	if c.usesStorage() && (c.Fingerprint || c.MaxHostsPerPath > 0 || c.GroupByTemplate || c.KeepStatus || c.KeepMethod || c.SchemeBreakdown || c.DistinctVariants || c.MaxUnique > 0 || c.MaxPerHost > 0 || c.Keep != "first" || c.LocaleDedup) {
+		return fmt.Errorf("--fingerprint, --max-hosts-per-path, --group-output-by-template, --keep-status, --keep-method, --scheme-breakdown, --distinct-variants, --max-unique, --max-per-host, --keep most-common/newest and --locale-dedup require in-memory deduplication (no --storage sqlite or --import-baseline-into-storage)")
+	}
+
+	// Fingerprinting needs the complete unique set, which streaming never holds
+	if c.Fingerprint && c.Streaming {
+		return fmt.Errorf("cannot use --fingerprint with --stream")
+	}
+
+	if c.GroupByTemplate && c.Streaming {
+		return fmt.Errorf("cannot use --group-output-by-template with --stream")
+	}
+
+	if c.SummarizeDomains != "" && c.Streaming {
+		return fmt.Errorf("cannot use --summarize-domains with --stream")
+	}
+
+	if c.Sort != "" && c.Streaming {
+		return fmt.Errorf("cannot use --sort with --stream")
+	}
+
+	if c.CountsFile != "" && c.Streaming {
+		return fmt.Errorf("cannot use --counts-file with --stream")
+	}
+
+	// Each window would write a separate HTML document
+	if c.OutputFormat == "html" && c.Streaming {
+		return fmt.Errorf("cannot use --output html with --stream")
+	}
+
+	if columns := c.csvColumns(); len(columns) > 0 {
+		if c.OutputFormat != "csv" {
+			return fmt.Errorf("--csv-columns requires --output csv")
+		}
+		for _, col := range columns {
+			if !contains(output.CSVColumns, col) {
+				return fmt.Errorf("invalid csv column: %s (valid: %s)", col, strings.Join(output.CSVColumns, ", "))
+			}
+		}
+	}
+
+	// A JSON array can't be extended by appending another one after it
+	if c.OutputAppend {
+		if c.OutputFile == "" {
+			return fmt.Errorf("--output-append requires --output-file")
+		}
+		if !output.LineOriented(c.OutputFormat) || c.GroupByTemplate {
+			return fmt.Errorf("--output-append requires a line-oriented format (text, csv, ndjson)")
+		}
+	}
+
+	if c.NearDedup && c.Streaming {
+		return fmt.Errorf("cannot use --near-dedup with --stream")
+	}
+
+	if c.ReportDuplicates && (c.Streaming || c.usesStorage() || c.Fingerprint || c.GroupByTemplate) {
+		return fmt.Errorf("cannot use --dedup-report-duplicates with --stream, --storage sqlite, --fingerprint or --group-output-by-template")
+	}
+
+	if c.LocaleDedup && c.Keep != "first" {
+		return fmt.Errorf("cannot use --locale-dedup with --keep %s", c.Keep)
+	}
+
+	if c.LocaleDedup && c.Streaming {
+		return fmt.Errorf("cannot use --locale-dedup with --stream")
+	}
+
+	if c.Keep != "first" && c.Streaming {
+		return fmt.Errorf("cannot use --keep %s with --stream", c.Keep)
+	}
+
+	if c.DistinctVariants && c.Streaming {
+		return fmt.Errorf("cannot use --distinct-variants with --stream")
+	}
+
+	if c.Mode == "hash" && c.Streaming {
+		return fmt.Errorf("cannot use --mode hash with --stream")
+	}
+
+	if c.LocaleCoverage != "" && c.Streaming {
+		return fmt.Errorf("cannot use --locale-coverage with --stream")
+	}
+
+	if c.LocaleShortest && c.LocaleCoverage == "" {
+		return fmt.Errorf("--locale-prefer-shortest-path requires --locale-coverage")
+	}
+
 	return nil
 }
 
@@ -305,24 +951,69 @@ func (c *CLIConfig) ToNormalizerConfig() *normalizer.Config {
 
 	config.Mode = c.Mode
 	config.IgnoreParams = normalizer.ParseSet(c.IgnoreParams)
+	if c.IgnoreState {
+		config.StateParams = normalizer.ParseSet(c.StateParams)
+	}
+	config.SemicolonQuery = c.SemicolonQuery
 	config.SortParams = c.SortParams
-	config.IgnoreFragment = c.IgnoreFragment
+	config.KeepQueryOrder = c.KeepQueryOrder
+	config.DedupValues = c.DedupValues
+	config.KeepParamValues = c.KeepValues
+	config.IgnoreValueCase = c.IgnoreValueCase
+	config.IgnoreFragment = c.IgnoreFragment && !c.KeepFragment
+	if c.StripOrderNoise {
+		config.SortParams = true
+		config.IgnoreFragment = true
+	}
 	config.CaseSensitive = c.CaseSensitive
 	config.KeepWWW = c.KeepWWW
 	config.KeepScheme = c.KeepScheme
+	config.KeepFQDNDot = c.KeepFQDNDot
+	config.WWWApexOnly = c.WWWApexOnly
+	config.IgnoreScheme = c.CrossScheme
+	config.PathRegisteredDomain = c.RegDomainPath
 	config.TrimSpaces = c.TrimSpaces
 	config.FuzzyMode = c.FuzzyMode
 	config.PathIncludeQuery = c.PathIncludeQuery
+	config.PathNoHost = c.PathNoHost
+	config.DropEmptyQuery = c.DropEmptyQuery
+	if c.KeyRegex != "" {
+		config.KeyRegex = regexp.MustCompile(c.KeyRegex)
+		config.KeyTemplate = c.KeyTemplate
+	}
+	config.RegexTimeout, _ = time.ParseDuration(c.RegexTimeout)
+	config.FuzzyHostNumbers = c.FuzzyHostNumbers
+	config.CollapseIDRuns = c.CollapseIDRuns
+	config.StructuralPath = c.StructuralPath
+	config.CollapseAMP = c.CollapseAMP
 	config.AllowDomains = normalizer.ParseSet(c.AllowDomains)
 	config.BlockDomains = normalizer.ParseSet(c.BlockDomains)
 	config.IgnoreExtensions = normalizer.ParseSet(c.IgnoreExtensions)
 	config.FilterExtensions = normalizer.ParseSet(c.FilterExtensions)
+	config.MaxPathSegments = c.MaxPathSegments
+	config.MinPathSegments = c.MinPathSegments
+	config.CanonicalOutput = c.CanonicalOutput
+	if priority := c.localePriority(); len(priority) > 0 {
+		config.LocalePriority = priority
+	}
+	if c.LocaleScanAll || c.LocaleAliases != "" || c.LocaleParamL || c.LocaleParams != "" {
+		config.LocaleDetector = locale.NewDetectorWithParams(strings.Split(c.LocaleParams, ","))
+		config.LocaleDetector.ScanAllSegments = c.LocaleScanAll
+		config.LocaleDetector.Aliases, _ = locale.ParseAliases(c.LocaleAliases)
+		config.LocaleDetector.SingleLetterParam = c.LocaleParamL
+	}
 
 	// Configure fuzzy patterns
 	if c.FuzzyMode && c.FuzzyPatterns != "" {
 		patterns := strings.Split(c.FuzzyPatterns, ",")
 		normalizer.EnablePatterns(config.FuzzyPatterns, patterns)
 	}
+	if c.FuzzyMode {
+		for _, spec := range c.FuzzyCustom {
+			pattern, _ := normalizer.ParseCustomPattern(spec)
+			config.FuzzyPatterns = append(config.FuzzyPatterns, pattern)
+		}
+	}
 
 	return config
 }
@@ -335,10 +1026,127 @@ func (c *CLIConfig) ToProcessorConfig() *processor.Config {
 	config.Workers = c.Workers
 	config.BatchSize = c.BatchSize
 	config.Verbose = c.Verbose
+	config.DetailedStats = c.detailedStats()
+	config.MaxHostsPerPath = c.MaxHostsPerPath
+	config.MaxUnique = c.MaxUnique
+	config.MaxPerHost = c.MaxPerHost
+	config.InputLimitBytes = c.InputLimitBytes
+	config.LocaleCoverage = c.LocaleCoverage != ""
+	config.LocalePreferShortestPath = c.LocaleShortest
+	config.KeepMostCommon = c.Keep == "most-common"
+	config.KeepNewest = c.Keep == "newest"
+	config.LocaleDedup = c.LocaleDedup
+	config.ReportDuplicates = c.ReportDuplicates
+	config.ExcludeStatus, _ = processor.ParseStatusSet(c.ExcludeStatus)
+	config.KeepStatus = c.KeepStatus
+	config.KeepMethod = c.KeepMethod
+	config.SchemeBreakdown = c.SchemeBreakdown
+	config.DistinctVariants = c.DistinctVariants
+	if c.GroupByTemplate {
+		config.MaxExamples = templateExamples
+	}
 
 	return config
 }
 
+// usesStorage reports whether batch processing goes through a storage backend
+func (c *CLIConfig) usesStorage() bool {
+	return c.StorageBackend == "sqlite" || c.ImportBaseline != ""
+}
+
+// openStorage opens the configured storage backend
+func (c *CLIConfig) openStorage() (storage.Backend, error) {
+	if c.StorageBackend == "sqlite" {
+		return storage.NewSQLiteBackendWithOptions(c.DBPath, c.ToSQLiteOptions())
+	}
+	return storage.NewMemoryBackend(), nil
+}
+
+// ToSQLiteOptions converts CLI config to SQLite pragma options
+func (c *CLIConfig) ToSQLiteOptions() storage.SQLiteOptions {
+	return storage.SQLiteOptions{
+		JournalMode: c.SQLiteJournal,
+		Synchronous: c.SQLiteSync,
+		CacheSize:   c.SQLiteCacheSize,
+	}
+}
+
+// listFlag is a flag that may be repeated, collecting every value
+type listFlag []string
+
+func (l *listFlag) String() string {
+	return strings.Join(*l, ",")
+}
+
+func (l *listFlag) Set(value string) error {
+	*l = append(*l, value)
+	return nil
+}
+
+// detailedStats reports whether a statistics output may show the domain,
+// parameter and extension breakdowns, which cost an extra parse per line
+func (c *CLIConfig) detailedStats() bool {
+	return c.ShowStatsDetailed || c.StatsJSON != "" || c.StatsTemplate != ""
+}
+
+// csvColumns returns the --csv-columns list, trimmed and lowercased
+func (c *CLIConfig) csvColumns() []string {
+	var columns []string
+	for _, col := range strings.Split(c.CSVColumns, ",") {
+		if col = strings.ToLower(strings.TrimSpace(col)); col != "" {
+			columns = append(columns, col)
+		}
+	}
+	return columns
+}
+
+// localePriority returns the --locale-priority locales, most preferred first
+func (c *CLIConfig) localePriority() []string {
+	var priority []string
+	for _, loc := range strings.Split(c.LocalePriority, ",") {
+		if loc = strings.ToLower(strings.TrimSpace(loc)); loc != "" {
+			priority = append(priority, loc)
+		}
+	}
+	return priority
+}
+
+// openInput returns the input files as one stream, or stdin when none were
+// given
+func (c *CLIConfig) openInput() (io.ReadCloser, error) {
+	if len(c.Inputs) == 0 {
+		stdin, err := c.stdin()
+		if err != nil {
+			return nil, err
+		}
+		return io.NopCloser(stdin), nil
+	}
+	return processor.OpenFiles(c.Inputs, c.Verbose)
+}
+
+// stdin returns standard input, decompressed when --gzip is set and it is
+// gzipped
+func (c *CLIConfig) stdin() (io.Reader, error) {
+	if !c.Gzip {
+		return os.Stdin, nil
+	}
+	r, err := processor.Decompress(os.Stdin)
+	if err != nil {
+		return nil, fmt.Errorf("error reading stdin: %w", err)
+	}
+	return r, nil
+}
+
+// explain writes the value of a URL after each normalization stage. The
+// steps reached are written even when a stage fails.
+func explain(w io.Writer, config *normalizer.Config, raw string) error {
+	steps, err := config.Explain(raw)
+	for _, step := range steps {
+		fmt.Fprintf(w, "%-8s %s\n", step.Stage+":", step.Value)
+	}
+	return err
+}
+
 // contains checks if a slice contains a string
 func contains(slice []string, item string) bool {
 	for _, s := range slice {
@@ -354,17 +1162,10 @@ func main() {
 	cliConfig := ParseFlags()
 
 	// Load config file if specified (or use default location)
-	var fileConfig *config.File
-	if cliConfig.ConfigFile != "" {
-		var err error
-		fileConfig, err = config.Load(cliConfig.ConfigFile)
-		if err != nil {
-			fmt.Fprintf(os.Stderr, "Error loading config file: %v\n", err)
-			os.Exit(1)
-		}
-	} else {
-		// Try to load from default location
-		fileConfig = config.LoadOrDefault()
+	fileConfig, err := loadFileConfig(cliConfig)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error loading config: %v\n", err)
+		os.Exit(1)
 	}
 
 	// Merge file config with CLI flags (CLI flags take precedence)
@@ -387,6 +1188,15 @@ func main() {
 		os.Exit(1)
 	}
 
+	// Trace a single URL instead of processing input
+	if cliConfig.Explain != "" {
+		if err := explain(os.Stdout, cliConfig.ToNormalizerConfig(), cliConfig.Explain); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		return
+	}
+
 	// Auto-detect number of workers if set to 0
 	if cliConfig.Workers == 0 {
 		cliConfig.Workers = runtime.NumCPU()
@@ -411,10 +1221,28 @@ func main() {
 	var differ *diff.Differ
 	if cliConfig.DiffBaseline != "" {
 		differ = diff.NewDiffer()
-		if err := differ.LoadBaseline(cliConfig.DiffBaseline); err != nil {
+		if err := differ.LoadBaselineFile(cliConfig.DiffBaseline); err != nil {
 			fmt.Fprintf(os.Stderr, "Error loading baseline: %v\n", err)
 			os.Exit(1)
 		}
+		if cliConfig.DiffIgnoreCounts {
+			differ.IgnoreCounts = true
+		}
+	}
+
+	// Baselines whose union filters the output down to new URLs
+	var seenBefore *diff.Differ
+	if cliConfig.OnlyNewAcross != "" {
+		seenBefore = diff.NewDiffer()
+		for _, path := range strings.Split(cliConfig.OnlyNewAcross, ",") {
+			if path = strings.TrimSpace(path); path == "" {
+				continue
+			}
+			if err := seenBefore.LoadBaselineFile(path); err != nil {
+				fmt.Fprintf(os.Stderr, "Error loading baseline %s: %v\n", path, err)
+				os.Exit(1)
+			}
+		}
 	}
 
 	// Get output formatter
@@ -423,9 +1251,37 @@ func main() {
 		fmt.Fprintf(os.Stderr, "Error creating formatter: %v\n", err)
 		os.Exit(1)
 	}
+	if csvFormatter, ok := formatter.(*output.CSVFormatter); ok {
+		csvFormatter.Columns = cliConfig.csvColumns()
+	}
+
+	// Write results to a file instead of stdout if requested
+	var out io.Writer = os.Stdout
+	if cliConfig.OutputFile != "" {
+		f, existing, err := output.OpenFile(cliConfig.OutputFile, cliConfig.OutputAppend)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error opening output file: %v\n", err)
+			os.Exit(1)
+		}
+		defer f.Close()
+		out = f
+
+		if csvFormatter, ok := formatter.(*output.CSVFormatter); ok && existing && cliConfig.OutputAppend {
+			csvFormatter.OmitHeader = true
+		}
+	}
 
 	var entries []deduplicator.Entry
 
+	// Ctrl-C or SIGTERM stops reading input; a second signal kills the
+	// process as usual
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	go func() {
+		<-ctx.Done()
+		stop()
+	}()
+
 	// Choose processing mode: streaming or batch
 	if cliConfig.Streaming {
 		// Streaming mode
@@ -433,8 +1289,14 @@ func main() {
 		streamConfig.Normalizer = cliConfig.ToNormalizerConfig()
 		streamConfig.Workers = cliConfig.Workers
 		streamConfig.Verbose = cliConfig.Verbose
+		streamConfig.DetailedStats = cliConfig.detailedStats()
+		streamConfig.ExcludeStatus, _ = processor.ParseStatusSet(cliConfig.ExcludeStatus)
+		streamConfig.KeepStatus = cliConfig.KeepStatus
+		streamConfig.KeepMethod = cliConfig.KeepMethod
+		streamConfig.SchemeBreakdown = cliConfig.SchemeBreakdown
+		streamConfig.InputLimitBytes = cliConfig.InputLimitBytes
 		streamConfig.Output = formatter
-		streamConfig.OutputWriter = os.Stdout
+		streamConfig.OutputWriter = out
 
 		// Parse flush interval
 		if cliConfig.StreamingFlushInterval != "" {
@@ -449,39 +1311,104 @@ func main() {
 		if cliConfig.StreamingMaxBuffer > 0 {
 			streamConfig.MaxBuffer = cliConfig.StreamingMaxBuffer
 		}
+		streamConfig.FlushMode = cliConfig.StreamingFlushMode
+		streamConfig.GlobalDedup = cliConfig.StreamingGlobalDedup
 
 		streamProc := processor.NewStreaming(streamConfig)
-		if err := streamProc.ProcessStreaming(os.Stdin); err != nil {
+		input, err := cliConfig.openInput()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error opening input: %v\n", err)
+			os.Exit(1)
+		}
+		defer input.Close()
+
+		// An interrupted stream still flushes its current window
+		err = streamProc.ProcessStreamingContext(ctx, input)
+		if err != nil && !errors.Is(err, context.Canceled) {
 			fmt.Fprintf(os.Stderr, "Error processing URLs: %v\n", err)
 			os.Exit(1)
 		}
 
 		// Print statistics if requested
-		stats := streamProc.GetStatistics()
-		if cliConfig.ShowStatsDetailed {
-			stats.PrintDetailed(os.Stderr)
-		} else if cliConfig.ShowStats {
-			stats.Print(os.Stderr)
-		}
+		printStatistics(streamProc.GetStatistics(), cliConfig)
 
+		if err != nil {
+			exitInterrupted()
+		}
 		return
 	}
 
 	// Batch mode (original behavior)
 	procConfig := cliConfig.ToProcessorConfig()
+
+	if cliConfig.usesStorage() {
+		backend, err := cliConfig.openStorage()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error opening %s storage (--db-path %s): %v\n",
+				cliConfig.StorageBackend, cliConfig.DBPath, err)
+			os.Exit(1)
+		}
+		procConfig.Storage = backend
+	}
+
 	proc := processor.New(procConfig)
 
-	entries, err = proc.Process(os.Stdin)
+	// Seed storage with a known baseline before reading new input
+	if cliConfig.ImportBaseline != "" {
+		baseline, err := diff.ReadBaseline(cliConfig.ImportBaseline)
+		if err == nil {
+			if err = proc.Seed(baseline); err != nil {
+				err = fmt.Errorf("seeding storage: %w", err)
+			}
+		}
+		if err != nil {
+			closeStorage(procConfig.Storage)
+			fmt.Fprintf(os.Stderr, "Error loading baseline: %v\n", err)
+			os.Exit(1)
+		}
+	}
+
+	if len(cliConfig.Inputs) > 0 {
+		entries, err = proc.ProcessFilesContext(ctx, cliConfig.Inputs)
+	} else {
+		var stdin io.Reader
+		if stdin, err = cliConfig.stdin(); err == nil {
+			entries, err = proc.ProcessContext(ctx, stdin)
+		}
+	}
+
+	// An interrupted run still outputs what was deduplicated so far
+	interrupted := errors.Is(err, context.Canceled)
+	if interrupted {
+		err = nil
+	}
+
+	// Entries have been read back, so the database can be closed before any
+	// exit below skips deferred calls
+	if cerr := closeStorage(procConfig.Storage); cerr != nil && err == nil {
+		err = cerr
+	}
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "Error processing URLs: %v\n", err)
 		os.Exit(1)
 	}
 
+	// Cluster near-identical URLs if requested
+	if cliConfig.NearDedup {
+		clustered, ok := deduplicator.ClusterNear(entries, cliConfig.NearDistance, cliConfig.NearMax)
+		if ok {
+			entries = clustered
+		} else {
+			fmt.Fprintf(os.Stderr, "Warning: skipping --near-dedup, %d unique URLs exceed --near-dedup-max %d\n",
+				len(entries), cliConfig.NearMax)
+		}
+	}
+
 	// Apply scope filtering if specified
 	if scopeChecker != nil {
 		// Count stats BEFORE filtering
 		if cliConfig.ScopeStats {
-			inScope, outScope := countScopeStats(entries, scopeChecker)
+			inScope, outScope := countScopeStats(entries, scopeChecker, cliConfig)
 			fmt.Fprintf(os.Stderr, "\n=== Scope Statistics ===\n")
 			fmt.Fprintf(os.Stderr, "In scope:     %d URLs\n", inScope)
 			fmt.Fprintf(os.Stderr, "Out of scope: %d URLs\n", outScope)
@@ -489,7 +1416,34 @@ func main() {
 		}
 
 		// Then filter
-		entries = filterByScope(entries, scopeChecker, cliConfig.OutOfScope)
+		entries = filterByScope(entries, scopeChecker, cliConfig)
+	}
+
+	// Export results to SQLite if requested
+	if cliConfig.ExportSQLite != "" {
+		if err := storage.ExportSQLite(entries, cliConfig.ExportSQLite, cliConfig.ToSQLiteOptions()); err != nil {
+			fmt.Fprintf(os.Stderr, "Error exporting to SQLite: %v\n", err)
+			os.Exit(1)
+		}
+		if cliConfig.Verbose {
+			fmt.Fprintf(os.Stderr, "Exported %d URLs to %s\n", len(entries), cliConfig.ExportSQLite)
+		}
+	}
+
+	// Write the counts sidecar if requested
+	if cliConfig.CountsFile != "" {
+		if err := output.WriteCountsFile(entries, cliConfig.CountsFile); err != nil {
+			fmt.Fprintf(os.Stderr, "Error writing counts file: %v\n", err)
+			os.Exit(1)
+		}
+	}
+
+	// Write the locale coverage report if requested
+	if cliConfig.LocaleCoverage != "" {
+		if err := writeLocaleCoverage(proc.LocaleCoverage(), cliConfig.LocaleCoverage); err != nil {
+			fmt.Fprintf(os.Stderr, "Error writing locale coverage: %v\n", err)
+			os.Exit(1)
+		}
 	}
 
 	// Save baseline if requested
@@ -504,61 +1458,299 @@ func main() {
 	// Diff mode
 	if differ != nil {
 		report := differ.Compare(entries)
-		report.PrintReport(os.Stderr)
-		fmt.Fprintf(os.Stderr, "\nSummary: %s\n", report.Summary())
+		if cliConfig.WebhookJSON != "" {
+			if err := report.SaveWebhookJSON(cliConfig.WebhookJSON); err != nil {
+				fmt.Fprintf(os.Stderr, "Error saving webhook payload: %v\n", err)
+				os.Exit(1)
+			}
+		}
+		if cliConfig.DiffSummaryOnly {
+			report.PrintSummary(out)
+		} else {
+			report.PrintReport(os.Stderr)
+			fmt.Fprintf(os.Stderr, "\nSummary: %s\n", report.Summary())
+		}
+		if interrupted {
+			exitInterrupted()
+		}
 		return
 	}
 
+	// Only URLs missing from every baseline are printed
+	if seenBefore != nil {
+		entries = seenBefore.FilterNew(entries)
+	}
+
+	// Templates are printed with the concrete URLs seen for each
+	if cliConfig.GroupByTemplate {
+		formatter = &output.TemplateFormatter{Examples: proc.Examples()}
+	}
+
+	if cliConfig.Sort != "" {
+		entries = output.SortEntries(entries, cliConfig.Sort)
+	}
+
 	// Output results
-	if err := formatter.Format(entries, os.Stdout); err != nil {
+	if cliConfig.Fingerprint {
+		fmt.Fprintln(out, proc.FingerprintKeys(entries))
+	} else if cliConfig.ReportDuplicates {
+		if err := output.WriteDuplicates(proc.Duplicates(), cliConfig.OutputFormat, out); err != nil {
+			fmt.Fprintf(os.Stderr, "Error writing output: %v\n", err)
+			os.Exit(1)
+		}
+	} else if err := formatter.Format(entries, out); err != nil {
 		fmt.Fprintf(os.Stderr, "Error writing output: %v\n", err)
 		os.Exit(1)
 	}
 
+	// Write the per-domain summary as a separate section
+	if cliConfig.SummarizeDomains != "" {
+		if err := writeDomainSummary(entries, cliConfig); err != nil {
+			fmt.Fprintf(os.Stderr, "Error writing domain summary: %v\n", err)
+			os.Exit(1)
+		}
+	}
+
 	// Print statistics if requested
-	stats := proc.GetStatistics()
-	if cliConfig.ShowStatsDetailed {
-		stats.PrintDetailed(os.Stderr)
-	} else if cliConfig.ShowStats {
-		stats.Print(os.Stderr)
+	printStatistics(proc.GetStatistics(), cliConfig)
+
+	if interrupted {
+		exitInterrupted()
+	}
+}
+
+// exitInterrupted ends a run cut short by Ctrl-C, after its partial output
+// has been written, with the conventional 128+SIGINT status
+func exitInterrupted() {
+	fmt.Fprintln(os.Stderr, "Interrupted")
+	os.Exit(130)
+}
+
+// closeStorage closes a storage backend, if any, so SQLite databases are
+// flushed and their journal files removed
+func closeStorage(backend storage.Backend) error {
+	if backend == nil {
+		return nil
 	}
+	if err := backend.Close(); err != nil {
+		return fmt.Errorf("closing storage: %w", err)
+	}
+	return nil
 }
 
-// mergeConfigs merges file config with CLI config (CLI takes precedence)
+// writeDomainSummary writes unique entry counts per registered domain to the
+// --summarize-domains file, or to stderr when it is "-"
+func writeDomainSummary(entries []deduplicator.Entry, cli *CLIConfig) error {
+	summary := output.SummarizeDomains(entries, func(entry string) string {
+		return normalizer.RegisteredDomain(scope.EntryHost(entry, cli.Mode))
+	})
+
+	if cli.SummarizeDomains == "-" {
+		fmt.Fprintf(os.Stderr, "\n=== Domain Summary ===\n")
+		if err := output.WriteDomainSummary(summary, os.Stderr); err != nil {
+			return err
+		}
+		fmt.Fprintf(os.Stderr, "======================\n")
+		return nil
+	}
+
+	f, err := os.Create(cli.SummarizeDomains)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	return output.WriteDomainSummary(summary, f)
+}
+
+// writeLocaleCoverage writes the locale coverage report as JSON to path, or
+// to stderr when it is "-"
+func writeLocaleCoverage(coverage []locale.Coverage, path string) error {
+	w := io.Writer(os.Stderr)
+	if path != "-" {
+		f, err := os.Create(path)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		w = f
+	}
+
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(coverage)
+}
+
+// printStatistics prints statistics to stderr in the requested format, or
+// writes them as JSON with --stats-json
+func printStatistics(st *stats.Statistics, cli *CLIConfig) {
+	if cli.StatsJSON != "" {
+		if err := writeStatsJSON(st, cli.StatsJSON); err != nil {
+			fmt.Fprintf(os.Stderr, "Error writing stats JSON: %v\n", err)
+		}
+	} else if cli.StatsTemplate != "" {
+		tmpl, _ := stats.ParseTemplate(cli.StatsTemplate)
+		if err := st.PrintTemplate(os.Stderr, tmpl); err != nil {
+			fmt.Fprintf(os.Stderr, "Error rendering stats template: %v\n", err)
+		}
+	} else if cli.ShowStatsDetailed {
+		st.PrintDetailed(os.Stderr)
+	} else if cli.ShowStatsOneLine {
+		st.PrintOneLine(os.Stderr)
+	} else if cli.ShowStats {
+		st.Print(os.Stderr)
+	}
+}
+
+// writeStatsJSON writes statistics as JSON to path, or to stderr when it
+// is "-"
+func writeStatsJSON(st *stats.Statistics, path string) error {
+	if path == "-" {
+		return st.PrintJSON(os.Stderr)
+	}
+
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	return st.PrintJSON(f)
+}
+
+// loadFileConfig loads the config file named by --config, or the default
+// one, and applies the --profile selected on the command line
+func loadFileConfig(cli *CLIConfig) (*config.File, error) {
+	var fileConfig *config.File
+	if cli.ConfigFile != "" {
+		var err error
+		fileConfig, err = config.Load(cli.ConfigFile)
+		if err != nil {
+			return nil, err
+		}
+	} else {
+		// Try to load from default location
+		fileConfig = config.LoadOrDefault()
+	}
+
+	if cli.Profile != "" {
+		if err := fileConfig.ApplyProfile(cli.Profile); err != nil {
+			return nil, fmt.Errorf("--profile: %w", err)
+		}
+	}
+	return fileConfig, nil
+}
+
+// mergeConfigs merges file config with CLI config. File values only fill
+// in options whose flags were not set explicitly, so CLI flags always win.
 func mergeConfigs(cli *CLIConfig, file *config.File) {
-	// Only apply file config if CLI flag wasn't explicitly set
-	// This is simplified - in production you'd track which flags were actually set
-	if cli.Mode == "url" && file.Mode != "" {
+	// Core options; the registered-domain preset has already chosen path mode
+	if !cli.flagSet("mode", "m") && !cli.RegDomainPath && file.Mode != "" {
 		cli.Mode = file.Mode
 	}
-	if !cli.FuzzyMode && file.FuzzyMode {
+	if !cli.flagSet("ignore-params", "ip") && len(file.IgnoreParams) > 0 {
+		cli.IgnoreParams = strings.Join(file.IgnoreParams, ",")
+	}
+	if !cli.flagSet("sort-params", "sp") {
+		cli.SortParams = file.SortParams
+	}
+	if !cli.flagSet("ignore-fragment") {
+		cli.IgnoreFragment = file.IgnoreFragment
+	}
+	if !cli.flagSet("case-sensitive") {
+		cli.CaseSensitive = file.CaseSensitive
+	}
+	if !cli.flagSet("keep-www") {
+		cli.KeepWWW = file.KeepWWW
+	}
+	if !cli.flagSet("keep-scheme") {
+		cli.KeepScheme = file.KeepScheme
+	}
+	if !cli.flagSet("trim", "t") {
+		cli.TrimSpaces = file.TrimSpaces
+	}
+
+	// Output options
+	if !cli.flagSet("counts", "c") {
+		cli.PrintCounts = file.PrintCounts
+	}
+	if !cli.flagSet("output", "o") && file.OutputFormat != "" {
+		cli.OutputFormat = file.OutputFormat
+	}
+	if !cli.flagSet("stats", "s") {
+		cli.ShowStats = file.ShowStats
+	}
+	if !cli.flagSet("stats-detailed", "sd") {
+		cli.ShowStatsDetailed = file.ShowStatsDetailed
+	}
+	if !cli.flagSet("verbose", "v") {
+		cli.Verbose = file.Verbose
+	}
+
+	// Advanced normalization
+	if !cli.flagSet("fuzzy", "f") {
 		cli.FuzzyMode = file.FuzzyMode
 	}
-	if cli.Workers == 1 && file.Workers > 0 {
+	if !cli.flagSet("fuzzy-patterns", "fp") && len(file.FuzzyPatterns) > 0 {
+		cli.FuzzyPatterns = strings.Join(file.FuzzyPatterns, ",")
+	}
+	if !cli.flagSet("path-include-query") {
+		cli.PathIncludeQuery = file.PathIncludeQuery
+	}
+	if !cli.flagSet("ignore-extensions", "ie") && len(file.IgnoreExtensions) > 0 {
+		cli.IgnoreExtensions = strings.Join(file.IgnoreExtensions, ",")
+	}
+
+	// Filtering
+	if !cli.flagSet("allow-domains", "ad") && len(file.AllowDomains) > 0 {
+		cli.AllowDomains = strings.Join(file.AllowDomains, ",")
+	}
+	if !cli.flagSet("block-domains", "bd") && len(file.BlockDomains) > 0 {
+		cli.BlockDomains = strings.Join(file.BlockDomains, ",")
+	}
+
+	// Locale handling
+	if !cli.flagSet("locale-priority") && len(file.LocalePriority) > 0 {
+		cli.LocalePriority = strings.Join(file.LocalePriority, ",")
+	}
+	if !cli.flagSet("locale-dedup") {
+		cli.LocaleDedup = file.LocaleDedup
+	}
+
+	// Performance and streaming
+	if !cli.flagSet("workers", "w") && file.Workers > 0 {
 		cli.Workers = file.Workers
 	}
-	// Add more field merging as needed
+	if !cli.flagSet("batch-size") && file.BatchSize > 0 {
+		cli.BatchSize = file.BatchSize
+	}
+	if !cli.flagSet("stream") {
+		cli.Streaming = file.Streaming
+	}
+	if !cli.flagSet("stream-interval") && file.StreamingFlushInterval != "" {
+		cli.StreamingFlushInterval = file.StreamingFlushInterval
+	}
+	if !cli.flagSet("stream-buffer") && file.StreamingMaxBuffer > 0 {
+		cli.StreamingMaxBuffer = file.StreamingMaxBuffer
+	}
 }
 
 // filterByScope filters entries based on scope checker
-func filterByScope(entries []deduplicator.Entry, checker *scope.Checker, showOutOfScope bool) []deduplicator.Entry {
+func filterByScope(entries []deduplicator.Entry, checker *scope.Checker, cli *CLIConfig) []deduplicator.Entry {
 	if checker == nil {
 		return entries
 	}
 
 	filtered := make([]deduplicator.Entry, 0, len(entries))
 	for _, entry := range entries {
-		// Parse URL to extract host
-		u, err := url.Parse(entry.URL)
-		if err != nil {
-			// If can't parse, skip it
+		host := scopeHost(entry, cli)
+		if host == "" {
+			// No host to check against, skip it
 			continue
 		}
 
-		inScope := checker.IsInScope(u.Host)
+		inScope := checker.IsInScope(host)
 
 		// Include based on mode
-		if showOutOfScope {
+		if cli.OutOfScope {
 			// Show only out-of-scope URLs
 			if !inScope {
 				filtered = append(filtered, entry)
@@ -575,14 +1767,14 @@ func filterByScope(entries []deduplicator.Entry, checker *scope.Checker, showOut
 }
 
 // countScopeStats counts in-scope and out-of-scope URLs
-func countScopeStats(entries []deduplicator.Entry, checker *scope.Checker) (inScope, outScope int) {
+func countScopeStats(entries []deduplicator.Entry, checker *scope.Checker, cli *CLIConfig) (inScope, outScope int) {
 	for _, entry := range entries {
-		u, err := url.Parse(entry.URL)
-		if err != nil {
+		host := scopeHost(entry, cli)
+		if host == "" {
 			continue
 		}
 
-		if checker.IsInScope(u.Host) {
+		if checker.IsInScope(host) {
 			inScope++
 		} else {
 			outScope++
@@ -590,3 +1782,12 @@ func countScopeStats(entries []deduplicator.Entry, checker *scope.Checker) (inSc
 	}
 	return
 }
+
+// scopeHost returns the host and port to scope-check an entry against,
+// falling back to --scope-host for entries whose mode drops the host
+func scopeHost(entry deduplicator.Entry, cli *CLIConfig) string {
+	if host := scope.EntryHostPort(entry.URL, cli.Mode); host != "" {
+		return host
+	}
+	return cli.ScopeHost
+}
@@ -0,0 +1,128 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/lcalzada-xor/dupdurl/pkg/config"
+)
+
+func parseTestArgs(t *testing.T, args ...string) *CLIConfig {
+	t.Helper()
+	fs := flag.NewFlagSet("dupdurl", flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	cli, err := parseArgs(fs, args)
+	if err != nil {
+		t.Fatalf("parseArgs(%v) error = %v", args, err)
+	}
+	return cli
+}
+
+func TestMergeConfigsExplicitFlagsWin(t *testing.T) {
+	file := config.DefaultConfig()
+	file.Mode = "path"
+	file.Workers = 8
+	file.OutputFormat = "json"
+	file.IgnoreParams = []string{"utm_source", "fbclid"}
+
+	// -mode=url is the default value, but it was set on purpose
+	cli := parseTestArgs(t, "-mode=url", "-o", "csv")
+	mergeConfigs(cli, file)
+
+	if cli.Mode != "url" {
+		t.Errorf("Mode = %q; want the explicit CLI value url", cli.Mode)
+	}
+	if cli.OutputFormat != "csv" {
+		t.Errorf("OutputFormat = %q; want the explicit CLI value csv (via -o alias)", cli.OutputFormat)
+	}
+
+	// Flags left unset take the file values
+	if cli.Workers != 8 {
+		t.Errorf("Workers = %d; want 8 from the config file", cli.Workers)
+	}
+	if cli.IgnoreParams != "utm_source,fbclid" {
+		t.Errorf("IgnoreParams = %q; want utm_source,fbclid from the config file", cli.IgnoreParams)
+	}
+
+	// Without an explicit flag the file mode wins
+	cli = parseTestArgs(t)
+	mergeConfigs(cli, file)
+	if cli.Mode != "path" {
+		t.Errorf("Mode = %q; want path from the config file", cli.Mode)
+	}
+}
+
+func TestLoadFileConfigProfile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yml")
+	if err := os.WriteFile(path, []byte("workers: 3\nmode: path\n"), 0644); err != nil {
+		t.Fatalf("WriteFile() error = %v", err)
+	}
+
+	cli := parseTestArgs(t, "-config", path, "-profile", "bugbounty", "-w", "2")
+	file, err := loadFileConfig(cli)
+	if err != nil {
+		t.Fatalf("loadFileConfig() error = %v", err)
+	}
+	mergeConfigs(cli, file)
+
+	// The profile overrides the file; explicit flags override the profile
+	if cli.Mode != "url" || !cli.FuzzyMode || !strings.Contains(cli.IgnoreExtensions, "woff2") {
+		t.Errorf("mode = %q, fuzzy = %v, ignore-extensions = %q; want the bugbounty profile settings", cli.Mode, cli.FuzzyMode, cli.IgnoreExtensions)
+	}
+	if cli.Workers != 2 {
+		t.Errorf("Workers = %d; want the explicit CLI value 2 over the profile's 4", cli.Workers)
+	}
+
+	cli = parseTestArgs(t, "-config", path, "-p", "nope")
+	_, err = loadFileConfig(cli)
+	if err == nil || !strings.Contains(err.Error(), "available: aggressive, bugbounty, conservative") {
+		t.Errorf("loadFileConfig() with unknown profile error = %v; want the available profiles listed", err)
+	}
+}
+
+func TestBatchInterruptWritesPartialOutput(t *testing.T) {
+	// Re-run as the dupdurl binary: main() exits, so it needs its own process
+	if os.Getenv("DUPDURL_RUN_MAIN") == "1" {
+		os.Args = []string{"dupdurl"}
+		main()
+		return
+	}
+
+	cmd := exec.Command(os.Args[0], "-test.run=^TestBatchInterruptWritesPartialOutput$")
+	cmd.Env = append(os.Environ(), "DUPDURL_RUN_MAIN=1")
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout, cmd.Stderr = &stdout, &stderr
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		t.Fatalf("StdinPipe() error = %v", err)
+	}
+	defer stdin.Close()
+	if err := cmd.Start(); err != nil {
+		t.Fatalf("Start() error = %v", err)
+	}
+
+	// stdin stays open and idle, as with tail -f, until Ctrl-C
+	io.WriteString(stdin, "https://example.com/a\nhttps://example.com/b\nhttps://example.com/a\n")
+	time.Sleep(500 * time.Millisecond)
+	cmd.Process.Signal(os.Interrupt)
+
+	err = cmd.Wait()
+	var exitErr *exec.ExitError
+	if !errors.As(err, &exitErr) || exitErr.ExitCode() != 130 {
+		t.Fatalf("exit = %v; want status 130 (stderr: %s)", err, stderr.String())
+	}
+	if want := "https://example.com/a\nhttps://example.com/b\n"; stdout.String() != want {
+		t.Errorf("stdout = %q; want the partial results %q", stdout.String(), want)
+	}
+	if !strings.Contains(stderr.String(), "Interrupted") {
+		t.Errorf("stderr = %q; want Interrupted", stderr.String())
+	}
+}
@@ -4,6 +4,8 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"sort"
+	"strings"
 
 	"gopkg.in/yaml.v3"
 )
@@ -37,6 +39,10 @@ type File struct {
 	AllowDomains []string `yaml:"allow-domains"`
 	BlockDomains []string `yaml:"block-domains"`
 
+	// Locale handling
+	LocalePriority []string `yaml:"locale-priority"` // Preferred locales, most preferred first
+	LocaleDedup    bool     `yaml:"locale-dedup"`    // Output each URL in its most preferred locale
+
 	// Performance
 	Workers   int  `yaml:"workers"`
 	BatchSize int  `yaml:"batch-size"`
@@ -60,6 +66,8 @@ type Profile struct {
 	AllowDomains     []string `yaml:"allow-domains"`
 	BlockDomains     []string `yaml:"block-domains"`
 	Workers          int      `yaml:"workers"`
+	LocalePriority   []string `yaml:"locale-priority"`
+	LocaleDedup      bool     `yaml:"locale-dedup"`
 }
 
 // DefaultConfig returns a default configuration
@@ -87,7 +95,7 @@ func DefaultConfig() *File {
 				FuzzyMode: false,
 				Workers:   1,
 			},
-			"bubbounty": {
+			"bugbounty": {
 				Mode:             "url",
 				FuzzyMode:        true,
 				FuzzyPatterns:    []string{"numeric", "uuid"},
@@ -134,7 +142,7 @@ func LoadWithProfile(path, profileName string) (*File, error) {
 func (c *File) ApplyProfile(name string) error {
 	profile, ok := c.Profiles[name]
 	if !ok {
-		return fmt.Errorf("profile not found: %s", name)
+		return fmt.Errorf("profile not found: %s (available: %s)", name, strings.Join(c.ProfileNames(), ", "))
 	}
 
 	// Apply profile settings (profile overrides base config)
@@ -162,10 +170,26 @@ func (c *File) ApplyProfile(name string) error {
 	if profile.Workers > 0 {
 		c.Workers = profile.Workers
 	}
+	if len(profile.LocalePriority) > 0 {
+		c.LocalePriority = profile.LocalePriority
+	}
+	if profile.LocaleDedup {
+		c.LocaleDedup = profile.LocaleDedup
+	}
 
 	return nil
 }
 
+// ProfileNames returns the names of the available profiles, sorted
+func (c *File) ProfileNames() []string {
+	names := make([]string, 0, len(c.Profiles))
+	for name := range c.Profiles {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
 // Save saves configuration to a file
 func (c *File) Save(path string) error {
 	data, err := yaml.Marshal(c)
@@ -1,26 +1,138 @@
 package deduplicator
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
 	"github.com/lcalzada-xor/dupdurl/pkg/locale"
 	"github.com/lcalzada-xor/dupdurl/pkg/stats"
 )
 
 // Entry represents a deduplicated URL with its count
 type Entry struct {
-	URL   string `json:"url"`
-	Count int    `json:"count"`
+	URL    string `json:"url"`
+	Count  int    `json:"count"`
+	Status int    `json:"status,omitempty"` // Most common status annotation (0 = none)
+
+	// Methods lists the HTTP methods seen for the entry, sorted and
+	// comma-separated ("GET,POST"; "" = none). A string keeps Entry comparable.
+	Methods string `json:"methods,omitempty"`
+
+	// Schemes counts the occurrences seen over http and https, when scheme
+	// tracking is on
+	Schemes SchemeCounts `json:"schemes,omitzero"`
+
+	// Variants is the number of distinct concrete variants (query value
+	// combinations) seen for the entry, when variant counting is on
+	Variants int `json:"distinct_variants,omitempty"`
+}
+
+// SchemeCounts counts an entry's occurrences per scheme
+type SchemeCounts struct {
+	HTTP  int `json:"http"`
+	HTTPS int `json:"https"`
+}
+
+// Item is a single observation passed to AddItem
+type Item struct {
+	Key  string // Dedup key used for comparison
+	URL  string // Normalized URL stored for output
+	Host string // Contributing host; prefixed to URL if the key is split per host
+
+	// Example is the concrete (unfuzzed) form of the input, kept as an
+	// example of the entry when example tracking is on
+	Example string
+
+	// Status is the input's status annotation (0 = none)
+	Status int
+
+	// Method is the HTTP method the input was prefixed with ("" = none)
+	Method string
+
+	// Scheme is the input's scheme, counted per entry when not empty
+	Scheme string
+
+	// Line is the input line the item came from, reported with duplicate
+	// events (defaults to URL)
+	Line string
+
+	// CapHost is the host counted against the per-host cap ("" = uncapped)
+	CapHost string
+
+	// Time is the input's capture timestamp, compared when keeping the
+	// newest variant (zero = none)
+	Time time.Time
+}
+
+// Duplicate is an observation that collapsed into an existing entry
+type Duplicate struct {
+	Line           string `json:"line"`
+	Key            string `json:"key"`
+	Representative string `json:"representative"` // First-seen URL for the key
+}
+
+// hostGroup tracks the hosts that contributed to one dedup key
+type hostGroup struct {
+	order    []string
+	variants map[string]*Entry // host -> host-qualified entry
+}
+
+// newestChoice is the variant representing a key when keeping the newest
+type newestChoice struct {
+	url string
+	at  time.Time
 }
 
-// Deduplicator handles URL deduplication
+// localeChoice is the variant representing a key under locale preference
+type localeChoice struct {
+	url    string
+	locale string // "" when the variant has no locale
+}
+
+// variantCounts tracks how often each concrete variant of a key was seen
+type variantCounts struct {
+	order  []string // first-seen order, to break ties
+	counts map[string]int
+}
+
+// Deduplicator handles URL deduplication. It is safe for concurrent use;
+// the Set methods are meant to be called before adding URLs.
 type Deduplicator struct {
+	mu sync.RWMutex
+
 	seen          map[string]string            // dedup key -> first full URL with values
 	counts        map[string]int               // dedup key -> occurrence count
 	order         []string                     // preserve first-appearance order
 	stats         *stats.Statistics
 	localeGroups  map[string]*locale.LocaleGroup // locale-aware grouping
 	grouper       *locale.Grouper
+	detector      *locale.Detector             // custom detector for grouper and locale preference (nil = default)
 	localeAware   bool
 	originalURLs  map[string]string            // dedup key -> original URL before normalization
+	maxHosts      int                          // split keys seen on more hosts than this (0 = never)
+	hosts         map[string]*hostGroup        // dedup key -> contributing hosts
+	maxExamples   int                          // concrete examples kept per key (0 = none)
+	examples      map[string][]string          // dedup key -> distinct examples
+	statuses      map[string]map[int]int       // dedup key -> status -> occurrences
+	methods       map[string]map[string]bool   // dedup key -> HTTP methods seen
+	schemes       map[string]SchemeCounts      // dedup key -> occurrences per scheme
+	maxUnique     int                          // evict low-count keys beyond this many (0 = unbounded)
+	maxPerHost    int                          // unique keys kept per host (0 = unbounded)
+	hostKeys      map[string]int               // capped host -> unique keys kept
+	keyHosts      map[string]string            // dedup key -> capped host
+	mostCommon    bool                         // represent each key by its most common variant
+	countVariants bool                         // report the distinct variants seen per key
+	variants      map[string]*variantCounts    // dedup key -> concrete variant counts
+	keepNewest    bool                         // represent each key by its most recently timestamped variant
+	newest        map[string]newestChoice      // dedup key -> newest variant
+	localeScorer  *locale.Scorer               // represent each key by its preferred-locale variant (nil = off)
+	localeBest    map[string]localeChoice      // dedup key -> preferred-locale variant
+
+	onDuplicate func(Duplicate) // called for each non-first occurrence of a key
 }
 
 // New creates a new Deduplicator instance
@@ -34,6 +146,16 @@ func New(s *stats.Statistics) *Deduplicator {
 		grouper:      nil,
 		localeAware:  false,
 		originalURLs: make(map[string]string),
+		hosts:        make(map[string]*hostGroup),
+		examples:     make(map[string][]string),
+		statuses:     make(map[string]map[int]int),
+		methods:      make(map[string]map[string]bool),
+		schemes:      make(map[string]SchemeCounts),
+		hostKeys:     make(map[string]int),
+		keyHosts:     make(map[string]string),
+		variants:     make(map[string]*variantCounts),
+		localeBest:   make(map[string]localeChoice),
+		newest:       make(map[string]newestChoice),
 	}
 }
 
@@ -52,6 +174,16 @@ func NewWithLocaleSupport(s *stats.Statistics, localePriority []string) *Dedupli
 		grouper:      locale.NewGrouper(localePriority),
 		localeAware:  true,
 		originalURLs: make(map[string]string),
+		hosts:        make(map[string]*hostGroup),
+		examples:     make(map[string][]string),
+		statuses:     make(map[string]map[int]int),
+		methods:      make(map[string]map[string]bool),
+		schemes:      make(map[string]SchemeCounts),
+		hostKeys:     make(map[string]int),
+		keyHosts:     make(map[string]string),
+		variants:     make(map[string]*variantCounts),
+		localeBest:   make(map[string]localeChoice),
+		newest:       make(map[string]newestChoice),
 	}
 }
 
@@ -66,51 +198,410 @@ func (d *Deduplicator) SetLocaleAware(enabled bool, priority []string) {
 	}
 }
 
+// SetLocaleDetector makes locale-aware grouping use detector, e.g. one
+// reading custom locale query params. Call it before adding URLs.
+func (d *Deduplicator) SetLocaleDetector(detector *locale.Detector) {
+	priority := []string{"en"}
+	if d.grouper != nil {
+		priority = d.grouper.Priority
+	}
+	d.detector = detector
+	d.grouper = locale.NewGrouperWithDetector(priority, detector)
+}
+
+// SetKeepNewest represents each key by the variant with the latest
+// Item.Time instead of the first-seen URL. Items without a time never
+// replace a timestamped variant; ties keep the variant seen first.
+func (d *Deduplicator) SetKeepNewest(enabled bool) {
+	d.keepNewest = enabled
+}
+
+// SetLocalePreference represents each key by its variant in the most
+// preferred locale of priority, detected from Item.Line (or Item.URL) with
+// detector (nil = default), instead of the first-seen URL. Unlocalized
+// variants rank below priority locales and above any other locale; ties
+// keep the first seen.
+func (d *Deduplicator) SetLocalePreference(priority []string, detector *locale.Detector) {
+	if detector != nil {
+		d.detector = detector
+	}
+	d.localeScorer = locale.NewScorer(priority)
+}
+
+// SetMaxHostsPerKey enables the cross-host safeguard: a key contributed by
+// more than n distinct hosts is emitted once per host instead of collapsed.
+// Only items added with a Host are tracked.
+func (d *Deduplicator) SetMaxHostsPerKey(n int) {
+	d.maxHosts = n
+}
+
+// SetMaxExamples keeps up to n distinct concrete examples per key, taken
+// from Item.Example, and reports them through Examples
+func (d *Deduplicator) SetMaxExamples(n int) {
+	d.maxExamples = n
+}
+
+// Examples returns the concrete examples tracked for each entry, keyed by
+// the entry URL
+func (d *Deduplicator) Examples() map[string][]string {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	result := make(map[string][]string, len(d.examples))
+	for key, examples := range d.examples {
+		result[d.entryURL(key)] = examples
+	}
+	return result
+}
+
+// SetKeepMostCommon represents each key by the concrete variant seen most
+// often, taken from Item.Example, instead of the first-seen URL. Ties go to
+// the variant seen first. Every distinct variant is counted, so memory grows
+// with the number of distinct inputs.
+func (d *Deduplicator) SetKeepMostCommon(enabled bool) {
+	d.mostCommon = enabled
+}
+
+// SetCountVariants reports the number of distinct concrete variants seen
+// for each key, taken from Item.Example, in Entry.Variants. Like
+// SetKeepMostCommon, memory grows with the number of distinct inputs.
+func (d *Deduplicator) SetCountVariants(enabled bool) {
+	d.countVariants = enabled
+}
+
+// SetMaxUnique caps the number of unique keys held in memory. Once the cap
+// is reached, the lowest-count keys are evicted to make room, oldest first
+// among equal counts. Results are lossy: an evicted key seen again starts
+// over as a new entry.
+func (d *Deduplicator) SetMaxUnique(n int) {
+	d.maxUnique = n
+}
+
+// SetMaxPerHost keeps at most n unique keys per host, the first seen.
+// Items for further keys of a full host are dropped and counted in the
+// statistics; items without a CapHost are never capped.
+func (d *Deduplicator) SetMaxPerHost(n int) {
+	d.maxPerHost = n
+}
+
+// SetOnDuplicate registers fn to be called for every occurrence of an
+// already seen key, with the entry it collapsed into. Pass nil to stop.
+// fn runs while the deduplicator is locked and must not call back into it.
+func (d *Deduplicator) SetOnDuplicate(fn func(Duplicate)) {
+	d.onDuplicate = fn
+}
+
 // Add adds a URL to the deduplicator
 // dedupKey is used for comparison, normalizedURL is stored for output
 func (d *Deduplicator) Add(dedupKey, normalizedURL string) {
+	d.AddItem(Item{Key: dedupKey, URL: normalizedURL})
+}
+
+// AddItem adds a single observation to the deduplicator
+func (d *Deduplicator) AddItem(item Item) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.add(item, item.URL)
+}
+
+// add is AddItem with the lock held, recording original as the URL the
+// item's key was first seen as before normalization
+func (d *Deduplicator) add(item Item, original string) {
 	// Standard deduplication logic
-	if _, exists := d.seen[dedupKey]; !exists {
-		d.seen[dedupKey] = normalizedURL
-		d.order = append(d.order, dedupKey)
-		d.originalURLs[dedupKey] = normalizedURL
+	if _, exists := d.seen[item.Key]; !exists {
+		if d.hostFull(item.CapHost) {
+			if d.stats != nil {
+				d.stats.RecordHostCapped()
+			}
+			return
+		}
+		if d.maxUnique > 0 && len(d.order) >= d.maxUnique {
+			d.evict()
+		}
+		if d.maxPerHost > 0 && item.CapHost != "" {
+			d.hostKeys[item.CapHost]++
+			d.keyHosts[item.Key] = item.CapHost
+		}
+		d.seen[item.Key] = item.URL
+		d.order = append(d.order, item.Key)
+		d.originalURLs[item.Key] = original
 		if d.stats != nil {
-			d.stats.UniqueURLs++
+			d.stats.RecordUnique()
 		}
 	} else {
 		if d.stats != nil {
-			d.stats.Duplicates++
+			d.stats.RecordDuplicate()
+		}
+		line := item.Line
+		if line == "" {
+			line = item.URL
+		}
+		d.reportDuplicate(item.Key, line)
+	}
+	d.counts[item.Key]++
+
+	if d.maxHosts > 0 && item.Host != "" {
+		d.trackHost(item)
+	}
+
+	if d.maxExamples > 0 && item.Example != "" {
+		d.trackExample(item)
+	}
+
+	if (d.mostCommon || d.countVariants) && item.Example != "" {
+		d.trackVariant(item)
+	}
+
+	if d.keepNewest {
+		if best, ok := d.newest[item.Key]; !ok || item.Time.After(best.at) {
+			d.newest[item.Key] = newestChoice{url: item.URL, at: item.Time}
+		}
+	}
+
+	if d.localeScorer != nil {
+		d.trackLocale(item)
+	}
+
+	if item.Status != 0 {
+		counts, ok := d.statuses[item.Key]
+		if !ok {
+			counts = make(map[int]int)
+			d.statuses[item.Key] = counts
+		}
+		counts[item.Status]++
+	}
+
+	if item.Method != "" {
+		seen, ok := d.methods[item.Key]
+		if !ok {
+			seen = make(map[string]bool)
+			d.methods[item.Key] = seen
+		}
+		seen[item.Method] = true
+	}
+
+	if item.Scheme != "" {
+		counts := d.schemes[item.Key]
+		switch strings.ToLower(item.Scheme) {
+		case "http":
+			counts.HTTP++
+		case "https":
+			counts.HTTPS++
 		}
+		d.schemes[item.Key] = counts
 	}
-	d.counts[dedupKey]++
 }
 
-// AddWithOriginal adds a URL with both normalized and original versions
+// hostFull reports whether a host already has maxPerHost unique keys
+func (d *Deduplicator) hostFull(host string) bool {
+	return d.maxPerHost > 0 && host != "" && d.hostKeys[host] >= d.maxPerHost
+}
+
+// reportDuplicate passes a duplicate event to the registered handler
+func (d *Deduplicator) reportDuplicate(key, line string) {
+	if d.onDuplicate != nil {
+		d.onDuplicate(Duplicate{Line: line, Key: key, Representative: d.seen[key]})
+	}
+}
+
+// status returns the most common status seen for a key, preferring the
+// higher code on ties so errors are not hidden behind successes
+func (d *Deduplicator) status(key string) int {
+	best, bestCount := 0, 0
+	for status, count := range d.statuses[key] {
+		if count > bestCount || (count == bestCount && status > best) {
+			best, bestCount = status, count
+		}
+	}
+	return best
+}
+
+// methodList returns the HTTP methods seen for a key, sorted and
+// comma-separated
+func (d *Deduplicator) methodList(key string) string {
+	seen := d.methods[key]
+	if len(seen) == 0 {
+		return ""
+	}
+
+	methods := make([]string, 0, len(seen))
+	for method := range seen {
+		methods = append(methods, method)
+	}
+	sort.Strings(methods)
+	return strings.Join(methods, ",")
+}
+
+// evict drops the lowest-count keys to make room under maxUnique. About a
+// tenth of the cap is evicted at once so the sort is amortized across many
+// inserts.
+func (d *Deduplicator) evict() {
+	n := d.maxUnique / 10
+	if n < 1 {
+		n = 1
+	}
+	if excess := len(d.order) - d.maxUnique + 1; excess > n {
+		n = excess
+	}
+
+	byCount := make([]string, len(d.order))
+	copy(byCount, d.order)
+	sort.SliceStable(byCount, func(i, j int) bool {
+		return d.counts[byCount[i]] < d.counts[byCount[j]]
+	})
+
+	evicted := make(map[string]bool, n)
+	for _, key := range byCount[:n] {
+		evicted[key] = true
+		delete(d.seen, key)
+		delete(d.counts, key)
+		delete(d.originalURLs, key)
+		delete(d.hosts, key)
+		delete(d.examples, key)
+		delete(d.statuses, key)
+		delete(d.methods, key)
+		delete(d.schemes, key)
+		delete(d.variants, key)
+		delete(d.localeBest, key)
+		delete(d.newest, key)
+		if host, ok := d.keyHosts[key]; ok {
+			d.hostKeys[host]--
+			delete(d.keyHosts, key)
+		}
+	}
+
+	kept := d.order[:0]
+	for _, key := range d.order {
+		if !evicted[key] {
+			kept = append(kept, key)
+		}
+	}
+	d.order = kept
+
+	if d.stats != nil {
+		d.stats.RecordEvicted(n)
+	}
+}
+
+// trackExample records a distinct concrete example of an item's key
+func (d *Deduplicator) trackExample(item Item) {
+	examples := d.examples[item.Key]
+	if len(examples) >= d.maxExamples {
+		return
+	}
+	for _, example := range examples {
+		if example == item.Example {
+			return
+		}
+	}
+	d.examples[item.Key] = append(examples, item.Example)
+}
+
+// trackVariant counts a concrete variant of an item's key
+func (d *Deduplicator) trackVariant(item Item) {
+	vc, ok := d.variants[item.Key]
+	if !ok {
+		vc = &variantCounts{counts: make(map[string]int)}
+		d.variants[item.Key] = vc
+	}
+	if vc.counts[item.Example] == 0 {
+		vc.order = append(vc.order, item.Example)
+	}
+	vc.counts[item.Example]++
+}
+
+// trackLocale keeps an item's URL as its key's representative when its
+// locale is preferred over the current one
+func (d *Deduplicator) trackLocale(item Item) {
+	line := item.Line
+	if line == "" {
+		line = item.URL
+	}
+
+	detector := d.detector
+	if detector == nil {
+		detector = locale.NewDetector()
+		d.detector = detector
+	}
+	var loc string
+	if localized, err := detector.Detect(strings.TrimSpace(line)); err == nil && localized.LocaleType != locale.LocaleTypeNone {
+		loc = localized.Locale
+	}
+
+	best, ok := d.localeBest[item.Key]
+	if !ok || d.localeScorer.ComparePriority(loc, best.locale) < 0 {
+		d.localeBest[item.Key] = localeChoice{url: item.URL, locale: loc}
+	}
+}
+
+// entryURL returns the URL that represents a key in the output
+func (d *Deduplicator) entryURL(key string) string {
+	vc, ok := d.variants[key]
+	if !ok || !d.mostCommon {
+		if choice, ok := d.newest[key]; ok {
+			return choice.url
+		}
+		if choice, ok := d.localeBest[key]; ok {
+			return choice.url
+		}
+		return d.seen[key]
+	}
+
+	best, bestCount := d.seen[key], 0
+	for _, variant := range vc.order {
+		if vc.counts[variant] > bestCount {
+			best, bestCount = variant, vc.counts[variant]
+		}
+	}
+	return best
+}
+
+// variantCount returns the number of distinct variants seen for a key, or
+// 0 when variant counting is off
+func (d *Deduplicator) variantCount(key string) int {
+	if !d.countVariants {
+		return 0
+	}
+	if vc, ok := d.variants[key]; ok {
+		return len(vc.order)
+	}
+	return 0
+}
+
+// trackHost records the contributing host of an item
+func (d *Deduplicator) trackHost(item Item) {
+	group, ok := d.hosts[item.Key]
+	if !ok {
+		group = &hostGroup{variants: make(map[string]*Entry)}
+		d.hosts[item.Key] = group
+	}
+
+	variant, ok := group.variants[item.Host]
+	if !ok {
+		variant = &Entry{URL: item.Host + item.URL}
+		group.variants[item.Host] = variant
+		group.order = append(group.order, item.Host)
+	}
+	variant.Count++
+}
+
+// AddWithOriginal adds a URL with both normalized and original versions,
+// under the same caps as AddItem
 func (d *Deduplicator) AddWithOriginal(dedupKey, normalizedURL, originalURL string) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
 	// If locale-aware mode is enabled, also track in grouper
 	if d.localeAware && d.grouper != nil {
 		// Add original URL to locale grouper
 		d.grouper.Add(originalURL)
 	}
 
-	// Standard deduplication logic
-	if _, exists := d.seen[dedupKey]; !exists {
-		d.seen[dedupKey] = normalizedURL
-		d.order = append(d.order, dedupKey)
-		d.originalURLs[dedupKey] = originalURL
-		if d.stats != nil {
-			d.stats.UniqueURLs++
-		}
-	} else {
-		if d.stats != nil {
-			d.stats.Duplicates++
-		}
-	}
-	d.counts[dedupKey]++
+	d.add(Item{Key: dedupKey, URL: normalizedURL, Line: originalURL}, originalURL)
 }
 
 // GetEntries returns all deduplicated entries in first-seen order
 func (d *Deduplicator) GetEntries() []Entry {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
 	// If locale-aware mode is enabled, get best URLs from grouper
 	if d.localeAware && d.grouper != nil {
 		bestURLs := d.grouper.GetBestURLs()
@@ -137,37 +628,111 @@ func (d *Deduplicator) GetEntries() []Entry {
 	}
 
 	// Standard mode: return all entries
-	entries := make([]Entry, len(d.order))
-	for i, key := range d.order {
-		entries[i] = Entry{
-			URL:   d.seen[key],
-			Count: d.counts[key],
+	entries := make([]Entry, 0, len(d.order))
+	for _, key := range d.order {
+		// Coincidental collisions across many hosts are not collapsed
+		if group, ok := d.hosts[key]; ok && len(group.order) > d.maxHosts {
+			for _, host := range group.order {
+				entries = append(entries, *group.variants[host])
+			}
+			continue
 		}
+
+		entries = append(entries, Entry{
+			URL:      d.entryURL(key),
+			Count:    d.counts[key],
+			Status:   d.status(key),
+			Methods:  d.methodList(key),
+			Schemes:  d.schemes[key],
+			Variants: d.variantCount(key),
+		})
 	}
 	return entries
 }
 
 // Count returns the number of unique entries
 func (d *Deduplicator) Count() int {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
 	return len(d.order)
 }
 
+// FingerprintKeys returns a stable SHA-256 hash of the sorted set of dedup
+// keys behind entries, typically GetEntries after scope or baseline
+// filtering. Hashing keys rather than the printed URLs keeps the fingerprint
+// independent of input order, so comparing fingerprints across runs is an
+// O(1) "did anything change" check. Entries without a known key, such as
+// hosts split by the cross-host safeguard, count by their URL.
+func (d *Deduplicator) FingerprintKeys(entries []Entry) string {
+	d.mu.RLock()
+	keyOf := make(map[string]string, len(d.order))
+	for _, key := range d.order {
+		keyOf[d.seen[key]] = key
+		keyOf[d.entryURL(key)] = key
+	}
+	d.mu.RUnlock()
+
+	seen := make(map[string]bool, len(entries))
+	keys := make([]string, 0, len(entries))
+	for _, entry := range entries {
+		key, ok := keyOf[entry.URL]
+		if !ok {
+			key = entry.URL
+		}
+		if !seen[key] {
+			seen[key] = true
+			keys = append(keys, key)
+		}
+	}
+	return fingerprint(keys)
+}
+
+// fingerprint hashes values in sorted order, one per line. It sorts values
+// in place.
+func fingerprint(values []string) string {
+	sort.Strings(values)
+
+	h := sha256.New()
+	for _, value := range values {
+		h.Write([]byte(value))
+		h.Write([]byte{'\n'})
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
 // Clear resets the deduplicator state
 func (d *Deduplicator) Clear() {
+	d.mu.Lock()
+	defer d.mu.Unlock()
 	d.seen = make(map[string]string)
 	d.counts = make(map[string]int)
 	d.order = make([]string, 0)
 	d.localeGroups = make(map[string]*locale.LocaleGroup)
 	d.originalURLs = make(map[string]string)
+	d.hosts = make(map[string]*hostGroup)
+	d.examples = make(map[string][]string)
+	d.statuses = make(map[string]map[int]int)
+	d.methods = make(map[string]map[string]bool)
+	d.schemes = make(map[string]SchemeCounts)
+	d.hostKeys = make(map[string]int)
+	d.keyHosts = make(map[string]string)
+	d.variants = make(map[string]*variantCounts)
+	d.localeBest = make(map[string]localeChoice)
+	d.newest = make(map[string]newestChoice)
 	if d.localeAware && d.grouper != nil {
 		// Reset grouper
 		priority := d.grouper.Priority
 		d.grouper = locale.NewGrouper(priority)
+		if d.detector != nil {
+			d.grouper = locale.NewGrouperWithDetector(priority, d.detector)
+		}
 	}
 }
 
 // GetLocaleGroups returns locale groups for debugging/stats
 func (d *Deduplicator) GetLocaleGroups() map[string]*locale.LocaleGroup {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
 	if d.grouper != nil {
 		return d.grouper.GetGroups()
 	}
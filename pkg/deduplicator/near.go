@@ -0,0 +1,63 @@
+package deduplicator
+
+// ClusterNear merges entries whose URLs are within maxDistance edits
+// (Levenshtein) of an earlier entry, keeping the first entry of each cluster
+// as its representative and summing the counts. Every entry is compared
+// against every representative, so the pass is skipped (returning entries
+// unchanged and false) when there are more than maxCandidates entries.
+func ClusterNear(entries []Entry, maxDistance, maxCandidates int) ([]Entry, bool) {
+	if maxCandidates > 0 && len(entries) > maxCandidates {
+		return entries, false
+	}
+
+	clusters := make([]Entry, 0, len(entries))
+	for _, entry := range entries {
+		merged := false
+		for i := range clusters {
+			if withinDistance(clusters[i].URL, entry.URL, maxDistance) {
+				clusters[i].Count += entry.Count
+				merged = true
+				break
+			}
+		}
+		if !merged {
+			clusters = append(clusters, entry)
+		}
+	}
+	return clusters, true
+}
+
+// withinDistance reports whether the Levenshtein distance between a and b
+// is at most max, giving up as soon as every path exceeds it
+func withinDistance(a, b string, max int) bool {
+	if len(a) > len(b) {
+		a, b = b, a
+	}
+	if len(b)-len(a) > max {
+		return false
+	}
+
+	prev := make([]int, len(a)+1)
+	curr := make([]int, len(a)+1)
+	for i := range prev {
+		prev[i] = i
+	}
+
+	for j := 1; j <= len(b); j++ {
+		curr[0] = j
+		rowMin := curr[0]
+		for i := 1; i <= len(a); i++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			curr[i] = min(prev[i]+1, curr[i-1]+1, prev[i-1]+cost)
+			rowMin = min(rowMin, curr[i])
+		}
+		if rowMin > max {
+			return false
+		}
+		prev, curr = curr, prev
+	}
+	return prev[len(a)] <= max
+}
@@ -1,31 +1,53 @@
 package diff
 
 import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
 	"encoding/json"
 	"fmt"
 	"io"
 	"os"
+	"strconv"
+	"strings"
 
 	"github.com/lcalzada-xor/dupdurl/pkg/deduplicator"
 )
 
+// SchemaVersion identifies the JSON shape of DiffReport. It changes only
+// when a field is removed or its meaning changes.
+const SchemaVersion = "dupdurl.diff.v1"
+
 // DiffReport represents the differences between two URL sets
 type DiffReport struct {
+	Schema  string   `json:"schema"`
 	Added   []string `json:"added"`
 	Removed []string `json:"removed"`
 	Changed []Change `json:"changed"`
 }
 
+// WebhookPayload is a compact summary of newly added URLs suitable for
+// posting to chat webhooks
+type WebhookPayload struct {
+	Added []string `json:"added"`
+	Count int      `json:"count"`
+}
+
 // Change represents a URL that exists in both sets but with different counts
 type Change struct {
 	URL      string `json:"url"`
 	OldCount int    `json:"old_count"`
 	NewCount int    `json:"new_count"`
+	Delta    int    `json:"delta"` // NewCount - OldCount
 }
 
 // Differ compares URL sets
 type Differ struct {
 	baseline map[string]int // URL -> count
+
+	// IgnoreCounts reports only added/removed URLs, skipping count changes.
+	// Text baselines carry no counts, so loading one enables it.
+	IgnoreCounts bool
 }
 
 // NewDiffer creates a new Differ instance
@@ -42,16 +64,154 @@ func (d *Differ) LoadBaseline(path string) error {
 		return fmt.Errorf("failed to read baseline file: %w", err)
 	}
 
-	var entries []deduplicator.Entry
-	if err := json.Unmarshal(data, &entries); err != nil {
-		return fmt.Errorf("failed to parse baseline JSON: %w", err)
+	entries, err := parseBaselineJSON(data)
+	if err != nil {
+		return err
+	}
+
+	d.addEntries(entries)
+	return nil
+}
+
+// LoadBaselineText loads baseline URLs from a plain text file with one URL
+// per line. Blank lines and lines starting with # are skipped.
+func (d *Differ) LoadBaselineText(path string) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("failed to read baseline file: %w", err)
+	}
+	defer file.Close()
+
+	entries, err := parseBaselineLines(file)
+	if err != nil {
+		return err
 	}
 
+	d.addTextEntries(entries)
+	return nil
+}
+
+// LoadBaselineFile loads a baseline in any format ReadBaseline accepts.
+// A plain URL list carries no counts, so it enables IgnoreCounts.
+func (d *Differ) LoadBaselineFile(path string) error {
+	entries, counted, err := readBaseline(path)
+	if err != nil {
+		return err
+	}
+
+	if counted {
+		d.addEntries(entries)
+	} else {
+		d.addTextEntries(entries)
+	}
+	return nil
+}
+
+// ReadBaseline reads baseline entries from a JSON file, from CSV output
+// (-o csv or --counts-file) or from a plain text URL list. Entries read
+// from a text baseline have a zero count.
+func ReadBaseline(path string) ([]deduplicator.Entry, error) {
+	entries, _, err := readBaseline(path)
+	return entries, err
+}
+
+// readBaseline reads a baseline file once and parses it by format: JSON is
+// detected by its leading '[', CSV by its url,count header, and anything
+// else is a plain URL list. counted is false for plain lists.
+func readBaseline(path string) (entries []deduplicator.Entry, counted bool, err error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to read baseline file: %w", err)
+	}
+
+	switch {
+	case isJSONBaseline(data):
+		entries, err = parseBaselineJSON(data)
+		return entries, true, err
+	case isCSVBaseline(data):
+		entries, err = parseBaselineCSV(data)
+		return entries, true, err
+	}
+	entries, err = parseBaselineLines(bytes.NewReader(data))
+	return entries, false, err
+}
+
+func (d *Differ) addEntries(entries []deduplicator.Entry) {
 	for _, entry := range entries {
 		d.baseline[entry.URL] = entry.Count
 	}
+}
 
-	return nil
+func (d *Differ) addTextEntries(entries []deduplicator.Entry) {
+	d.addEntries(entries)
+
+	// Counts are unknown, so only presence can be compared
+	d.IgnoreCounts = true
+}
+
+func isJSONBaseline(data []byte) bool {
+	trimmed := bytes.TrimSpace(data)
+	return len(trimmed) > 0 && trimmed[0] == '['
+}
+
+// isCSVBaseline reports whether data starts with the url,count header that
+// CSV output writes, possibly followed by --csv-columns
+func isCSVBaseline(data []byte) bool {
+	header, _, _ := bytes.Cut(bytes.TrimLeft(data, " \t\r\n"), []byte("\n"))
+	header = bytes.TrimSpace(header)
+	return bytes.Equal(header, []byte("url,count")) || bytes.HasPrefix(header, []byte("url,count,"))
+}
+
+// parseBaselineCSV reads url and count from each row after the header. The
+// CSV reader undoes the quoting of URLs that contain commas.
+func parseBaselineCSV(data []byte) ([]deduplicator.Entry, error) {
+	reader := csv.NewReader(bytes.NewReader(data))
+	reader.FieldsPerRecord = -1
+
+	records, err := reader.ReadAll()
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse baseline CSV: %w", err)
+	}
+
+	entries := make([]deduplicator.Entry, 0, len(records))
+	for i, record := range records[1:] {
+		if len(record) < 2 || record[0] == "" {
+			continue
+		}
+		count, err := strconv.Atoi(record[1])
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse baseline CSV: row %d: invalid count %q", i+2, record[1])
+		}
+		entries = append(entries, deduplicator.Entry{URL: record[0], Count: count})
+	}
+	return entries, nil
+}
+
+func parseBaselineJSON(data []byte) ([]deduplicator.Entry, error) {
+	var entries []deduplicator.Entry
+	if err := json.Unmarshal(data, &entries); err != nil {
+		return nil, fmt.Errorf("failed to parse baseline JSON: %w", err)
+	}
+	return entries, nil
+}
+
+func parseBaselineLines(r io.Reader) ([]deduplicator.Entry, error) {
+	var entries []deduplicator.Entry
+
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		entries = append(entries, deduplicator.Entry{URL: line})
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read baseline file: %w", err)
+	}
+
+	return entries, nil
 }
 
 // LoadBaselineFromEntries loads baseline from entry slice
@@ -65,6 +225,7 @@ func (d *Differ) LoadBaselineFromEntries(entries []deduplicator.Entry) {
 // Compare compares current entries against baseline
 func (d *Differ) Compare(current []deduplicator.Entry) *DiffReport {
 	report := &DiffReport{
+		Schema:  SchemaVersion,
 		Added:   []string{},
 		Removed: []string{},
 		Changed: []Change{},
@@ -85,11 +246,12 @@ func (d *Differ) Compare(current []deduplicator.Entry) *DiffReport {
 			seen[entry.URL] = struct{}{}
 
 			// Check if count changed
-			if entry.Count != oldCount {
+			if !d.IgnoreCounts && entry.Count != oldCount {
 				report.Changed = append(report.Changed, Change{
 					URL:      entry.URL,
 					OldCount: oldCount,
 					NewCount: entry.Count,
+					Delta:    entry.Count - oldCount,
 				})
 			}
 		}
@@ -105,6 +267,19 @@ func (d *Differ) Compare(current []deduplicator.Entry) *DiffReport {
 	return report
 }
 
+// FilterNew returns the entries whose URL is absent from the baseline, in
+// their original order. Baselines loaded one after another are merged, so
+// loading several filters against their union.
+func (d *Differ) FilterNew(entries []deduplicator.Entry) []deduplicator.Entry {
+	fresh := make([]deduplicator.Entry, 0, len(entries))
+	for _, entry := range entries {
+		if _, existed := d.baseline[entry.URL]; !existed {
+			fresh = append(fresh, entry)
+		}
+	}
+	return fresh
+}
+
 // PrintReport prints a human-readable diff report
 func (r *DiffReport) PrintReport(w io.Writer) {
 	if len(r.Added) > 0 {
@@ -144,6 +319,38 @@ func (r *DiffReport) Summary() string {
 		len(r.Added), len(r.Removed), len(r.Changed))
 }
 
+// PrintSummary prints only the one-line summary, for dashboards that don't
+// want the full report
+func (r *DiffReport) PrintSummary(w io.Writer) {
+	fmt.Fprintln(w, r.Summary())
+}
+
+// Webhook returns the compact "new endpoints" payload for the report
+func (r *DiffReport) Webhook() WebhookPayload {
+	added := r.Added
+	if added == nil {
+		added = []string{}
+	}
+	return WebhookPayload{
+		Added: added,
+		Count: len(added),
+	}
+}
+
+// SaveWebhookJSON writes the compact webhook payload to a file
+func (r *DiffReport) SaveWebhookJSON(path string) error {
+	data, err := json.Marshal(r.Webhook())
+	if err != nil {
+		return fmt.Errorf("failed to marshal webhook payload: %w", err)
+	}
+
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		return fmt.Errorf("failed to write webhook file: %w", err)
+	}
+
+	return nil
+}
+
 // SaveBaseline saves current entries as baseline JSON file
 func SaveBaseline(entries []deduplicator.Entry, path string) error {
 	data, err := json.MarshalIndent(entries, "", "  ")
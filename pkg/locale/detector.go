@@ -1,6 +1,8 @@
 package locale
 
 import (
+	"fmt"
+	"net"
 	"net/url"
 	"regexp"
 	"strings"
@@ -52,16 +54,59 @@ var localeCodes = map[string]bool{
 	"wa": true, "wo": true, "xh": true, "yi": true, "yo": true, "za": true, "zh": true, "zu": true,
 }
 
-// Extended locale codes (language-region combinations like en-US, es-MX, en-us, es-mx)
-var extendedLocaleRegex = regexp.MustCompile(`^[a-z]{2}-[a-zA-Z]{2}$`)
+// Extended locale codes: language-region (en-US, es-mx) or language-script
+// (zh-Hans, sr-latn) combinations, as used in hreflang
+var extendedLocaleRegex = regexp.MustCompile(`^([a-z]{2})-([a-zA-Z]{2}|[a-zA-Z]{4})$`)
 
-// Common query parameter names for locale
-var localeQueryParams = []string{"lang", "locale", "language", "hl", "l"}
+// ISO 15924 script subtags commonly found in hreflang values
+var scriptSubtags = map[string]bool{
+	"arab": true, "beng": true, "cyrl": true, "deva": true, "ethi": true,
+	"geor": true, "grek": true, "guru": true, "hans": true, "hant": true,
+	"hebr": true, "jpan": true, "khmr": true, "kore": true, "latn": true,
+	"mong": true, "taml": true, "telu": true, "thai": true, "tibt": true,
+}
+
+// isExtendedLocale checks for a known language followed by a two-letter
+// region or a known four-letter script subtag
+func isExtendedLocale(code string) bool {
+	match := extendedLocaleRegex.FindStringSubmatch(strings.ToLower(code))
+	if match == nil || !localeCodes[match[1]] {
+		return false
+	}
+	if len(match[2]) == 4 {
+		return scriptSubtags[match[2]]
+	}
+	return true
+}
+
+// Common query parameter names for locale. The single-letter "l" is only
+// read with Detector.SingleLetterParam, as it often means something else
+// (?l=5 for a limit).
+var localeQueryParams = []string{"lang", "locale", "language", "hl"}
 
 // Detector handles locale detection in URLs
 type Detector struct {
 	// Context-based detection to avoid false positives
 	contextAware bool
+
+	// ScanAllSegments also looks for a locale deeper than the second path
+	// segment (/docs/help/en/article). Mid-path candidates get stricter
+	// context checks than prefix ones.
+	ScanAllSegments bool
+
+	// Aliases maps nonstandard locale codes to canonical ones (us -> en,
+	// br -> pt). Keys are lowercase; an alias wins over a standard code of
+	// the same name and skips the context checks.
+	Aliases map[string]string
+
+	// SingleLetterParam also reads the locale from the "l" query param
+	// (?l=en). Off by default since ?l= is often a limit or a letter.
+	SingleLetterParam bool
+
+	// CustomQueryParams are extra query param names read for a locale
+	// (culture, ui-lang), checked after the default ones. Names are
+	// matched case-sensitively, like the defaults.
+	CustomQueryParams []string
 }
 
 // NewDetector creates a new locale detector
@@ -71,6 +116,19 @@ func NewDetector() *Detector {
 	}
 }
 
+// NewDetectorWithParams creates a locale detector that also reads the
+// locale from the given query params, on top of the default ones. Names
+// are trimmed and lowercased; empty ones are skipped.
+func NewDetectorWithParams(params []string) *Detector {
+	d := NewDetector()
+	for _, param := range params {
+		if param = strings.ToLower(strings.TrimSpace(param)); param != "" {
+			d.CustomQueryParams = append(d.CustomQueryParams, param)
+		}
+	}
+	return d
+}
+
 // Detect analyzes a URL and extracts locale information
 func (d *Detector) Detect(rawURL string) (*LocalizedURL, error) {
 	u, err := url.Parse(rawURL)
@@ -101,10 +159,10 @@ func (d *Detector) Detect(rawURL string) (*LocalizedURL, error) {
 	}
 
 	// Priority 3: Check query parameters
-	if locale := d.detectQueryParam(u.Query()); locale != "" {
+	if locale, param := d.detectQueryParam(u.Query()); locale != "" {
 		result.Locale = locale
 		result.LocaleType = LocaleTypeQuery
-		result.BaseURL = d.removeQueryLocale(rawURL, u, locale)
+		result.BaseURL = d.removeQueryLocale(u, locale, param)
 		return result, nil
 	}
 
@@ -115,21 +173,31 @@ func (d *Detector) Detect(rawURL string) (*LocalizedURL, error) {
 
 // detectSubdomain checks if the subdomain is a locale code
 func (d *Detector) detectSubdomain(host string) string {
-	parts := strings.Split(host, ".")
-	if len(parts) < 2 {
+	// Raw IPs have no subdomains; en.192.168.1.1 is not a localized IP either
+	name := (&url.URL{Host: host}).Hostname()
+	if net.ParseIP(name) != nil {
+		return ""
+	}
+
+	parts := strings.Split(name, ".")
+	if len(parts) < 2 || net.ParseIP(strings.Join(parts[1:], ".")) != nil {
 		return ""
 	}
 
 	firstPart := strings.ToLower(parts[0])
 
+	if canonical, ok := d.Aliases[firstPart]; ok {
+		return canonical
+	}
+
 	// Check if it's a valid locale code
 	if localeCodes[firstPart] {
 		return firstPart
 	}
 
-	// Check extended format (en-us, es-mx)
-	if extendedLocaleRegex.MatchString(firstPart) {
-		return strings.ToLower(firstPart)
+	// Check extended format (en-us, es-mx, zh-hans)
+	if isExtendedLocale(firstPart) {
+		return firstPart
 	}
 
 	return ""
@@ -158,15 +226,59 @@ func (d *Detector) detectPathPrefix(path string) (string, int) {
 		}
 	}
 
+	// Optionally check the remaining segments (for /docs/help/en/article)
+	if d.ScanAllSegments {
+		for i := 2; i < len(segments); i++ {
+			if !d.plausibleMidPathLocale(segments, i) {
+				continue
+			}
+			if locale := d.validatePathSegmentAsLocale(segments[i], segments, i); locale != "" {
+				return locale, i
+			}
+		}
+	}
+
 	return "", -1
 }
 
+// Segments that make a following two-letter segment unlikely to be a locale
+var (
+	versionSegmentRegex = regexp.MustCompile(`^v\d+(\.\d+)*$`)
+	idSegmentRegex      = regexp.MustCompile(`^(\d+|[0-9a-f]{8,})$`)
+)
+
+// plausibleMidPathLocale applies the extra context checks for a locale
+// found past the second path segment: it must be followed by more path,
+// must not sit under an API or version prefix, and must not follow an ID
+// (/users/123/hr is a sub-resource, not Croatian)
+func (d *Detector) plausibleMidPathLocale(segments []string, position int) bool {
+	if position >= len(segments)-1 {
+		return false
+	}
+
+	if strings.ToLower(segments[0]) == "api" {
+		return false
+	}
+
+	for _, seg := range segments[:position] {
+		if versionSegmentRegex.MatchString(strings.ToLower(seg)) {
+			return false
+		}
+	}
+
+	return !idSegmentRegex.MatchString(strings.ToLower(segments[position-1]))
+}
+
 // validatePathSegmentAsLocale checks if a path segment is a locale with context awareness
 func (d *Detector) validatePathSegmentAsLocale(segment string, allSegments []string, position int) string {
 	segment = strings.ToLower(segment)
 
+	if canonical, ok := d.Aliases[segment]; ok {
+		return canonical
+	}
+
 	// Basic check: is it a locale code?
-	isLocale := localeCodes[segment] || extendedLocaleRegex.MatchString(segment)
+	isLocale := localeCodes[segment] || isExtendedLocale(segment)
 	if !isLocale {
 		return ""
 	}
@@ -174,7 +286,7 @@ func (d *Detector) validatePathSegmentAsLocale(segment string, allSegments []str
 	// Context awareness to avoid false positives
 	if d.contextAware {
 		// Don't treat as locale if it's part of a word
-		if strings.Contains(segment, "-") && !extendedLocaleRegex.MatchString(segment) {
+		if strings.Contains(segment, "-") && !isExtendedLocale(segment) {
 			return ""
 		}
 
@@ -218,17 +330,49 @@ func (d *Detector) validatePathSegmentAsLocale(segment string, allSegments []str
 	return segment
 }
 
-// detectQueryParam checks query parameters for locale
-func (d *Detector) detectQueryParam(query url.Values) string {
-	for _, param := range localeQueryParams {
+// detectQueryParam checks query parameters for locale, returning it along
+// with the param it was read from
+func (d *Detector) detectQueryParam(query url.Values) (string, string) {
+	for _, param := range d.queryParams() {
 		if val := query.Get(param); val != "" {
-			val = strings.ToLower(val)
-			if localeCodes[val] || extendedLocaleRegex.MatchString(val) {
-				return val
+			val = normalizeLocaleValue(val)
+			if canonical, ok := d.Aliases[val]; ok {
+				return canonical, param
+			}
+			if localeCodes[val] || isExtendedLocale(val) {
+				return val, param
 			}
 		}
 	}
-	return ""
+	return "", ""
+}
+
+// queryParams returns the query param names read for a locale
+func (d *Detector) queryParams() []string {
+	if !d.SingleLetterParam && len(d.CustomQueryParams) == 0 {
+		return localeQueryParams
+	}
+
+	params := append([]string(nil), localeQueryParams...)
+	if d.SingleLetterParam {
+		params = append(params, "l")
+	}
+	return append(params, d.CustomQueryParams...)
+}
+
+// canonicalValue normalizes a locale value and resolves it through Aliases
+func (d *Detector) canonicalValue(val string) string {
+	val = normalizeLocaleValue(val)
+	if canonical, ok := d.Aliases[val]; ok {
+		return canonical
+	}
+	return val
+}
+
+// normalizeLocaleValue lowercases a locale value and converts POSIX-style
+// underscores (en_US) into the dash form used by the locale tables (en-us)
+func normalizeLocaleValue(val string) string {
+	return strings.ReplaceAll(strings.ToLower(val), "_", "-")
 }
 
 // removeSubdomainLocale removes locale subdomain from URL
@@ -243,7 +387,7 @@ func (d *Detector) removeSubdomainLocale(rawURL string, u *url.URL, locale strin
 
 	newURL := *u
 	newURL.Host = newHost
-	return newURL.String()
+	return baseURLString(&newURL)
 }
 
 // removePathLocale removes locale from path
@@ -265,27 +409,56 @@ func (d *Detector) removePathLocale(rawURL string, u *url.URL, locale string, po
 
 	newURL := *u
 	newURL.Path = newPath
-	return newURL.String()
+	return baseURLString(&newURL)
 }
 
-// removeQueryLocale removes locale query parameter from URL
-func (d *Detector) removeQueryLocale(rawURL string, u *url.URL, locale string) string {
+// removeQueryLocale removes the locale query parameter it was read from,
+// and any other locale parameter carrying the same locale, from the URL
+func (d *Detector) removeQueryLocale(u *url.URL, locale, matched string) string {
 	q := u.Query()
+	q.Del(matched)
 
-	// Remove all locale-related parameters
-	for _, param := range localeQueryParams {
-		if strings.ToLower(q.Get(param)) == locale {
+	for _, param := range d.queryParams() {
+		if d.canonicalValue(q.Get(param)) == locale {
 			q.Del(param)
 		}
 	}
 
 	newURL := *u
 	newURL.RawQuery = q.Encode()
-	return newURL.String()
+	return baseURLString(&newURL)
+}
+
+// baseURLString renders a URL with its locale removed. The host is lowercased
+// so EN.example.com and en.example.com variants yield the same base URL.
+func baseURLString(u *url.URL) string {
+	u.Host = strings.ToLower(u.Host)
+	return u.String()
+}
+
+// ParseAliases parses a comma-separated list of alias=locale pairs
+// ("us=en,br=pt") into a map for Detector.Aliases
+func ParseAliases(s string) (map[string]string, error) {
+	aliases := make(map[string]string)
+	for _, pair := range strings.Split(s, ",") {
+		pair = strings.TrimSpace(pair)
+		if pair == "" {
+			continue
+		}
+
+		alias, canonical, ok := strings.Cut(pair, "=")
+		alias = normalizeLocaleValue(strings.TrimSpace(alias))
+		canonical = normalizeLocaleValue(strings.TrimSpace(canonical))
+		if !ok || alias == "" || canonical == "" {
+			return nil, fmt.Errorf("%q is not alias=locale", pair)
+		}
+		aliases[alias] = canonical
+	}
+	return aliases, nil
 }
 
 // IsLocaleCode checks if a string is a valid locale code
 func IsLocaleCode(code string) bool {
-	code = strings.ToLower(code)
-	return localeCodes[code] || extendedLocaleRegex.MatchString(code)
+	code = normalizeLocaleValue(code)
+	return localeCodes[code] || isExtendedLocale(code)
 }
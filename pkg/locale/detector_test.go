@@ -90,12 +90,60 @@ func TestDetectSubdomain(t *testing.T) {
 			expectedLocale: "es",
 			expectedType:   LocaleTypeSubdomain,
 		},
+		{
+			name:           "Script subtag subdomain",
+			url:            "https://zh-hans.example.com/about",
+			expectedLocale: "zh-hans",
+			expectedType:   LocaleTypeSubdomain,
+		},
+		{
+			name:           "Latin script subdomain",
+			url:            "https://sr-Latn.example.com/about",
+			expectedLocale: "sr-latn",
+			expectedType:   LocaleTypeSubdomain,
+		},
+		{
+			name:           "Region subdomain",
+			url:            "https://en-gb.example.com/about",
+			expectedLocale: "en-gb",
+			expectedType:   LocaleTypeSubdomain,
+		},
+		{
+			name:           "Unknown four-letter subtag",
+			url:            "https://en-test.example.com/about",
+			expectedLocale: "",
+			expectedType:   LocaleTypeNone,
+		},
+		{
+			name:           "Unknown language with region",
+			url:            "https://xx-us.example.com/about",
+			expectedLocale: "",
+			expectedType:   LocaleTypeNone,
+		},
 		{
 			name:           "No locale subdomain",
 			url:            "https://www.example.com/about",
 			expectedLocale: "",
 			expectedType:   LocaleTypeNone,
 		},
+		{
+			name:           "Locale label before an IP",
+			url:            "https://en.192.168.1.1/about",
+			expectedLocale: "",
+			expectedType:   LocaleTypeNone,
+		},
+		{
+			name:           "Raw IP host with port",
+			url:            "http://10.0.0.1:8080/about",
+			expectedLocale: "",
+			expectedType:   LocaleTypeNone,
+		},
+		{
+			name:           "Subdomain with port",
+			url:            "https://de.example.com:8443/about",
+			expectedLocale: "de",
+			expectedType:   LocaleTypeSubdomain,
+		},
 	}
 
 	for _, tt := range tests {
@@ -137,6 +185,18 @@ func TestDetectQueryParam(t *testing.T) {
 			expectedLocale: "es",
 			expectedType:   LocaleTypeQuery,
 		},
+		{
+			name:           "Underscore locale value",
+			url:            "https://example.com/about?hl=en_US",
+			expectedLocale: "en-us",
+			expectedType:   LocaleTypeQuery,
+		},
+		{
+			name:           "Uppercase region locale value",
+			url:            "https://example.com/about?locale=pt-BR",
+			expectedLocale: "pt-br",
+			expectedType:   LocaleTypeQuery,
+		},
 		{
 			name:           "No locale parameter",
 			url:            "https://example.com/about?foo=bar",
@@ -186,6 +246,67 @@ func TestRemoveLocale(t *testing.T) {
 			url:         "https://example.com/about?lang=en&foo=bar",
 			expectedBase: "https://example.com/about?foo=bar",
 		},
+		{
+			name:        "Remove underscore query locale",
+			url:         "https://example.com/about?hl=en_US&foo=bar",
+			expectedBase: "https://example.com/about?foo=bar",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := detector.Detect(tt.url)
+			if err != nil {
+				t.Fatalf("Detect() error = %v", err)
+			}
+
+			if result.BaseURL != tt.expectedBase {
+				t.Errorf("Expected base URL %q, got %q", tt.expectedBase, result.BaseURL)
+			}
+		})
+	}
+}
+
+func TestDetectMidPathLocale(t *testing.T) {
+	detector := NewDetector()
+	detector.ScanAllSegments = true
+
+	tests := []struct {
+		name           string
+		url            string
+		expectedLocale string
+		expectedBase   string
+	}{
+		{
+			name:           "Second segment",
+			url:            "https://example.com/help/en/article",
+			expectedLocale: "en",
+			expectedBase:   "https://example.com/help/article",
+		},
+		{
+			name:           "Third segment",
+			url:            "https://example.com/docs/help/es/articulo",
+			expectedLocale: "es",
+			expectedBase:   "https://example.com/docs/help/articulo",
+		},
+		{
+			name:           "Last segment is not a locale",
+			url:            "https://example.com/docs/help/page/de",
+			expectedLocale: "",
+			expectedBase:   "https://example.com/docs/help/page/de",
+		},
+		{
+			name:           "After an API version",
+			url:            "https://example.com/service/v2/de/items",
+			expectedLocale: "",
+			expectedBase:   "https://example.com/service/v2/de/items",
+		},
+		{
+			name:           "After a numeric ID",
+			url:            "https://example.com/org/users/123/hr/reports",
+			expectedLocale: "",
+			expectedBase:   "https://example.com/org/users/123/hr/reports",
+		},
 	}
 
 	for _, tt := range tests {
@@ -195,9 +316,154 @@ func TestRemoveLocale(t *testing.T) {
 				t.Fatalf("Detect() error = %v", err)
 			}
 
+			if result.Locale != tt.expectedLocale {
+				t.Errorf("Expected locale %q, got %q", tt.expectedLocale, result.Locale)
+			}
+
 			if result.BaseURL != tt.expectedBase {
 				t.Errorf("Expected base URL %q, got %q", tt.expectedBase, result.BaseURL)
 			}
 		})
 	}
+
+	// Mid-path locales are ignored unless scanning is enabled
+	result, _ := NewDetector().Detect("https://example.com/docs/help/es/articulo")
+	if result.LocaleType != LocaleTypeNone {
+		t.Errorf("Default detector should not scan past the second segment, got %q", result.Locale)
+	}
+}
+
+func TestDetectAliases(t *testing.T) {
+	aliases, err := ParseAliases("us=en, BR=pt")
+	if err != nil {
+		t.Fatalf("ParseAliases() error = %v", err)
+	}
+	detector := NewDetector()
+	detector.Aliases = aliases
+
+	tests := []struct {
+		name           string
+		url            string
+		expectedLocale string
+		expectedBase   string
+	}{
+		{
+			name:           "Path alias",
+			url:            "https://example.com/us/about",
+			expectedLocale: "en",
+			expectedBase:   "https://example.com/about",
+		},
+		{
+			name:           "Alias overrides a standard code",
+			url:            "https://example.com/br/about",
+			expectedLocale: "pt",
+			expectedBase:   "https://example.com/about",
+		},
+		{
+			name:           "Subdomain alias",
+			url:            "https://us.example.com/about",
+			expectedLocale: "en",
+			expectedBase:   "https://example.com/about",
+		},
+		{
+			name:           "Query alias",
+			url:            "https://example.com/about?lang=US",
+			expectedLocale: "en",
+			expectedBase:   "https://example.com/about",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := detector.Detect(tt.url)
+			if err != nil {
+				t.Fatalf("Detect() error = %v", err)
+			}
+			if result.Locale != tt.expectedLocale {
+				t.Errorf("Expected locale %q, got %q", tt.expectedLocale, result.Locale)
+			}
+			if result.BaseURL != tt.expectedBase {
+				t.Errorf("Expected base %q, got %q", tt.expectedBase, result.BaseURL)
+			}
+		})
+	}
+
+	if _, err := ParseAliases("us"); err == nil {
+		t.Error("ParseAliases(\"us\") should fail without a locale")
+	}
+}
+
+func TestDetectSingleLetterParam(t *testing.T) {
+	tests := []struct {
+		url            string
+		enabled        bool
+		expectedLocale string
+		expectedBase   string
+	}{
+		{"https://example.com/about?l=en", false, "", "https://example.com/about?l=en"},
+		{"https://example.com/about?l=en", true, "en", "https://example.com/about"},
+		{"https://example.com/items?l=5", true, "", "https://example.com/items?l=5"},
+		{"https://example.com/items?l=abc", true, "", "https://example.com/items?l=abc"},
+		{"https://example.com/about?lang=en", false, "en", "https://example.com/about"},
+	}
+
+	for _, tt := range tests {
+		detector := NewDetector()
+		detector.SingleLetterParam = tt.enabled
+
+		result, err := detector.Detect(tt.url)
+		if err != nil {
+			t.Fatalf("Detect(%q) error = %v", tt.url, err)
+		}
+		if result.Locale != tt.expectedLocale {
+			t.Errorf("Detect(%q) with SingleLetterParam=%v locale = %q; want %q", tt.url, tt.enabled, result.Locale, tt.expectedLocale)
+		}
+		if result.BaseURL != tt.expectedBase {
+			t.Errorf("Detect(%q) with SingleLetterParam=%v base = %q; want %q", tt.url, tt.enabled, result.BaseURL, tt.expectedBase)
+		}
+	}
+}
+
+func TestDetectCustomQueryParams(t *testing.T) {
+	detector := NewDetectorWithParams([]string{" Culture ", "ui-lang", ""})
+
+	tests := []struct {
+		url            string
+		expectedLocale string
+		expectedBase   string
+	}{
+		{"https://example.com/about?culture=fr&page=2", "fr", "https://example.com/about?page=2"},
+		{"https://example.com/about?ui-lang=es-MX", "es-mx", "https://example.com/about"},
+		{"https://example.com/about?lang=de", "de", "https://example.com/about"},
+		{"https://example.com/about?lc=it", "", "https://example.com/about?lc=it"},
+		{"https://example.com/about?culture=xyz", "", "https://example.com/about?culture=xyz"},
+		// Only the param the locale was read from is stripped
+		{"https://example.com/about?culture=en&ref=en", "en", "https://example.com/about?ref=en"},
+	}
+
+	for _, tt := range tests {
+		result, err := detector.Detect(tt.url)
+		if err != nil {
+			t.Fatalf("Detect(%q) error = %v", tt.url, err)
+		}
+		if result.Locale != tt.expectedLocale {
+			t.Errorf("Detect(%q) locale = %q; want %q", tt.url, result.Locale, tt.expectedLocale)
+		}
+		if result.BaseURL != tt.expectedBase {
+			t.Errorf("Detect(%q) base = %q; want %q", tt.url, result.BaseURL, tt.expectedBase)
+		}
+	}
+
+	// Grouping goes through the same detector
+	grouper := NewGrouperWithDetector([]string{"en"}, detector)
+	for _, u := range []string{
+		"https://example.com/about?culture=fr",
+		"https://example.com/about?culture=en",
+	} {
+		grouper.Add(u)
+	}
+	best := grouper.GetBestURLs()
+	if len(best) != 1 || best[0].OriginalURL != "https://example.com/about?culture=en" {
+		t.Errorf("GetBestURLs() = %+v; want the en variant only", best)
+	}
 }
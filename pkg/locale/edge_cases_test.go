@@ -268,6 +268,16 @@ func TestBaseURLGeneration(t *testing.T) {
 			url:      "https://example.com/en/search?q=test&page=1",
 			expected: "https://example.com/search?q=test&page=1",
 		},
+		{
+			name:     "Uppercase subdomain locale removal",
+			url:      "https://EN.Example.com/about",
+			expected: "https://example.com/about",
+		},
+		{
+			name:     "Uppercase host with path locale",
+			url:      "https://Example.COM/EN/about",
+			expected: "https://example.com/about",
+		},
 	}
 
 	for _, tt := range tests {
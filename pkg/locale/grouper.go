@@ -2,6 +2,7 @@ package locale
 
 import (
 	"net/url"
+	"sort"
 	"strings"
 )
 
@@ -19,6 +20,10 @@ type Grouper struct {
 	translationMatcher *TranslationMatcher
 	groups             map[string]*LocaleGroup
 	Priority           []string // Exported for access
+
+	// PreferShortestPath picks the variant with the shortest base path when
+	// no priority locale is present, instead of the unlocalized variant
+	PreferShortestPath bool
 }
 
 // NewGrouper creates a new locale grouper
@@ -35,6 +40,14 @@ func NewGrouper(priority []string) *Grouper {
 	}
 }
 
+// NewGrouperWithDetector creates a locale grouper that uses a custom
+// detector, e.g. one with ScanAllSegments enabled
+func NewGrouperWithDetector(priority []string, detector *Detector) *Grouper {
+	g := NewGrouper(priority)
+	g.detector = detector
+	return g
+}
+
 // Add adds a URL to the grouper
 func (g *Grouper) Add(rawURL string) error {
 	localized, err := g.detector.Detect(rawURL)
@@ -139,6 +152,12 @@ func (g *Grouper) updateBestURL(group *LocaleGroup) {
 		}
 	}
 
+	// Without a priority match, the shortest path is usually the canonical one
+	if g.PreferShortestPath {
+		group.BestURL = shortestPath(group.URLs)
+		return
+	}
+
 	// If no priority match, use "default" (no locale detected)
 	if url, exists := group.URLs["default"]; exists {
 		group.BestURL = url
@@ -152,11 +171,17 @@ func (g *Grouper) updateBestURL(group *LocaleGroup) {
 	}
 }
 
-// GetBestURLs returns the best URL from each group
+// GetBestURLs returns the best URL from each group, ordered by group key
 func (g *Grouper) GetBestURLs() []*LocalizedURL {
+	keys := make([]string, 0, len(g.groups))
+	for key := range g.groups {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
 	result := make([]*LocalizedURL, 0, len(g.groups))
-	for _, group := range g.groups {
-		if group.BestURL != nil {
+	for _, key := range keys {
+		if group := g.groups[key]; group.BestURL != nil {
 			result = append(result, group.BestURL)
 		}
 	}
@@ -168,6 +193,41 @@ func (g *Grouper) GetGroups() map[string]*LocaleGroup {
 	return g.groups
 }
 
+// Coverage lists the locales found for one endpoint. Variants without a
+// detected locale are reported as "default".
+type Coverage struct {
+	Endpoint string   `json:"endpoint"` // Group key: host + translation-aware path
+	URL      string   `json:"url"`      // Best URL of the group
+	Locales  []string `json:"locales"`
+}
+
+// Coverage returns the locales discovered for each group, ordered by
+// endpoint, to surface missing translations
+func (g *Grouper) Coverage() []Coverage {
+	keys := make([]string, 0, len(g.groups))
+	for key := range g.groups {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	result := make([]Coverage, 0, len(keys))
+	for _, key := range keys {
+		group := g.groups[key]
+		locales := make([]string, 0, len(group.URLs))
+		for loc := range group.URLs {
+			locales = append(locales, loc)
+		}
+		sort.Strings(locales)
+
+		cov := Coverage{Endpoint: key, Locales: locales}
+		if group.BestURL != nil {
+			cov.URL = group.BestURL.OriginalURL
+		}
+		result = append(result, cov)
+	}
+	return result
+}
+
 // ShouldGroup determines if two URLs should be grouped together
 func (g *Grouper) ShouldGroup(url1, url2 string) (bool, error) {
 	loc1, err := g.detector.Detect(url1)
@@ -1,6 +1,7 @@
 package locale
 
 import (
+	"strings"
 	"testing"
 )
 
@@ -129,3 +130,178 @@ func TestShouldGroup(t *testing.T) {
 		})
 	}
 }
+
+func TestGrouperDeterministicOrder(t *testing.T) {
+	urls := []string{
+		"https://example.com/en/products",
+		"https://example.com/es/productos",
+		"https://example.com/unique-page",
+		"https://example.com/en/about",
+		"https://example.com/es/sobre-nosotros",
+		"https://example.com/contact",
+		"https://example.com/blog/post",
+	}
+
+	run := func() []string {
+		grouper := NewGrouper([]string{"en"})
+		for _, url := range urls {
+			if err := grouper.Add(url); err != nil {
+				t.Fatalf("Add(%q) error = %v", url, err)
+			}
+		}
+		var result []string
+		for _, best := range grouper.GetBestURLs() {
+			result = append(result, best.OriginalURL)
+		}
+		return result
+	}
+
+	first := run()
+	for i := 0; i < 20; i++ {
+		got := run()
+		if len(got) != len(first) {
+			t.Fatalf("run %d returned %d URLs; want %d", i, len(got), len(first))
+		}
+		for j := range got {
+			if got[j] != first[j] {
+				t.Fatalf("run %d order differs at %d: %q vs %q", i, j, got[j], first[j])
+			}
+		}
+	}
+}
+
+func TestGrouperMidPathLocale(t *testing.T) {
+	detector := NewDetector()
+	detector.ScanAllSegments = true
+	grouper := NewGrouperWithDetector([]string{"en"}, detector)
+
+	urls := []string{
+		"https://example.com/help/en/article",
+		"https://example.com/help/es/articulo",
+		"https://example.com/docs/help/en/article",
+		"https://example.com/docs/help/es/articulo",
+	}
+
+	for _, url := range urls {
+		if err := grouper.Add(url); err != nil {
+			t.Fatalf("Add(%q) error = %v", url, err)
+		}
+	}
+
+	bestURLs := grouper.GetBestURLs()
+	if len(bestURLs) != 2 {
+		t.Fatalf("Expected 2 groups, got %d", len(bestURLs))
+	}
+
+	for _, best := range bestURLs {
+		if best.Locale != "en" {
+			t.Errorf("Expected English URL to be selected, got %q", best.OriginalURL)
+		}
+	}
+}
+
+func TestGrouperUppercaseLocale(t *testing.T) {
+	grouper := NewGrouper([]string{"en"})
+
+	urls := []string{
+		"https://EN.example.com/about",
+		"https://en.example.com/about",
+		"https://example.com/EN/about",
+	}
+
+	for _, url := range urls {
+		if err := grouper.Add(url); err != nil {
+			t.Fatalf("Add(%q) error = %v", url, err)
+		}
+	}
+
+	groups := grouper.GetGroups()
+	if len(groups) != 1 {
+		t.Fatalf("Expected 1 group, got %d", len(groups))
+	}
+
+	for _, url := range urls {
+		result, err := grouper.detector.Detect(url)
+		if err != nil {
+			t.Fatalf("Detect(%q) error = %v", url, err)
+		}
+		if result.BaseURL != "https://example.com/about" {
+			t.Errorf("Detect(%q).BaseURL = %q; want https://example.com/about", url, result.BaseURL)
+		}
+	}
+}
+
+func TestGrouperCoverage(t *testing.T) {
+	grouper := NewGrouper([]string{"en"})
+
+	urls := []string{
+		"https://example.com/en/about",
+		"https://example.com/es/sobre-nosotros",
+		"https://example.com/it/chi-siamo",
+		"https://example.com/fr/about",
+		"https://example.com/en/contact",
+		"https://example.com/contact",
+	}
+
+	for _, url := range urls {
+		if err := grouper.Add(url); err != nil {
+			t.Fatalf("Add(%q) error = %v", url, err)
+		}
+	}
+
+	coverage := grouper.Coverage()
+	if len(coverage) != 2 {
+		t.Fatalf("Expected 2 endpoints, got %d: %v", len(coverage), coverage)
+	}
+
+	want := map[string]string{
+		"example.com/about":   "en,es,fr,it",
+		"example.com/contact": "default,en",
+	}
+	for _, cov := range coverage {
+		got := strings.Join(cov.Locales, ",")
+		if got != want[cov.Endpoint] {
+			t.Errorf("Locales for %q = %q; want %q", cov.Endpoint, got, want[cov.Endpoint])
+		}
+	}
+
+	if coverage[0].URL != "https://example.com/en/about" {
+		t.Errorf("Expected English URL for the about group, got %q", coverage[0].URL)
+	}
+}
+
+func TestGrouperPreferShortestPath(t *testing.T) {
+	urls := []string{
+		"https://example.com/es/sobre-nosotros",
+		"https://example.com/fr/a-propos",
+		"https://example.com/it/chi-siamo",
+	}
+
+	grouper := NewGrouper([]string{"de"})
+	grouper.PreferShortestPath = true
+	for _, url := range urls {
+		if err := grouper.Add(url); err != nil {
+			t.Fatalf("Add(%q) error = %v", url, err)
+		}
+	}
+
+	bestURLs := grouper.GetBestURLs()
+	if len(bestURLs) != 1 {
+		t.Fatalf("Expected 1 group, got %d", len(bestURLs))
+	}
+	if bestURLs[0].OriginalURL != "https://example.com/fr/a-propos" {
+		t.Errorf("Expected shortest path to win, got %q", bestURLs[0].OriginalURL)
+	}
+
+	// A priority locale still wins over a shorter path
+	grouper = NewGrouper([]string{"es"})
+	grouper.PreferShortestPath = true
+	for _, url := range urls {
+		if err := grouper.Add(url); err != nil {
+			t.Fatalf("Add(%q) error = %v", url, err)
+		}
+	}
+	if best := grouper.GetBestURLs(); len(best) != 1 || best[0].Locale != "es" {
+		t.Errorf("Expected priority locale es to win, got %v", best)
+	}
+}
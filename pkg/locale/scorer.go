@@ -17,6 +17,10 @@ type Score struct {
 // Scorer handles URL scoring for prioritization
 type Scorer struct {
 	localePriority map[string]int // locale -> priority score
+
+	// PreferShortestPath breaks score ties in favor of the variant with the
+	// shortest base path
+	PreferShortestPath bool
 }
 
 // NewScorer creates a new scorer with given locale priorities
@@ -132,7 +136,8 @@ func (s *Scorer) GetBestFromGroup(group *LocaleGroup) *LocalizedURL {
 		score := s.Score(locURL, isFirst)
 		isFirst = false
 
-		if bestURL == nil || score.TotalScore > bestScore.TotalScore {
+		if bestURL == nil || score.TotalScore > bestScore.TotalScore ||
+			(s.PreferShortestPath && score.TotalScore == bestScore.TotalScore && shorterPath(locURL, bestURL)) {
 			bestURL = locURL
 			bestScore = score
 		}
@@ -140,3 +145,37 @@ func (s *Scorer) GetBestFromGroup(group *LocaleGroup) *LocalizedURL {
 
 	return bestURL
 }
+
+// shortestPath returns the variant whose base path is shortest
+func shortestPath(urls map[string]*LocalizedURL) *LocalizedURL {
+	var best *LocalizedURL
+	for _, locURL := range urls {
+		if best == nil || shorterPath(locURL, best) {
+			best = locURL
+		}
+	}
+	return best
+}
+
+// shorterPath reports whether a has a shorter (cleaner) base path than b.
+// Ties fall back to the shorter, then lexically smaller, original URL so
+// the choice does not depend on map order.
+func shorterPath(a, b *LocalizedURL) bool {
+	pa, pb := basePath(a), basePath(b)
+	if len(pa) != len(pb) {
+		return len(pa) < len(pb)
+	}
+	if len(a.OriginalURL) != len(b.OriginalURL) {
+		return len(a.OriginalURL) < len(b.OriginalURL)
+	}
+	return a.OriginalURL < b.OriginalURL
+}
+
+// basePath returns the locale-free path of a variant
+func basePath(localized *LocalizedURL) string {
+	u, err := url.Parse(localized.BaseURL)
+	if err != nil {
+		return localized.BaseURL
+	}
+	return u.Path
+}
@@ -2,6 +2,11 @@ package locale
 
 import (
 	"strings"
+	"unicode"
+
+	"golang.org/x/text/runes"
+	"golang.org/x/text/transform"
+	"golang.org/x/text/unicode/norm"
 )
 
 // TranslationGroup represents a group of translations for the same concept
@@ -207,6 +212,17 @@ var commonTranslations = []TranslationGroup{
 			"sepet", "alisveris-sepeti", // Turkish
 		},
 	},
+	{
+		Canonical: "categories",
+		Variants: []string{
+			"categories", "category",
+			"categorias", "categoria", // Spanish/Portuguese
+			"categorie", // Italian/French
+			"kategorien", "kategorie", // German
+			"kategorie", "kategoria", // Polish
+			"kategoriler", "kategori", // Turkish
+		},
+	},
 	{
 		Canonical: "checkout",
 		Variants: []string{
@@ -222,28 +238,50 @@ var commonTranslations = []TranslationGroup{
 	},
 }
 
+// MatchOptions controls how segments are normalized before matching
+type MatchOptions struct {
+	FoldAccents bool // Strip diacritics (categoría -> categoria)
+	StemPlurals bool // Strip -ies/-es/-s endings instead of only a trailing 's'
+}
+
+// DefaultMatchOptions returns the options used by NewTranslationMatcher
+func DefaultMatchOptions() MatchOptions {
+	return MatchOptions{
+		FoldAccents: true,
+		StemPlurals: true,
+	}
+}
+
 // TranslationMatcher handles translation matching
 type TranslationMatcher struct {
 	normalizedIndex map[string]string // normalized variant -> canonical
 	groupIndex      map[string]*TranslationGroup
+	options         MatchOptions
 }
 
 // NewTranslationMatcher creates a new translation matcher
 func NewTranslationMatcher() *TranslationMatcher {
+	return NewTranslationMatcherWithOptions(DefaultMatchOptions())
+}
+
+// NewTranslationMatcherWithOptions creates a translation matcher with custom
+// normalization options. The zero value keeps the naive trailing-'s' matching.
+func NewTranslationMatcherWithOptions(options MatchOptions) *TranslationMatcher {
 	tm := &TranslationMatcher{
 		normalizedIndex: make(map[string]string),
 		groupIndex:      make(map[string]*TranslationGroup),
+		options:         options,
 	}
 
 	// Build indexes
 	for i := range commonTranslations {
 		group := &commonTranslations[i]
-		canonical := normalizeForMatching(group.Canonical)
+		canonical := tm.normalizeForMatching(group.Canonical)
 
 		tm.groupIndex[canonical] = group
 
 		for _, variant := range group.Variants {
-			normalized := normalizeForMatching(variant)
+			normalized := tm.normalizeForMatching(variant)
 			tm.normalizedIndex[normalized] = canonical
 		}
 	}
@@ -253,8 +291,8 @@ func NewTranslationMatcher() *TranslationMatcher {
 
 // AreTranslations checks if two path segments are translations of each other
 func (tm *TranslationMatcher) AreTranslations(seg1, seg2 string) bool {
-	norm1 := normalizeForMatching(seg1)
-	norm2 := normalizeForMatching(seg2)
+	norm1 := tm.normalizeForMatching(seg1)
+	norm2 := tm.normalizeForMatching(seg2)
 
 	// Same segment
 	if norm1 == norm2 {
@@ -274,7 +312,7 @@ func (tm *TranslationMatcher) AreTranslations(seg1, seg2 string) bool {
 
 // GetCanonical returns the canonical form of a segment if it's a known translation
 func (tm *TranslationMatcher) GetCanonical(segment string) string {
-	normalized := normalizeForMatching(segment)
+	normalized := tm.normalizeForMatching(segment)
 	if canonical, ok := tm.normalizedIndex[normalized]; ok {
 		return canonical
 	}
@@ -282,7 +320,7 @@ func (tm *TranslationMatcher) GetCanonical(segment string) string {
 }
 
 // normalizeForMatching normalizes a string for translation matching
-func normalizeForMatching(s string) string {
+func (tm *TranslationMatcher) normalizeForMatching(s string) string {
 	// Convert to lowercase
 	s = strings.ToLower(s)
 
@@ -290,6 +328,14 @@ func normalizeForMatching(s string) string {
 	s = strings.ReplaceAll(s, "-", "")
 	s = strings.ReplaceAll(s, "_", "")
 
+	if tm.options.FoldAccents {
+		s = foldAccents(s)
+	}
+
+	if tm.options.StemPlurals {
+		return stemPlural(s)
+	}
+
 	// Remove trailing 's' for simple pluralization
 	if len(s) > 3 && strings.HasSuffix(s, "s") {
 		return s[:len(s)-1]
@@ -297,3 +343,42 @@ func normalizeForMatching(s string) string {
 
 	return s
 }
+
+// foldAccents removes combining marks so accented and plain spellings match
+func foldAccents(s string) string {
+	t := transform.Chain(norm.NFD, runes.Remove(runes.In(unicode.Mn)), norm.NFC)
+	folded, _, err := transform.String(t, s)
+	if err != nil {
+		return s
+	}
+	return folded
+}
+
+// stemPlural strips common English/Romance plural endings
+func stemPlural(s string) string {
+	switch {
+	case len(s) > 4 && strings.HasSuffix(s, "ies"):
+		// categories -> category
+		return s[:len(s)-3] + "y"
+	case len(s) > 4 && hasSibilantPlural(s):
+		// addresses -> address, boxes -> box
+		return s[:len(s)-2]
+	case len(s) > 3 && strings.HasSuffix(s, "s") && !strings.HasSuffix(s, "ss"):
+		return s[:len(s)-1]
+	}
+	return s
+}
+
+// hasSibilantPlural reports whether s ends in -es after a sibilant
+func hasSibilantPlural(s string) bool {
+	if !strings.HasSuffix(s, "es") {
+		return false
+	}
+	stem := s[:len(s)-2]
+	for _, suffix := range []string{"ss", "x", "z", "ch", "sh"} {
+		if strings.HasSuffix(stem, suffix) {
+			return true
+		}
+	}
+	return false
+}
@@ -102,3 +102,48 @@ func TestGetCanonical(t *testing.T) {
 		})
 	}
 }
+
+func TestTranslationMatcherAccentsAndPlurals(t *testing.T) {
+	matcher := NewTranslationMatcher()
+
+	segments := []string{"categorias", "categoría", "category", "categories", "Categorías"}
+	for _, seg := range segments {
+		if got := matcher.GetCanonical(seg); got != "category" {
+			t.Errorf("GetCanonical(%q) = %q; want category", seg, got)
+		}
+	}
+
+	if !matcher.AreTranslations("categorias", "category") {
+		t.Error("categorias and category should match")
+	}
+	if !matcher.AreTranslations("categoría", "categories") {
+		t.Error("categoría and categories should match")
+	}
+
+	// The zero options keep the naive behavior: no accent folding, no -ies
+	naive := NewTranslationMatcherWithOptions(MatchOptions{})
+	if naive.AreTranslations("categoría", "category") {
+		t.Error("accent folding should be disabled with zero options")
+	}
+	if !naive.AreTranslations("products", "productos") {
+		t.Error("trailing 's' matching should still work with zero options")
+	}
+}
+
+func TestStemPlural(t *testing.T) {
+	tests := map[string]string{
+		"categories": "category",
+		"addresses":  "address",
+		"boxes":      "box",
+		"services":   "service",
+		"productos":  "producto",
+		"address":    "address",
+		"bus":        "bus",
+	}
+
+	for input, expected := range tests {
+		if got := stemPlural(input); got != expected {
+			t.Errorf("stemPlural(%q) = %q; want %q", input, got, expected)
+		}
+	}
+}
@@ -0,0 +1,47 @@
+package normalizer
+
+import "strings"
+
+// Stages reported by Explain, in pipeline order
+const (
+	StageInput  = "input"
+	StageLocale = "locale"
+	StageScheme = "scheme"
+	StageHost   = "host"
+	StagePath   = "path"
+	StageFuzzy  = "fuzzy"
+	StageQuery  = "query"
+	StageKey    = "key"
+	StageOutput = "output"
+)
+
+// Step is the value of a URL after one normalization stage
+type Step struct {
+	Stage string
+	Value string
+}
+
+// Explain traces a single URL through the URL normalization stages and
+// returns the value after each one, ending with the dedup key and the
+// output value the configured mode actually produces. The stage values
+// follow the url mode key; other modes and a custom key only show in the
+// last two steps. An error at any stage is returned with the steps so far.
+func (c *Config) Explain(raw string) ([]Step, error) {
+	if c.TrimSpaces {
+		raw = strings.TrimSpace(raw)
+	}
+	steps := []Step{{StageInput, raw}}
+
+	_, err := c.traceKeyURL(raw, c.KeepParamValues, func(stage, value string) {
+		steps = append(steps, Step{stage, value})
+	})
+	if err != nil {
+		return steps, err
+	}
+
+	key, normalized, err := c.NormalizeWithKey(raw)
+	if err != nil {
+		return steps, err
+	}
+	return append(steps, Step{StageKey, key}, Step{StageOutput, normalized}), nil
+}
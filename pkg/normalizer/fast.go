@@ -0,0 +1,141 @@
+package normalizer
+
+import "strings"
+
+// fastNormalizeURL normalizes a plain http(s) URL with plain string work,
+// skipping the url.Parse / url.Values / String round trip. It only handles
+// URLs without query, userinfo, escapes or IPv6 hosts, and only when no
+// filter, fuzzy pattern or path rewrite is configured; ok is false when the
+// full normalization must run. Query options (ignored or sorted params) are
+// moot here since such URLs carry no query.
+func (c *Config) fastNormalizeURL(raw string) (normalized string, ok bool) {
+	if !c.fastPathEligible() {
+		return "", false
+	}
+
+	var scheme, rest string
+	switch {
+	case strings.HasPrefix(raw, "https://"):
+		scheme, rest = "https", raw[len("https://"):]
+	case strings.HasPrefix(raw, "http://"):
+		scheme, rest = "http", raw[len("http://"):]
+	default:
+		return "", false
+	}
+
+	if i := strings.IndexByte(rest, '#'); i != -1 {
+		if !c.IgnoreFragment || !simpleFragment(rest[i+1:]) {
+			return "", false
+		}
+		rest = rest[:i]
+	}
+
+	host, path := rest, ""
+	if i := strings.IndexByte(rest, '/'); i != -1 {
+		host, path = rest[:i], rest[i:]
+	}
+	if !simpleHost(host) || !simplePath(path) {
+		return "", false
+	}
+
+	// Same steps, in the same order, as normalizeScheme, normalizeHost,
+	// unifyScheme and NormalizePath
+	if c.CaseSensitive && !c.KeepScheme {
+		scheme = "https"
+	}
+	host = c.canonicalHost(host, scheme)
+	if c.IgnoreScheme && scheme == "http" {
+		scheme = "https"
+	}
+	if !cleanPath(path) {
+		path = NormalizePath(path)
+	}
+
+	return scheme + "://" + host + path, true
+}
+
+// fastPathEligible reports whether no option needs more than the host and
+// path string work fastNormalizeURL does
+func (c *Config) fastPathEligible() bool {
+	return !c.FuzzyMode && !c.StructuralPath && !c.CanonicalOutput && !c.CollapseAMP &&
+		len(c.AllowDomains) == 0 && len(c.BlockDomains) == 0 &&
+		len(c.IgnoreExtensions) == 0 && len(c.FilterExtensions) == 0 &&
+		c.MaxPathSegments <= 0 && c.MinPathSegments <= 0
+}
+
+// simpleHost reports whether host is a plain name with an optional numeric
+// port, which url.Parse would accept unchanged
+func simpleHost(host string) bool {
+	name, port := host, ""
+	if i := strings.IndexByte(host, ':'); i != -1 {
+		name, port = host[:i], host[i+1:]
+		if port == "" {
+			return false
+		}
+	}
+	if name == "" || name[0] == '.' || name[0] == '-' {
+		return false
+	}
+
+	for i := 0; i < len(name); i++ {
+		b := name[i]
+		if !isAlnum(b) && b != '.' && b != '-' {
+			return false
+		}
+	}
+	for i := 0; i < len(port); i++ {
+		if port[i] < '0' || port[i] > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+// simplePath reports whether p is empty or an absolute path made only of
+// unreserved characters, so escaping it is a no-op
+func simplePath(p string) bool {
+	for i := 0; i < len(p); i++ {
+		b := p[i]
+		if !isAlnum(b) && !strings.ContainsRune("-._~/", rune(b)) {
+			return false
+		}
+	}
+	return true
+}
+
+// simpleFragment reports whether url.Parse would accept a fragment that is
+// about to be dropped: no escapes to validate and no control characters
+func simpleFragment(f string) bool {
+	for i := 0; i < len(f); i++ {
+		if f[i] == '%' || f[i] < 0x20 || f[i] == 0x7f {
+			return false
+		}
+	}
+	return true
+}
+
+// cleanPath reports whether NormalizePath would return p unchanged: no empty,
+// "." or ".." segments and no trailing slash except on the root
+func cleanPath(p string) bool {
+	if p == "/" {
+		return true
+	}
+	if p == "" || strings.HasSuffix(p, "/") {
+		return false
+	}
+
+	for rest := p[1:]; ; {
+		seg, tail, more := strings.Cut(rest, "/")
+		if seg == "" || seg == "." || seg == ".." {
+			return false
+		}
+		if !more {
+			return true
+		}
+		rest = tail
+	}
+}
+
+func isAlnum(b byte) bool {
+	return b >= 'a' && b <= 'z' || b >= 'A' && b <= 'Z' || b >= '0' && b <= '9'
+}
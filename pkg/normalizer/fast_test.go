@@ -0,0 +1,65 @@
+package normalizer
+
+import "testing"
+
+func TestFastNormalizeURLMatchesParse(t *testing.T) {
+	urls := []string{
+		"https://example.com",
+		"https://example.com/",
+		"https://www.Example.COM/api/users#section",
+		"http://example.com:80/a/b/",
+		"https://example.com:8443/a//b/./c/../d",
+		"https://example.com./docs",
+		"HTTP://example.com/upper-scheme",
+		"https://example.com/search?q=go",
+		"https://example.com/caf%C3%A9",
+		"https://user@example.com/private",
+		"https://[::1]:8080/ipv6",
+		"https://192.168.0.1/ip",
+		"https://example.com/a#frag%zz",
+		"https://example.com:/empty-port",
+		"https://example.com/path with space",
+		"//example.com/no-scheme",
+		"not a url",
+	}
+
+	configs := map[string]func(c *Config){
+		"default":        func(c *Config) {},
+		"keep-www":       func(c *Config) { c.KeepWWW = true },
+		"keep-fragment":  func(c *Config) { c.IgnoreFragment = false },
+		"case-sensitive": func(c *Config) { c.CaseSensitive = true },
+		"keep-scheme":    func(c *Config) { c.KeepScheme = true },
+		"ignore-scheme":  func(c *Config) { c.IgnoreScheme = true },
+		"keep-fqdn-dot":  func(c *Config) { c.KeepFQDNDot = true },
+		"www-apex-only":  func(c *Config) { c.WWWApexOnly = true },
+		"sort-params":    func(c *Config) { c.SortParams = true },
+	}
+
+	for name, configure := range configs {
+		c := NewConfig()
+		configure(c)
+
+		for _, raw := range urls {
+			got, gotErr := c.NormalizeURL(raw)
+			want, wantErr := c.parseNormalizeURL(raw)
+			if got != want || (gotErr == nil) != (wantErr == nil) {
+				t.Errorf("%s: NormalizeURL(%q) = %q, %v; want %q, %v as on the parsed path", name, raw, got, gotErr, want, wantErr)
+			}
+		}
+	}
+}
+
+func TestFastNormalizeURLAllocs(t *testing.T) {
+	c := NewConfig()
+	raw := "https://www.example.com/api/users#section"
+
+	if _, ok := c.fastNormalizeURL(raw); !ok {
+		t.Fatalf("fastNormalizeURL(%q) not taken", raw)
+	}
+
+	fast := testing.AllocsPerRun(100, func() { c.NormalizeURL(raw) })
+	slow := testing.AllocsPerRun(100, func() { c.parseNormalizeURL(raw) })
+	if fast >= slow {
+		t.Errorf("fast path allocs = %v; want fewer than the parsed path's %v", fast, slow)
+	}
+}
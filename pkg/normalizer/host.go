@@ -0,0 +1,214 @@
+package normalizer
+
+import (
+	"net"
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+// numberedLabelRegex matches host labels ending in digits after a non-digit
+// prefix (web01, node7, api-2). Pure numeric labels never match.
+var numberedLabelRegex = regexp.MustCompile(`(?i)^([a-z][a-z0-9-]*?[a-z-])\d+$`)
+
+// defaultPorts maps schemes to the port implied when none is given
+var defaultPorts = map[string]string{
+	"http":  "80",
+	"https": "443",
+	"ws":    "80",
+	"wss":   "443",
+	"ftp":   "21",
+	"ssh":   "22",
+}
+
+func (c *Config) normalizeHost(u *url.URL) {
+	u.Host = c.canonicalHost(u.Host, u.Scheme)
+}
+
+// canonicalHost applies case folding, default port removal, FQDN dot and www
+// stripping to a host according to the configuration
+func (c *Config) canonicalHost(host, scheme string) string {
+	// Normalize case FIRST
+	if !c.CaseSensitive {
+		host = strings.ToLower(host)
+	}
+
+	// Remove the scheme's default port
+	if port, ok := defaultPorts[strings.ToLower(scheme)]; ok {
+		host = strings.TrimSuffix(host, ":"+port)
+	}
+
+	// IP hosts have no subdomains or FQDN dot to strip
+	if isIPHost(host) {
+		return host
+	}
+
+	// Remove the trailing dot of a fully-qualified host (example.com.)
+	if !c.KeepFQDNDot {
+		host = trimFQDNDot(host)
+	}
+
+	// Remove www (after lowercasing)
+	if !c.KeepWWW && strings.HasPrefix(host, "www.") {
+		if trimmed := strings.TrimPrefix(host, "www."); !c.WWWApexOnly || isApex(trimmed) {
+			host = trimmed
+		}
+	}
+
+	return host
+}
+
+// HostOf returns the canonical host of a URL line, or "" if it has none
+func (c *Config) HostOf(line string) string {
+	if c.TrimSpaces {
+		line = strings.TrimSpace(line)
+	}
+
+	u, err := url.Parse(decodeHost(line))
+	if err != nil || u.Host == "" {
+		return ""
+	}
+	return c.canonicalHost(u.Host, u.Scheme)
+}
+
+// decodeHost percent-decodes the host of a raw URL line, which url.Parse
+// rejects (example.com%3A8080 -> example.com:8080). The line is returned
+// unchanged unless the decoded host is made only of valid host characters.
+func decodeHost(raw string) string {
+	schemeEnd := strings.Index(raw, "://")
+	if schemeEnd == -1 {
+		return raw
+	}
+
+	start := schemeEnd + len("://")
+	end := len(raw)
+	if idx := strings.IndexAny(raw[start:], "/?#"); idx != -1 {
+		end = start + idx
+	}
+
+	// Only the host is decoded; userinfo may legitimately be escaped
+	hostStart := start + strings.LastIndex(raw[start:end], "@") + 1
+	host := raw[hostStart:end]
+	if !strings.Contains(host, "%") {
+		return raw
+	}
+
+	decoded, err := url.PathUnescape(host)
+	if err != nil || !validHostChars(decoded) {
+		return raw
+	}
+	return raw[:hostStart] + decoded + raw[end:]
+}
+
+// validHostChars reports whether s contains only characters allowed in a
+// host with an optional port
+func validHostChars(s string) bool {
+	for _, r := range s {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9':
+		case strings.ContainsRune("-._:[]", r):
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+// isApex reports whether a host, with or without a port, is a registered
+// domain itself (example.com, example.co.uk) rather than a subdomain
+func isApex(host string) bool {
+	name, _ := splitHostPort(host)
+	return RegisteredDomain(name) == strings.ToLower(name)
+}
+
+// isIPHost reports whether a host, with or without a port, is a raw IPv4 or
+// bracketed IPv6 address
+func isIPHost(host string) bool {
+	name, _ := splitHostPort(host)
+	return net.ParseIP(strings.Trim(name, "[]")) != nil
+}
+
+// trimFQDNDot removes a single trailing dot from the host name, keeping any
+// port (example.com.:8080 -> example.com:8080)
+func trimFQDNDot(host string) string {
+	name, port := splitHostPort(host)
+	if !strings.HasSuffix(name, ".") {
+		return host
+	}
+	name = strings.TrimSuffix(name, ".")
+	if port != "" {
+		return name + ":" + port
+	}
+	return name
+}
+
+// splitHostPort splits a host into name and port without failing on hosts
+// that have no port, unlike net.SplitHostPort
+func splitHostPort(host string) (string, string) {
+	idx := strings.LastIndex(host, ":")
+	if idx == -1 || strings.Contains(host[idx:], "]") {
+		return host, ""
+	}
+	return host[:idx], host[idx+1:]
+}
+
+// fuzzHostNumbers replaces trailing digits in subdomain labels with {n} so
+// load-balanced hosts (web01.example.com, web02.example.com) share a key.
+// The registered domain labels are left untouched.
+func fuzzHostNumbers(host string) string {
+	if isIPHost(host) {
+		return host
+	}
+	name, port := splitHostPort(host)
+
+	labels := strings.Split(name, ".")
+	if len(labels) <= 2 {
+		return host
+	}
+
+	for i := 0; i < len(labels)-2; i++ {
+		labels[i] = numberedLabelRegex.ReplaceAllString(labels[i], "${1}{n}")
+	}
+
+	name = strings.Join(labels, ".")
+	if port != "" {
+		return name + ":" + port
+	}
+	return name
+}
+
+// multiLabelSuffixes lists common public suffixes made of more than one
+// label. Any other host is assumed to use a single-label suffix (com, org).
+var multiLabelSuffixes = map[string]bool{
+	"co.uk": true, "org.uk": true, "ac.uk": true, "gov.uk": true, "me.uk": true,
+	"com.au": true, "net.au": true, "org.au": true, "edu.au": true, "gov.au": true,
+	"co.nz": true, "org.nz": true, "co.jp": true, "ne.jp": true, "or.jp": true,
+	"co.kr": true, "co.in": true, "co.za": true, "co.il": true,
+	"com.br": true, "com.mx": true, "com.ar": true, "com.cn": true, "com.tw": true,
+	"com.hk": true, "com.sg": true, "com.tr": true, "com.es": true,
+}
+
+// RegisteredDomain returns the registered domain (eTLD+1) of a host, so
+// api.example.co.uk and www.example.co.uk both yield example.co.uk. Ports
+// are dropped; IP addresses and single-label hosts are returned as is.
+func RegisteredDomain(host string) string {
+	name, _ := splitHostPort(host)
+	name = strings.TrimSuffix(strings.ToLower(name), ".")
+	if name == "" || isIPHost(name) {
+		return name
+	}
+
+	labels := strings.Split(name, ".")
+	if len(labels) <= 2 {
+		return name
+	}
+
+	suffixLabels := 1
+	if multiLabelSuffixes[strings.Join(labels[len(labels)-2:], ".")] {
+		suffixLabels = 2
+	}
+	if len(labels) <= suffixLabels {
+		return name
+	}
+	return strings.Join(labels[len(labels)-suffixLabels-1:], ".")
+}
@@ -1,6 +1,8 @@
 package normalizer
 
 import (
+	"fmt"
+	"net/url"
 	"regexp"
 	"strings"
 )
@@ -11,6 +13,10 @@ const (
 )
 
 var (
+	// Extension pattern - a real extension is a short alphanumeric token, so
+	// leftovers like "tar%2fsomething" are never mistaken for one
+	extensionRegex = regexp.MustCompile(`^[a-z0-9_-]+$`)
+
 	// Numeric ID pattern - matches pure numeric path segments
 	numericIDRegex = regexp.MustCompile(`/\d+(/|$)`)
 
@@ -22,6 +28,12 @@ var (
 
 	// Alphanumeric token pattern - matches long alphanumeric strings
 	tokenRegex = regexp.MustCompile(`/[a-zA-Z0-9]{16,}(/|$)`)
+
+	// A placeholder segment as url.URL escapes it (/%7Bid%7D)
+	escapedPlaceholderRegex = regexp.MustCompile(`%7B([A-Za-z0-9_-]+)%7D`)
+
+	// Whole-segment UUID in either case, for StructuralPath
+	uuidSegmentRegex = regexp.MustCompile(`^(?i)[0-9a-f]{8}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{12}$`)
 )
 
 // FuzzyPattern represents a pattern for fuzzy matching
@@ -48,8 +60,8 @@ func NormalizePath(p string) string {
 		return "/"
 	}
 
-	// Collapse multiple slashes
-	p = collapseSlashes(p)
+	// Collapse multiple slashes and resolve dot segments
+	p = collapseSegments(p)
 
 	// Remove trailing slash (except root)
 	if len(p) > 1 && strings.HasSuffix(p, "/") {
@@ -59,8 +71,62 @@ func NormalizePath(p string) string {
 	return p
 }
 
-// collapseSlashes removes consecutive slashes from path
-func collapseSlashes(p string) string {
+// PathExtension returns the lowercase extension of the last path segment, or
+// "" if it has none. The path is unescaped first so encoded slashes (%2F)
+// split segments instead of ending up inside the extension.
+func PathExtension(p string) string {
+	if decoded, err := url.PathUnescape(p); err == nil {
+		p = decoded
+	}
+
+	segment := p[strings.LastIndex(p, "/")+1:]
+	lastDot := strings.LastIndex(segment, ".")
+	if lastDot == -1 || lastDot == len(segment)-1 {
+		return ""
+	}
+
+	ext := strings.ToLower(segment[lastDot+1:])
+	if !extensionRegex.MatchString(ext) {
+		return ""
+	}
+	return ext
+}
+
+// CollapseAMP removes a standalone "amp" segment at the start or end of a
+// path, so /amp/article and /article/amp both become /article. Paths that
+// are only "/amp", and segments merely containing amp (/ampersand), are
+// left alone.
+func CollapseAMP(p string) string {
+	segments := strings.Split(strings.Trim(p, "/"), "/")
+	if len(segments) < 2 {
+		return p
+	}
+
+	if strings.EqualFold(segments[0], "amp") {
+		segments = segments[1:]
+	} else if strings.EqualFold(segments[len(segments)-1], "amp") {
+		segments = segments[:len(segments)-1]
+	} else {
+		return p
+	}
+
+	return "/" + strings.Join(segments, "/")
+}
+
+// CountPathSegments returns the number of non-empty segments in a path
+func CountPathSegments(p string) int {
+	count := 0
+	for _, seg := range strings.Split(p, "/") {
+		if seg != "" {
+			count++
+		}
+	}
+	return count
+}
+
+// collapseSegments removes consecutive slashes from path and resolves "."
+// and ".." segments, so /a/./b, /a/c/../b and /a//b all become /a/b
+func collapseSegments(p string) string {
 	if p == "" {
 		return "/"
 	}
@@ -69,11 +135,20 @@ func collapseSlashes(p string) string {
 	out := make([]string, 0, len(parts))
 
 	for _, seg := range parts {
-		if seg == "" {
+		switch seg {
+		case "":
 			if len(out) == 0 {
 				out = append(out, "")
 			}
 			continue
+		case ".":
+			continue
+		case "..":
+			// Never climb above the root
+			if len(out) > 0 && out[len(out)-1] != "" {
+				out = out[:len(out)-1]
+			}
+			continue
 		}
 		out = append(out, seg)
 	}
@@ -90,7 +165,7 @@ func ApplyFuzzyPatterns(p string, patterns []FuzzyPattern) string {
 	result := p
 	for _, pattern := range patterns {
 		if pattern.Enabled {
-			result = pattern.Regex.ReplaceAllString(result, "/"+pattern.Placeholder+"$1")
+			result = replaceSegments(pattern.Regex, result, "/"+pattern.Placeholder+"$1")
 		}
 	}
 	return result
@@ -99,7 +174,103 @@ func ApplyFuzzyPatterns(p string, patterns []FuzzyPattern) string {
 // FuzzyPath replaces numeric path segments with {id}
 // This is the legacy method for backward compatibility
 func FuzzyPath(p string) string {
-	return numericIDRegex.ReplaceAllString(p, "/{id}$1")
+	return replaceSegments(numericIDRegex, p, "/{id}$1")
+}
+
+// replaceSegments applies a segment pattern until the path stops changing.
+// Each match consumes the trailing slash, so a single pass skips every other
+// segment in runs like /1/2/3.
+func replaceSegments(re *regexp.Regexp, p, repl string) string {
+	for {
+		next := re.ReplaceAllString(p, repl)
+		if next == p {
+			return next
+		}
+		p = next
+	}
+}
+
+// CollapseIDRuns replaces runs of two or more consecutive placeholder
+// segments with a single {ids} segment (/a/{id}/{id}/b -> /a/{ids}/b)
+func CollapseIDRuns(p string) string {
+	segments := strings.Split(p, "/")
+	out := make([]string, 0, len(segments))
+
+	run := 0
+	for _, seg := range segments {
+		if isPlaceholder(seg) {
+			run++
+			if run == 2 {
+				out[len(out)-1] = "{ids}"
+			}
+			if run >= 2 {
+				continue
+			}
+		} else {
+			run = 0
+		}
+		out = append(out, seg)
+	}
+
+	return strings.Join(out, "/")
+}
+
+// StructuralPath replaces every dynamic-looking segment with {dyn}, leaving
+// a coarse skeleton of the endpoint (/a/123/b/ab12cd/c -> /a/{dyn}/b/{dyn}/c).
+// Numeric IDs, UUIDs, hashes, long tokens, letters mixed with two or more
+// digits or with mixed case, and existing placeholders all count as dynamic.
+func StructuralPath(p string) string {
+	segments := strings.Split(p, "/")
+	for i, seg := range segments {
+		if isDynamicSegment(seg) {
+			segments[i] = "{dyn}"
+		}
+	}
+	return strings.Join(segments, "/")
+}
+
+// isDynamicSegment reports whether a path segment looks generated rather
+// than part of the endpoint's structure
+func isDynamicSegment(seg string) bool {
+	if seg == "" {
+		return false
+	}
+	if isPlaceholder(seg) || uuidSegmentRegex.MatchString(seg) {
+		return true
+	}
+
+	var letters, digits int
+	var upper, lower bool
+	for i := 0; i < len(seg); i++ {
+		switch b := seg[i]; {
+		case b >= '0' && b <= '9':
+			digits++
+		case b >= 'a' && b <= 'z':
+			letters++
+			lower = true
+		case b >= 'A' && b <= 'Z':
+			letters++
+			upper = true
+		default:
+			return false
+		}
+	}
+
+	switch {
+	case letters == 0: // numeric ID
+		return true
+	case len(seg) >= 16: // long token, including hashes
+		return true
+	case digits == 0:
+		return false
+	default: // ab12cd, aB3x; v2 and oauth2 stay
+		return digits >= 2 || upper && lower
+	}
+}
+
+// isPlaceholder reports whether a path segment is a fuzzy placeholder
+func isPlaceholder(seg string) bool {
+	return len(seg) > 2 && strings.HasPrefix(seg, "{") && strings.HasSuffix(seg, "}")
 }
 
 // EnablePattern enables a fuzzy pattern by name
@@ -118,3 +289,31 @@ func EnablePatterns(patterns []FuzzyPattern, names []string) {
 		EnablePattern(patterns, name)
 	}
 }
+
+// ParseCustomPattern parses a user-supplied "<regex>=<placeholder>" fuzzy
+// pattern (v[0-9]+=ver). The regex is matched against whole path segments,
+// anchored like the built-ins as /regex(/|$), and the placeholder is wrapped
+// in braces if needed. Capture groups are rejected since the anchoring
+// relies on the trailing group being $1; use (?:...) instead. Pathological
+// regexes are rejected by CheckRegex.
+func ParseCustomPattern(spec string) (FuzzyPattern, error) {
+	idx := strings.LastIndex(spec, "=")
+	if idx <= 0 || idx == len(spec)-1 {
+		return FuzzyPattern{}, fmt.Errorf("%q is not <regex>=<placeholder>", spec)
+	}
+	expr, placeholder := spec[:idx], spec[idx+1:]
+
+	if _, err := regexp.Compile(expr); err != nil {
+		return FuzzyPattern{}, fmt.Errorf("invalid regex %q: %w", expr, err)
+	}
+	if err := CheckRegex(expr); err != nil {
+		return FuzzyPattern{}, err
+	}
+	re := regexp.MustCompile("/(?:" + expr + ")(/|$)")
+	if re.NumSubexp() != 1 {
+		return FuzzyPattern{}, fmt.Errorf("regex %q must not contain capture groups, use (?:...)", expr)
+	}
+
+	placeholder = "{" + strings.Trim(placeholder, "{}") + "}"
+	return FuzzyPattern{Name: "custom", Regex: re, Placeholder: placeholder, Enabled: true}, nil
+}
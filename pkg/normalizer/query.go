@@ -45,7 +45,36 @@ func BuildSortedQuery(q url.Values) string {
 	return sb.String()
 }
 
-// BuildKeyOnlyQuery builds a query string with parameter names only (no values)
+// DedupParamValues sorts the values of each parameter and removes repeats,
+// so ?tag=b&tag=a&tag=b becomes ?tag=a&tag=b
+func DedupParamValues(q url.Values) {
+	for k, vs := range q {
+		if len(vs) < 2 {
+			continue
+		}
+		sort.Strings(vs)
+		unique := vs[:1]
+		for _, v := range vs[1:] {
+			if v != unique[len(unique)-1] {
+				unique = append(unique, v)
+			}
+		}
+		q[k] = unique
+	}
+}
+
+// LowerParamValues lowercases every parameter value in place, so
+// ?color=Red and ?color=red give the same values
+func LowerParamValues(q url.Values) {
+	for _, vs := range q {
+		for i, v := range vs {
+			vs[i] = strings.ToLower(v)
+		}
+	}
+}
+
+// BuildKeyOnlyQuery builds a query string with parameter names only (no values).
+// Every name is written as name=, so ?a, ?a= and ?a=x all give a=
 // Used for deduplication keys
 func BuildKeyOnlyQuery(q url.Values) string {
 	if len(q) == 0 {
@@ -58,9 +87,68 @@ func BuildKeyOnlyQuery(q url.Values) string {
 	}
 	sort.Strings(keys)
 
-	return strings.Join(keys, "&") + "="
+	return strings.Join(keys, "=&") + "="
+}
+
+// SplitSemicolonQuery rewrites ';' separators in the query of a raw URL as
+// '&', leaving the path and fragment untouched (?a=1;b=2 -> ?a=1&b=2)
+func SplitSemicolonQuery(raw string) string {
+	start := strings.IndexByte(raw, '?')
+	if start < 0 {
+		return raw
+	}
+
+	end := len(raw)
+	if i := strings.IndexByte(raw[start:], '#'); i >= 0 {
+		end = start + i
+	}
+	return raw[:start] + strings.ReplaceAll(raw[start:end], ";", "&") + raw[end:]
 }
 
+// FilterQuery removes ignored parameters from a raw query string while
+// keeping the remaining pairs in their original order and encoding
+func FilterQuery(rawQuery string, ignore map[string]struct{}) string {
+	if rawQuery == "" {
+		return ""
+	}
+
+	pairs := strings.Split(rawQuery, "&")
+	kept := pairs[:0]
+	for _, pair := range pairs {
+		if pair == "" {
+			continue
+		}
+		key, _, _ := strings.Cut(pair, "=")
+		if decoded, err := url.QueryUnescape(key); err == nil {
+			key = decoded
+		}
+		if _, ignored := ignore[strings.ToLower(key)]; ignored {
+			continue
+		}
+		kept = append(kept, pair)
+	}
+	return strings.Join(kept, "&")
+}
+
+// DropParams deletes every parameter whose name is in the ignore set.
+// Names are already percent-decoded by url.Values, and are lowercased
+// before lookup to match the sets built by ParseSet, so %75tm_source and
+// UTM_Source are both removed by an ignore entry of utm_source.
+func DropParams(q url.Values, ignore map[string]struct{}) {
+	if len(ignore) == 0 {
+		return
+	}
+	for key := range q {
+		if _, ignored := ignore[strings.ToLower(key)]; ignored {
+			delete(q, key)
+		}
+	}
+}
+
+// DefaultStateParams lists query params that usually carry UI state in
+// single-page apps rather than select a distinct endpoint
+const DefaultStateParams = "tab,modal,dialog,panel,view,section,step,ref,page,sort,order"
+
 // ParseSet parses a comma-separated string into a set
 // Pre-allocates map with estimated size for better performance
 func ParseSet(s string) map[string]struct{} {
@@ -84,7 +172,7 @@ func ParseSet(s string) map[string]struct{} {
 
 // ExtractParams extracts and sorts parameter names from a URL
 func ExtractParams(rawURL string) (string, error) {
-	u, err := url.Parse(rawURL)
+	u, err := url.Parse(decodeHost(rawURL))
 	if err != nil {
 		return "", err
 	}
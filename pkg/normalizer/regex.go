@@ -0,0 +1,108 @@
+package normalizer
+
+import (
+	"errors"
+	"fmt"
+	"regexp/syntax"
+	"time"
+)
+
+// maxRegexInsts caps the compiled size of a user-supplied regex. Go regexes
+// run in linear time, but a huge program still makes every match slow.
+const maxRegexInsts = 5000
+
+// ErrRegexTimeout is returned when normalizing a URL with user-supplied
+// regexes takes longer than Config.RegexTimeout
+var ErrRegexTimeout = errors.New("regex timeout")
+
+// CheckRegex rejects user-supplied regexes that are obviously pathological:
+// a quantifier applied directly to another unbounded quantifier, as in
+// (a+)+ or (.*)*, or a pattern that compiles to an oversized program such
+// as nested counted repetitions.
+func CheckRegex(expr string) error {
+	re, err := syntax.Parse(expr, syntax.Perl)
+	if err != nil {
+		return err
+	}
+	if nestedQuantifier(re, false) {
+		return fmt.Errorf("regex %q nests unbounded quantifiers, e.g. (a+)+", expr)
+	}
+
+	prog, err := syntax.Compile(re.Simplify())
+	if err != nil {
+		return err
+	}
+	if len(prog.Inst) > maxRegexInsts {
+		return fmt.Errorf("regex %q is too large (%d instructions, max %d)", expr, len(prog.Inst), maxRegexInsts)
+	}
+	return nil
+}
+
+// nestedQuantifier reports whether re repeats an unbounded quantifier with
+// nothing else in between. inRepeat is set while walking the direct body
+// of a repetition.
+func nestedQuantifier(re *syntax.Regexp, inRepeat bool) bool {
+	switch re.Op {
+	case syntax.OpStar, syntax.OpPlus:
+		if inRepeat {
+			return true
+		}
+		return nestedQuantifier(re.Sub[0], true)
+	case syntax.OpRepeat:
+		if inRepeat && re.Max == -1 {
+			return true
+		}
+		return nestedQuantifier(re.Sub[0], re.Max == -1)
+	case syntax.OpCapture:
+		return nestedQuantifier(re.Sub[0], inRepeat)
+	}
+
+	for _, sub := range re.Sub {
+		if nestedQuantifier(sub, false) {
+			return true
+		}
+	}
+	return false
+}
+
+// hasCustomRegex reports whether normalization runs user-supplied regexes
+func (c *Config) hasCustomRegex() bool {
+	if c.KeyRegex != nil {
+		return true
+	}
+	if !c.FuzzyMode {
+		return false
+	}
+	for _, pattern := range c.FuzzyPatterns {
+		if pattern.Enabled && pattern.Name == "custom" {
+			return true
+		}
+	}
+	return false
+}
+
+// withRegexTimeout runs a normalization step in a goroutine and gives up
+// with ErrRegexTimeout after the configured timeout. Go cannot stop a
+// running match, so a timed-out step finishes in the background and its
+// result is discarded.
+func (c *Config) withRegexTimeout(step func() (string, string, error)) (string, string, error) {
+	type result struct {
+		key, normalized string
+		err             error
+	}
+
+	done := make(chan result, 1)
+	go func() {
+		key, normalized, err := step()
+		done <- result{key, normalized, err}
+	}()
+
+	timer := time.NewTimer(c.RegexTimeout)
+	defer timer.Stop()
+	select {
+	case r := <-done:
+		return r.key, r.normalized, r.err
+	case <-timer.C:
+		return "", "", fmt.Errorf("%w after %v", ErrRegexTimeout, c.RegexTimeout)
+	}
+}
@@ -3,7 +3,9 @@ package normalizer
 import (
 	"fmt"
 	"net/url"
+	"regexp"
 	"strings"
+	"time"
 
 	"github.com/lcalzada-xor/dupdurl/pkg/locale"
 )
@@ -27,6 +29,50 @@ type Config struct {
 	FilterExtensions map[string]struct{}
 	LocaleAware      bool     // Enable locale-aware deduplication
 	LocalePriority   []string // Priority order for locales (default: ["en"])
+	LocaleDetector   *locale.Detector // Detector used when LocaleAware (nil = locale.NewDetector())
+	FuzzyHostNumbers bool     // Collapse numbered host labels (web01 -> web{n}) in the dedup key
+	CollapseIDRuns   bool     // Merge consecutive fuzzy placeholders into a single {ids} segment
+	MaxPathSegments  int      // Drop URLs deeper than this many path segments (0 = no limit)
+	MinPathSegments  int      // Drop URLs shallower than this many path segments (0 = no limit)
+	CanonicalOutput  bool     // Emit the locale-stripped base URL instead of the first-seen variant
+	PathNoHost       bool     // In path mode, drop the host so identical paths collapse across hosts
+	KeepQueryOrder   bool     // Keep source param order in output; the dedup key stays order-insensitive
+	DropEmptyQuery   bool     // In path mode with PathIncludeQuery, drop "?" when no params remain
+	KeyRegex         *regexp.Regexp // Custom dedup key: applied to the raw line, bypassing normalization
+	KeyTemplate      string         // Replacement template for KeyRegex ($1, ${name}, ...)
+	IgnoreScheme     bool           // Treat http:// and https:// as the same URL (output uses https)
+	DedupValues      bool           // Sort repeated param values and drop duplicates (?t=b&t=a&t=b -> ?t=a&t=b)
+	CollapseAMP      bool           // Drop a standalone leading or trailing "amp" segment (/amp/x, /x/amp -> /x)
+	KeepFQDNDot      bool           // Keep a trailing dot on fully-qualified hosts (example.com. != example.com)
+	WWWApexOnly      bool           // Strip www. only before the registered domain (www.blog.example.com is kept)
+	StructuralPath   bool           // Replace every dynamic-looking path segment with {dyn}, with or without FuzzyMode
+
+	// StateParams are UI state params (tab, modal, ...) dropped from the
+	// dedup key only, so /dashboard?tab=1 collapses with /dashboard
+	StateParams map[string]struct{}
+
+	// PathRegisteredDomain, in path mode, keys on the registered domain
+	// instead of the full host, so api.example.com/users and
+	// www.example.com/users collapse. Ports are dropped too.
+	PathRegisteredDomain bool
+
+	// SemicolonQuery treats ';' in the query as a param separator, as
+	// older servers do (?a=1;b=2 is read as ?a=1&b=2)
+	SemicolonQuery bool
+
+	// KeepParamValues keeps query values in the url-mode dedup key, so
+	// /search?q=foo and /search?q=bar stay distinct. Expect far more
+	// unique URLs than with the default names-only key.
+	KeepParamValues bool
+
+	// IgnoreValueCase lowercases query values in the KeepParamValues key
+	// only, so ?color=Red and ?color=red collapse; output keeps the case
+	IgnoreValueCase bool
+
+	// RegexTimeout bounds the time spent normalizing one URL when
+	// user-supplied regexes (KeyRegex, custom fuzzy patterns) are in use;
+	// slower URLs fail with ErrRegexTimeout (0 = no limit)
+	RegexTimeout time.Duration
 }
 
 // NewConfig creates a default normalization configuration
@@ -52,6 +98,16 @@ func (c *Config) NormalizeURL(raw string) (string, error) {
 		raw = strings.TrimSpace(raw)
 	}
 
+	if normalized, ok := c.fastNormalizeURL(raw); ok {
+		return normalized, nil
+	}
+	return c.parseNormalizeURL(raw)
+}
+
+// parseNormalizeURL is NormalizeURL on a parsed URL, for every URL the fast
+// path does not handle
+func (c *Config) parseNormalizeURL(raw string) (string, error) {
+	raw = c.splitQuery(decodeHost(raw))
 	u, err := url.Parse(raw)
 	if err != nil {
 		return "", fmt.Errorf("parse error: %w", err)
@@ -67,35 +123,58 @@ func (c *Config) NormalizeURL(raw string) (string, error) {
 		return "", err
 	}
 
+	// Check path depth filtering
+	if err := c.checkPathDepth(u.Path); err != nil {
+		return "", err
+	}
+
+	// Rebuild from the locale-free base so every variant emits the same URL
+	if c.CanonicalOutput {
+		if base := c.stripLocale(raw); base != raw {
+			if u, err = url.Parse(base); err != nil {
+				return "", fmt.Errorf("parse error: %w", err)
+			}
+		}
+	}
+
 	// Normalize scheme
 	c.normalizeScheme(u)
 
 	// Normalize host
 	c.normalizeHost(u)
+	c.unifyScheme(u)
 
 	// Remove fragment
 	if c.IgnoreFragment {
 		u.Fragment = ""
 	}
 
-	// Normalize path
+	// Normalize path; a bare "?" carries no query and is dropped
 	u.Path = NormalizePath(u.Path)
+	u.ForceQuery = false
+
+	// Fold AMP variants into the canonical page
+	if c.CollapseAMP {
+		u.Path = CollapseAMP(u.Path)
+	}
 
 	// Apply fuzzy mode
-	if c.FuzzyMode {
-		if len(c.FuzzyPatterns) > 0 {
-			u.Path = ApplyFuzzyPatterns(u.Path, c.FuzzyPatterns)
-		} else {
-			u.Path = FuzzyPath(u.Path)
-		}
+	u.Path = c.fuzzPath(u.Path)
+
+	// Keep params exactly as the source ordered them
+	if c.KeepQueryOrder {
+		u.RawQuery = FilterQuery(u.RawQuery, c.IgnoreParams)
+		return c.urlString(u), nil
 	}
 
 	// Query params handling - keep values by default
 	q := u.Query()
 
 	// Delete ignored params
-	for p := range c.IgnoreParams {
-		q.Del(p)
+	DropParams(q, c.IgnoreParams)
+
+	if c.DedupValues {
+		DedupParamValues(q)
 	}
 
 	if c.SortParams {
@@ -104,64 +183,156 @@ func (c *Config) NormalizeURL(raw string) (string, error) {
 		u.RawQuery = q.Encode()
 	}
 
-	return u.String(), nil
+	return c.urlString(u), nil
 }
 
-// CreateDedupKey creates a key for deduplication (parameter names only, no values)
+// CreateDedupKey creates a key for deduplication (parameter names only, no
+// values, unless KeepParamValues is set)
 func (c *Config) CreateDedupKey(raw string) (string, error) {
 	if c.TrimSpaces {
 		raw = strings.TrimSpace(raw)
 	}
 
-	// Apply locale-aware normalization if enabled
-	if c.LocaleAware {
-		detector := locale.NewDetector()
-		localized, err := detector.Detect(raw)
-		if err == nil && localized.LocaleType != locale.LocaleTypeNone {
-			// Use the base URL (without locale) as the starting point
-			raw = localized.BaseURL
-		}
+	// A custom key replaces the built-in normalization entirely
+	if c.KeyRegex != nil {
+		return c.KeyRegex.ReplaceAllString(raw, c.KeyTemplate), nil
+	}
+
+	return c.keyURL(raw, c.KeepParamValues)
+}
+
+// keyURL normalizes a URL down to host, path and the names of the query
+// parameters present, dropping their values unless keepValues is set
+func (c *Config) keyURL(raw string, keepValues bool) (string, error) {
+	return c.traceKeyURL(raw, keepValues, nil)
+}
+
+// traceKeyURL is keyURL, calling trace (when not nil) with the URL as it
+// stands after each stage
+func (c *Config) traceKeyURL(raw string, keepValues bool, trace func(stage, value string)) (string, error) {
+	// Use the base URL (without locale) as the starting point
+	raw = c.stripLocale(c.splitQuery(decodeHost(raw)))
+	if trace != nil {
+		trace(StageLocale, raw)
 	}
 
 	u, err := url.Parse(raw)
 	if err != nil {
 		return "", fmt.Errorf("parse error: %w", err)
 	}
+	step := func(stage string) {
+		if trace != nil {
+			trace(stage, c.urlString(u))
+		}
+	}
 
 	// Apply same normalization
 	c.normalizeScheme(u)
+	step(StageScheme)
 	c.normalizeHost(u)
+	c.unifyScheme(u)
+
+	if c.FuzzyHostNumbers {
+		u.Host = fuzzHostNumbers(u.Host)
+	}
+	step(StageHost)
 
 	if c.IgnoreFragment {
 		u.Fragment = ""
 	}
 
 	u.Path = NormalizePath(u.Path)
+	u.ForceQuery = false
 
-	if c.FuzzyMode {
-		if len(c.FuzzyPatterns) > 0 {
-			u.Path = ApplyFuzzyPatterns(u.Path, c.FuzzyPatterns)
-		} else {
-			u.Path = FuzzyPath(u.Path)
-		}
+	if c.CollapseAMP {
+		u.Path = CollapseAMP(u.Path)
 	}
+	step(StagePath)
+	u.Path = c.fuzzPath(u.Path)
+	step(StageFuzzy)
 
 	// For the dedup key, we only keep parameter NAMES, not values
 	q := u.Query()
 
-	// Delete ignored params
-	for p := range c.IgnoreParams {
-		q.Del(p)
-	}
+	// Delete ignored params, and UI state params that only the key ignores
+	DropParams(q, c.IgnoreParams)
+	DropParams(q, c.StateParams)
 
-	// Build query string with param names only (no values)
-	if len(q) > 0 {
-		u.RawQuery = BuildKeyOnlyQuery(q)
-	} else {
+	// Build query string with param names only, or sorted names and values
+	switch {
+	case len(q) == 0:
 		u.RawQuery = ""
+	case keepValues:
+		if c.IgnoreValueCase {
+			LowerParamValues(q)
+		}
+		if c.DedupValues {
+			DedupParamValues(q)
+		}
+		u.RawQuery = BuildSortedQuery(q)
+	default:
+		u.RawQuery = BuildKeyOnlyQuery(q)
 	}
+	step(StageQuery)
+
+	return c.urlString(u), nil
+}
 
-	return u.String(), nil
+// NormalizeWithKey normalizes a line and returns its dedup key along with
+// the value to output
+func (c *Config) NormalizeWithKey(line string) (string, string, error) {
+	if c.RegexTimeout > 0 && c.hasCustomRegex() {
+		return c.withRegexTimeout(func() (string, string, error) {
+			return c.normalizeWithKey(line)
+		})
+	}
+	return c.normalizeWithKey(line)
+}
+
+// normalizeWithKey is NormalizeWithKey without the regex timeout
+func (c *Config) normalizeWithKey(line string) (string, string, error) {
+	normalized, err := c.NormalizeLine(line)
+	if err != nil {
+		return "", "", err
+	}
+
+	// Hash mode keys on the content hash and keeps the URL as the value
+	if c.Mode == "hash" {
+		_, hash, _ := SplitHashLine(line)
+		return hash, normalized, nil
+	}
+
+	// For URL mode (or a custom key), create separate dedup key (params without values)
+	// For other modes, use normalized value as both key and output
+	if c.Mode != "url" && c.KeyRegex == nil {
+		return c.modeKey(normalized), normalized, nil
+	}
+
+	key, err := c.CreateDedupKey(line)
+	if err != nil {
+		return "", "", err
+	}
+	return key, normalized, nil
+}
+
+// modeKey returns the dedup key for a host or path mode value, folding
+// numbered host labels when FuzzyHostNumbers is set; the output keeps the
+// host as seen, like in url mode
+func (c *Config) modeKey(normalized string) string {
+	if !c.FuzzyHostNumbers {
+		return normalized
+	}
+
+	switch c.Mode {
+	case "host":
+		return fuzzHostNumbers(normalized)
+	case "path":
+		// Path values are host + path, or just the path with PathNoHost
+		if host, path, ok := strings.Cut(normalized, "/"); ok && host != "" {
+			return fuzzHostNumbers(host) + "/" + path
+		}
+	}
+	return normalized
 }
 
 // NormalizeLine normalizes a line according to the mode
@@ -187,18 +358,93 @@ func (c *Config) NormalizeLine(line string) (string, error) {
 		return c.extractPath(line)
 
 	case "params":
-		return ExtractParams(line)
+		return ExtractParams(c.splitQuery(line))
+
+	case "presence":
+		return c.keyURL(line, false)
 
 	case "url":
 		return c.NormalizeURL(line)
 
+	case "hash":
+		raw, _, err := SplitHashLine(line)
+		if err != nil {
+			return "", err
+		}
+		return c.NormalizeURL(raw)
+
 	default:
 		return "", fmt.Errorf("unknown mode: %s", c.Mode)
 	}
 }
 
+// SplitHashLine splits a "URL<TAB>hash" line, as read in hash mode, into
+// the URL and its lowercased content hash
+func SplitHashLine(line string) (string, string, error) {
+	raw, hash, ok := strings.Cut(line, "\t")
+	raw, hash = strings.TrimSpace(raw), strings.ToLower(strings.TrimSpace(hash))
+	if !ok || raw == "" || hash == "" {
+		return "", "", fmt.Errorf("parse error: expected URL<TAB>hash")
+	}
+	return raw, hash, nil
+}
+
 // Helper methods
 
+// stripLocale returns the URL without its locale component when
+// locale-aware normalization is enabled
+func (c *Config) stripLocale(raw string) string {
+	if !c.LocaleAware {
+		return raw
+	}
+
+	detector := c.LocaleDetector
+	if detector == nil {
+		detector = locale.NewDetector()
+	}
+	localized, err := detector.Detect(raw)
+	if err == nil && localized.LocaleType != locale.LocaleTypeNone {
+		return localized.BaseURL
+	}
+	return raw
+}
+
+// urlString renders u with fuzzy placeholders left readable. url.URL
+// escapes the braces of /{id} as %7B and %7D, so they are restored in the
+// path when placeholders may be present.
+func (c *Config) urlString(u *url.URL) string {
+	s := u.String()
+	if !c.FuzzyMode && !c.StructuralPath {
+		return s
+	}
+
+	escaped := u.EscapedPath()
+	if readable := escapedPlaceholderRegex.ReplaceAllString(escaped, "{$1}"); readable != escaped {
+		s = strings.Replace(s, escaped, readable, 1)
+	}
+	return s
+}
+
+// fuzzPath applies the configured fuzzy patterns to a path
+func (c *Config) fuzzPath(p string) string {
+	if c.FuzzyMode {
+		if len(c.FuzzyPatterns) > 0 {
+			p = ApplyFuzzyPatterns(p, c.FuzzyPatterns)
+		} else {
+			p = FuzzyPath(p)
+		}
+	}
+
+	if c.StructuralPath {
+		p = StructuralPath(p)
+	}
+
+	if c.FuzzyMode && c.CollapseIDRuns {
+		p = CollapseIDRuns(p)
+	}
+	return p
+}
+
 func (c *Config) normalizeScheme(u *url.URL) {
 	if !c.CaseSensitive && !c.KeepScheme {
 		u.Scheme = strings.ToLower(u.Scheme)
@@ -207,27 +453,16 @@ func (c *Config) normalizeScheme(u *url.URL) {
 	}
 }
 
-func (c *Config) normalizeHost(u *url.URL) {
-	// Normalize case FIRST
-	if !c.CaseSensitive {
-		u.Host = strings.ToLower(u.Host)
-	}
-
-	// Remove default ports
-	if u.Scheme == "https" && strings.HasSuffix(u.Host, ":443") {
-		u.Host = strings.TrimSuffix(u.Host, ":443")
-	} else if u.Scheme == "http" && strings.HasSuffix(u.Host, ":80") {
-		u.Host = strings.TrimSuffix(u.Host, ":80")
-	}
-
-	// Remove www (after lowercasing)
-	if !c.KeepWWW && strings.HasPrefix(u.Host, "www.") {
-		u.Host = strings.TrimPrefix(u.Host, "www.")
+// unifyScheme maps http to https when IgnoreScheme is set. It runs after
+// host normalization so default ports are stripped for the original scheme.
+func (c *Config) unifyScheme(u *url.URL) {
+	if c.IgnoreScheme && strings.EqualFold(u.Scheme, "http") {
+		u.Scheme = "https"
 	}
 }
 
 func (c *Config) checkDomainFilters(host string) error {
-	normalizedHost := strings.ToLower(host)
+	normalizedHost := strings.TrimSuffix(strings.ToLower(host), ".")
 	if strings.HasPrefix(normalizedHost, "www.") {
 		normalizedHost = strings.TrimPrefix(normalizedHost, "www.")
 	}
@@ -248,9 +483,8 @@ func (c *Config) checkDomainFilters(host string) error {
 }
 
 func (c *Config) checkExtensionFilter(path string) error {
-	// Find the last dot in the path
-	lastDot := strings.LastIndex(path, ".")
-	if lastDot == -1 || lastDot == len(path)-1 {
+	ext := PathExtension(path)
+	if ext == "" {
 		// No extension found
 		if len(c.FilterExtensions) > 0 {
 			// Whitelist mode: reject URLs without extension
@@ -259,17 +493,6 @@ func (c *Config) checkExtensionFilter(path string) error {
 		return nil
 	}
 
-	// Extract extension (without the dot)
-	ext := strings.ToLower(path[lastDot+1:])
-
-	// Check if there's a slash after the dot (not a real extension)
-	if strings.Contains(ext, "/") {
-		if len(c.FilterExtensions) > 0 {
-			return fmt.Errorf("no valid extension found (whitelist mode requires extension)")
-		}
-		return nil
-	}
-
 	// Whitelist mode (FilterExtensions): only allow specified extensions
 	if len(c.FilterExtensions) > 0 {
 		if _, allowed := c.FilterExtensions[ext]; !allowed {
@@ -288,8 +511,32 @@ func (c *Config) checkExtensionFilter(path string) error {
 	return nil
 }
 
+func (c *Config) checkPathDepth(path string) error {
+	if c.MaxPathSegments <= 0 && c.MinPathSegments <= 0 {
+		return nil
+	}
+
+	depth := CountPathSegments(path)
+	if c.MaxPathSegments > 0 && depth > c.MaxPathSegments {
+		return fmt.Errorf("too many path segments: %d > %d", depth, c.MaxPathSegments)
+	}
+	if c.MinPathSegments > 0 && depth < c.MinPathSegments {
+		return fmt.Errorf("too few path segments: %d < %d", depth, c.MinPathSegments)
+	}
+
+	return nil
+}
+
+// splitQuery rewrites ';' query separators as '&' when SemicolonQuery is set
+func (c *Config) splitQuery(raw string) string {
+	if !c.SemicolonQuery {
+		return raw
+	}
+	return SplitSemicolonQuery(raw)
+}
+
 func (c *Config) extractHost(line string) (string, error) {
-	u, err := url.Parse(line)
+	u, err := url.Parse(decodeHost(line))
 	if err != nil {
 		if !c.CaseSensitive {
 			return strings.ToLower(line), nil
@@ -302,30 +549,15 @@ func (c *Config) extractHost(line string) (string, error) {
 		return "", err
 	}
 
-	h := u.Host
-
-	// Normalize case FIRST
-	if !c.CaseSensitive {
-		h = strings.ToLower(h)
-	}
-
-	// Remove default ports
-	if u.Scheme == "https" && strings.HasSuffix(h, ":443") {
-		h = strings.TrimSuffix(h, ":443")
-	} else if u.Scheme == "http" && strings.HasSuffix(h, ":80") {
-		h = strings.TrimSuffix(h, ":80")
-	}
-
-	// Remove www (after lowercasing)
-	if !c.KeepWWW && strings.HasPrefix(h, "www.") {
-		h = strings.TrimPrefix(h, "www.")
+	if err := c.checkPathDepth(u.Path); err != nil {
+		return "", err
 	}
 
-	return h, nil
+	return c.canonicalHost(u.Host, u.Scheme), nil
 }
 
 func (c *Config) extractPath(line string) (string, error) {
-	u, err := url.Parse(line)
+	u, err := url.Parse(c.splitQuery(decodeHost(line)))
 	if err != nil {
 		if !c.CaseSensitive {
 			return strings.ToLower(line), nil
@@ -338,51 +570,50 @@ func (c *Config) extractPath(line string) (string, error) {
 		return "", err
 	}
 
-	host := u.Host
-
-	// Normalize case FIRST
-	if !c.CaseSensitive {
-		host = strings.ToLower(host)
-	}
-
-	// Remove default ports
-	if u.Scheme == "https" && strings.HasSuffix(host, ":443") {
-		host = strings.TrimSuffix(host, ":443")
-	} else if u.Scheme == "http" && strings.HasSuffix(host, ":80") {
-		host = strings.TrimSuffix(host, ":80")
-	}
-
-	// Remove www (after lowercasing)
-	if !c.KeepWWW && strings.HasPrefix(host, "www.") {
-		host = strings.TrimPrefix(host, "www.")
+	if err := c.checkPathDepth(u.Path); err != nil {
+		return "", err
 	}
 
+	// Fuzzy patterns run before lowercasing, as in url mode, so the case
+	// of a segment still counts (StructuralPath's mixed-case rule)
 	path := NormalizePath(u.Path)
+	if c.CollapseAMP {
+		path = CollapseAMP(path)
+	}
+	path = c.fuzzPath(path)
 	if !c.CaseSensitive {
 		path = strings.ToLower(path)
 	}
-	if c.FuzzyMode {
-		if len(c.FuzzyPatterns) > 0 {
-			path = ApplyFuzzyPatterns(path, c.FuzzyPatterns)
-		} else {
-			path = FuzzyPath(path)
-		}
-	}
 
-	result := host + path
+	result := path
+	if c.PathRegisteredDomain {
+		result = RegisteredDomain(c.canonicalHost(u.Host, u.Scheme)) + path
+	} else if !c.PathNoHost {
+		result = c.canonicalHost(u.Host, u.Scheme) + path
+	}
 
-	// Optionally include normalized query
-	if c.PathIncludeQuery && u.RawQuery != "" {
-		q := u.Query()
-		for p := range c.IgnoreParams {
-			q.Del(p)
+	// Optionally include normalized query; one without parameters (?, ?&)
+	// is empty and dropped like in url mode
+	if q := u.Query(); c.PathIncludeQuery && len(q) > 0 {
+		// Path values are their own key, so UI state params go too
+		DropParams(q, c.IgnoreParams)
+		DropParams(q, c.StateParams)
+		if c.DedupValues {
+			DedupParamValues(q)
 		}
-		if c.SortParams {
-			result += "?" + BuildSortedQuery(q)
-		} else {
-			result += "?" + q.Encode()
+		if len(q) > 0 || !c.DropEmptyQuery {
+			if c.SortParams {
+				result += "?" + BuildSortedQuery(q)
+			} else {
+				result += "?" + q.Encode()
+			}
 		}
 	}
 
+	// Fragments are meaningful routes when the caller opted to keep them
+	if !c.IgnoreFragment && u.Fragment != "" {
+		result += "#" + u.Fragment
+	}
+
 	return result, nil
 }
@@ -4,7 +4,15 @@ import (
 	"encoding/csv"
 	"encoding/json"
 	"fmt"
+	"html/template"
 	"io"
+	"net/url"
+	"os"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
 
 	"github.com/lcalzada-xor/dupdurl/pkg/deduplicator"
 )
@@ -22,10 +30,24 @@ type TextFormatter struct {
 // Format writes entries as plain text
 func (f *TextFormatter) Format(entries []deduplicator.Entry, w io.Writer) error {
 	for _, entry := range entries {
+		line := entry.URL
+		if entry.Status != 0 {
+			line = fmt.Sprintf("[%d] %s", entry.Status, line)
+		}
+		if entry.Methods != "" {
+			line = entry.Methods + " " + line
+		}
+		if entry.Schemes != (deduplicator.SchemeCounts{}) {
+			line = fmt.Sprintf("%s http=%d https=%d", line, entry.Schemes.HTTP, entry.Schemes.HTTPS)
+		}
+		if entry.Variants > 0 {
+			line = fmt.Sprintf("%s variants=%d", line, entry.Variants)
+		}
+
 		if f.PrintCounts {
-			fmt.Fprintf(w, "%d %s\n", entry.Count, entry.URL)
+			fmt.Fprintf(w, "%d %s\n", entry.Count, line)
 		} else {
-			fmt.Fprintln(w, entry.URL)
+			fmt.Fprintln(w, line)
 		}
 	}
 	return nil
@@ -41,8 +63,29 @@ func (f *JSONFormatter) Format(entries []deduplicator.Entry, w io.Writer) error
 	return encoder.Encode(entries)
 }
 
+// NDJSONFormatter outputs URLs as newline-delimited JSON, one compact
+// object per line, so output can be streamed and appended
+type NDJSONFormatter struct{}
+
+// Format writes entries as one JSON object per line
+func (f *NDJSONFormatter) Format(entries []deduplicator.Entry, w io.Writer) error {
+	encoder := json.NewEncoder(w)
+	for _, entry := range entries {
+		if err := encoder.Encode(entry); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 // CSVFormatter outputs URLs as CSV
-type CSVFormatter struct{}
+type CSVFormatter struct {
+	OmitHeader bool     // Skip the header row, e.g. when appending to an existing file
+	Columns    []string // Extra columns computed per entry, from CSVColumns
+}
+
+// CSVColumns lists the extra columns CSVFormatter can compute per entry
+var CSVColumns = []string{"host", "path", "scheme", "params", "extension"}
 
 // Format writes entries as CSV
 func (f *CSVFormatter) Format(entries []deduplicator.Entry, w io.Writer) error {
@@ -50,13 +93,23 @@ func (f *CSVFormatter) Format(entries []deduplicator.Entry, w io.Writer) error {
 	defer writer.Flush()
 
 	// Write header
-	if err := writer.Write([]string{"url", "count"}); err != nil {
-		return err
+	if !f.OmitHeader {
+		header := append([]string{"url", "count"}, f.Columns...)
+		if err := writer.Write(header); err != nil {
+			return err
+		}
 	}
 
 	// Write data
 	for _, entry := range entries {
-		if err := writer.Write([]string{entry.URL, fmt.Sprintf("%d", entry.Count)}); err != nil {
+		record := []string{entry.URL, fmt.Sprintf("%d", entry.Count)}
+		if len(f.Columns) > 0 {
+			u := entryURL(entry.URL)
+			for _, col := range f.Columns {
+				record = append(record, csvColumn(u, col))
+			}
+		}
+		if err := writer.Write(record); err != nil {
 			return err
 		}
 	}
@@ -64,15 +117,342 @@ func (f *CSVFormatter) Format(entries []deduplicator.Entry, w io.Writer) error {
 	return nil
 }
 
+// entryURL parses an output entry, treating schemeless entries such as the
+// host + path output of path mode as host-relative
+func entryURL(entry string) *url.URL {
+	u, err := url.Parse(entry)
+	if err == nil && u.Host != "" {
+		return u
+	}
+	if !strings.HasPrefix(entry, "/") {
+		if hostRel, err := url.Parse("//" + entry); err == nil {
+			return hostRel
+		}
+	}
+	if err != nil {
+		return &url.URL{}
+	}
+	return u
+}
+
+// csvColumn computes one extra CSV column for a parsed entry
+func csvColumn(u *url.URL, col string) string {
+	switch col {
+	case "host":
+		return u.Hostname()
+	case "path":
+		return u.Path
+	case "scheme":
+		return u.Scheme
+	case "params":
+		return strconv.Itoa(len(u.Query()))
+	case "extension":
+		return strings.TrimPrefix(strings.ToLower(path.Ext(u.Path)), ".")
+	default:
+		return ""
+	}
+}
+
+// HTMLFormatter outputs entries as a self-contained HTML report: a summary
+// line and a table of URLs and counts, sortable by clicking a header
+type HTMLFormatter struct{}
+
+// htmlReport is the page written by HTMLFormatter. html/template escapes
+// every URL, so hostile input can't inject markup or script.
+var htmlReport = template.Must(template.New("report").Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<title>dupdurl report</title>
+<style>
+body { font-family: sans-serif; margin: 2em; }
+table { border-collapse: collapse; }
+th, td { border: 1px solid #ccc; padding: 4px 8px; text-align: left; }
+th { background: #eee; cursor: pointer; user-select: none; }
+td.count { text-align: right; }
+td.url { font-family: monospace; word-break: break-all; }
+</style>
+</head>
+<body>
+<p class="summary">{{len .Entries}} unique URLs ({{.Total}} occurrences)</p>
+<table id="results">
+<thead><tr><th data-type="text">URL</th><th data-type="number">Count</th></tr></thead>
+<tbody>
+{{- range .Entries}}
+<tr><td class="url">{{.URL}}</td><td class="count">{{.Count}}</td></tr>
+{{- end}}
+</tbody>
+</table>
+<script>
+document.querySelectorAll("#results th").forEach(function (th, col) {
+  var asc = true;
+  th.addEventListener("click", function () {
+    var tbody = document.querySelector("#results tbody");
+    var rows = Array.from(tbody.rows);
+    var numeric = th.dataset.type === "number";
+    rows.sort(function (a, b) {
+      var x = a.cells[col].textContent, y = b.cells[col].textContent;
+      var cmp = numeric ? x - y : x.localeCompare(y);
+      return asc ? cmp : -cmp;
+    });
+    asc = !asc;
+    rows.forEach(function (row) { tbody.appendChild(row); });
+  });
+});
+</script>
+</body>
+</html>
+`))
+
+// Format writes entries as an HTML report
+func (f *HTMLFormatter) Format(entries []deduplicator.Entry, w io.Writer) error {
+	total := 0
+	for _, entry := range entries {
+		total += entry.Count
+	}
+	return htmlReport.Execute(w, struct {
+		Entries []deduplicator.Entry
+		Total   int
+	}{entries, total})
+}
+
+// TemplateGroup is one fuzzed template with the concrete URLs it covers
+type TemplateGroup struct {
+	Template string   `json:"template"`
+	Count    int      `json:"count"`
+	Examples []string `json:"examples"`
+}
+
+// placeholderUnescaper restores fuzzy placeholders such as {id} that a URL
+// round trip escaped to %7Bid%7D
+var placeholderUnescaper = strings.NewReplacer("%7B", "{", "%7D", "}", "%7b", "{", "%7d", "}")
+
+// TemplateFormatter outputs entries as JSON template groups, nesting the
+// concrete examples tracked for each entry under its template
+type TemplateFormatter struct {
+	Examples map[string][]string // entry URL -> concrete examples
+}
+
+// Format writes entries as JSON template groups
+func (f *TemplateFormatter) Format(entries []deduplicator.Entry, w io.Writer) error {
+	groups := make([]TemplateGroup, 0, len(entries))
+	for _, entry := range entries {
+		examples := f.Examples[entry.URL]
+		if examples == nil {
+			examples = []string{}
+		}
+		groups = append(groups, TemplateGroup{
+			Template: placeholderUnescaper.Replace(entry.URL),
+			Count:    entry.Count,
+			Examples: examples,
+		})
+	}
+
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(groups)
+}
+
+// WriteCountsFile writes entries as "url,count" CSV to a sidecar file at
+// path, so counts can be kept apart from the main output
+func WriteCountsFile(entries []deduplicator.Entry, path string) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+
+	if err := (&CSVFormatter{}).Format(entries, f); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// Sort modes accepted by SortEntries
+const (
+	SortCount    = "count"     // Most occurrences first
+	SortCountAsc = "count-asc" // Fewest occurrences first
+	SortAlpha    = "alpha"     // Lexical by URL
+)
+
+// SortModes lists the valid SortEntries modes
+var SortModes = []string{SortCount, SortCountAsc, SortAlpha}
+
+// SortEntries returns a copy of entries ordered by mode, leaving the
+// caller's slice untouched. Ties keep first-seen order; an empty or unknown
+// mode returns the copy unsorted.
+func SortEntries(entries []deduplicator.Entry, mode string) []deduplicator.Entry {
+	sorted := make([]deduplicator.Entry, len(entries))
+	copy(sorted, entries)
+
+	var less func(a, b deduplicator.Entry) bool
+	switch mode {
+	case SortCount:
+		less = func(a, b deduplicator.Entry) bool { return a.Count > b.Count }
+	case SortCountAsc:
+		less = func(a, b deduplicator.Entry) bool { return a.Count < b.Count }
+	case SortAlpha:
+		less = func(a, b deduplicator.Entry) bool { return a.URL < b.URL }
+	default:
+		return sorted
+	}
+
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return less(sorted[i], sorted[j])
+	})
+	return sorted
+}
+
+// LineOriented reports whether a format writes one independent record per
+// line, so that output from several runs can be appended to one file
+func LineOriented(format string) bool {
+	return format == "text" || format == "csv" || format == "ndjson"
+}
+
+// OpenFile opens an output file for writing, truncating it or, when
+// appendMode is set, appending to it. existing reports whether the file
+// already held data, so callers can skip headers on append.
+func OpenFile(path string, appendMode bool) (f *os.File, existing bool, err error) {
+	flags := os.O_CREATE | os.O_WRONLY | os.O_TRUNC
+	if appendMode {
+		flags = os.O_CREATE | os.O_WRONLY | os.O_APPEND
+	}
+
+	f, err = os.OpenFile(path, flags, 0o644)
+	if err != nil {
+		return nil, false, err
+	}
+
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return nil, false, err
+	}
+	return f, info.Size() > 0, nil
+}
+
+// DomainCount is the number of unique entries under one registered domain
+type DomainCount struct {
+	Domain string `json:"domain"`
+	URLs   int    `json:"urls"`
+}
+
+// SummarizeDomains groups entries by the domain domainOf returns for each
+// entry URL, most populated domain first. Entries with no domain are skipped.
+func SummarizeDomains(entries []deduplicator.Entry, domainOf func(string) string) []DomainCount {
+	counts := make(map[string]int)
+	for _, entry := range entries {
+		if domain := domainOf(entry.URL); domain != "" {
+			counts[domain]++
+		}
+	}
+
+	summary := make([]DomainCount, 0, len(counts))
+	for domain, n := range counts {
+		summary = append(summary, DomainCount{Domain: domain, URLs: n})
+	}
+	sort.Slice(summary, func(i, j int) bool {
+		if summary[i].URLs != summary[j].URLs {
+			return summary[i].URLs > summary[j].URLs
+		}
+		return summary[i].Domain < summary[j].Domain
+	})
+	return summary
+}
+
+// WriteDomainSummary writes a domain summary as "count domain" lines
+func WriteDomainSummary(summary []DomainCount, w io.Writer) error {
+	for _, dc := range summary {
+		if _, err := fmt.Fprintf(w, "%d %s\n", dc.URLs, dc.Domain); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// WriteDuplicates writes removed duplicates with the entry each matched:
+// "line<TAB>representative" lines for text, line,key,representative rows for
+// csv, one object per line for ndjson, or a JSON array for json
+func WriteDuplicates(dups []deduplicator.Duplicate, format string, w io.Writer) error {
+	switch format {
+	case "json":
+		if dups == nil {
+			dups = []deduplicator.Duplicate{}
+		}
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(dups)
+	case "ndjson":
+		encoder := json.NewEncoder(w)
+		for _, dup := range dups {
+			if err := encoder.Encode(dup); err != nil {
+				return err
+			}
+		}
+		return nil
+	case "csv":
+		writer := csv.NewWriter(w)
+		if err := writer.Write([]string{"line", "key", "representative"}); err != nil {
+			return err
+		}
+		for _, dup := range dups {
+			if err := writer.Write([]string{dup.Line, dup.Key, dup.Representative}); err != nil {
+				return err
+			}
+		}
+		writer.Flush()
+		return writer.Error()
+	default:
+		for _, dup := range dups {
+			if _, err := fmt.Fprintf(w, "%s\t%s\n", dup.Line, dup.Representative); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+}
+
+// FormatterFactory builds a formatter for a given counts setting
+type FormatterFactory func(printCounts bool) Formatter
+
+var (
+	registryMu sync.RWMutex
+	registry   = make(map[string]FormatterFactory)
+)
+
+// RegisterFormatter makes a custom formatter available to GetFormatter under
+// name. Registering an existing name replaces it, including built-in formats.
+func RegisterFormatter(name string, factory FormatterFactory) {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+
+	if factory == nil {
+		delete(registry, name)
+		return
+	}
+	registry[name] = factory
+}
+
 // GetFormatter returns the appropriate formatter based on format string
 func GetFormatter(format string, printCounts bool) (Formatter, error) {
+	registryMu.RLock()
+	factory, ok := registry[format]
+	registryMu.RUnlock()
+	if ok {
+		return factory(printCounts), nil
+	}
+
 	switch format {
 	case "text":
 		return &TextFormatter{PrintCounts: printCounts}, nil
 	case "json":
 		return &JSONFormatter{}, nil
+	case "ndjson":
+		return &NDJSONFormatter{}, nil
 	case "csv":
 		return &CSVFormatter{}, nil
+	case "html":
+		return &HTMLFormatter{}, nil
 	default:
 		return nil, fmt.Errorf("unknown output format: %s", format)
 	}
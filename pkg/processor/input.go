@@ -0,0 +1,404 @@
+package processor
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ParseStatusAnnotation splits a status-annotated input line such as
+// "https://example.com/a [404]" or "[404] https://example.com/a" into the
+// URL and its status code. Lines without an annotation return status 0.
+func ParseStatusAnnotation(line string) (string, int) {
+	trimmed := strings.TrimSpace(line)
+
+	// Trailing annotation: url [404]
+	if strings.HasSuffix(trimmed, "]") {
+		if idx := strings.LastIndex(trimmed, "["); idx > 0 {
+			if status, ok := parseStatus(trimmed[idx+1 : len(trimmed)-1]); ok {
+				return strings.TrimSpace(trimmed[:idx]), status
+			}
+		}
+	}
+
+	// Leading annotation: [404] url
+	if strings.HasPrefix(trimmed, "[") {
+		if idx := strings.Index(trimmed, "]"); idx > 0 {
+			if status, ok := parseStatus(trimmed[1:idx]); ok {
+				return strings.TrimSpace(trimmed[idx+1:]), status
+			}
+		}
+	}
+
+	return line, 0
+}
+
+// parseStatus parses a three-digit HTTP status code
+func parseStatus(s string) (int, bool) {
+	if len(s) != 3 {
+		return 0, false
+	}
+	status, err := strconv.Atoi(s)
+	if err != nil || status < 100 || status > 599 {
+		return 0, false
+	}
+	return status, true
+}
+
+// ParseStatusSet parses a comma-separated list of status codes into a set
+func ParseStatusSet(s string) (map[int]struct{}, error) {
+	set := make(map[int]struct{})
+	for _, item := range strings.Split(s, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		status, ok := parseStatus(item)
+		if !ok {
+			return nil, fmt.Errorf("invalid status code: %s", item)
+		}
+		set[status] = struct{}{}
+	}
+	return set, nil
+}
+
+// inputLine is an input line with its status annotation removed
+type inputLine struct {
+	text   string
+	status int       // 0 when unannotated or status handling is off
+	method string    // "" when unprefixed or method handling is off
+	time   time.Time // zero when unprefixed or timestamp handling is off
+}
+
+// httpMethods are the verbs recognized by ParseMethodPrefix
+var httpMethods = map[string]bool{
+	"GET": true, "POST": true, "PUT": true, "DELETE": true, "PATCH": true,
+	"HEAD": true, "OPTIONS": true, "TRACE": true, "CONNECT": true,
+}
+
+// ParseMethodPrefix splits a method-prefixed input line such as
+// "POST https://example.com/a" into the uppercased method and the rest of
+// the line. Lines without a known method return "" and the line unchanged.
+func ParseMethodPrefix(line string) (string, string) {
+	trimmed := strings.TrimSpace(line)
+	idx := strings.IndexAny(trimmed, " \t")
+	if idx <= 0 {
+		return "", line
+	}
+
+	method := strings.ToUpper(trimmed[:idx])
+	if !httpMethods[method] {
+		return "", line
+	}
+	return method, strings.TrimSpace(trimmed[idx+1:])
+}
+
+// timestampLayouts are the leading timestamp formats ParseTimestampPrefix
+// accepts: RFC 3339, Wayback Machine capture times and plain dates
+var timestampLayouts = []string{time.RFC3339Nano, "20060102150405", "2006-01-02"}
+
+// ParseTimestampPrefix splits a timestamp-prefixed input line such as
+// "2024-05-01T10:00:00Z https://example.com/a" into the timestamp and the
+// rest of the line. Unix seconds are accepted too. Lines without a known
+// timestamp return the zero time and the line unchanged.
+func ParseTimestampPrefix(line string) (time.Time, string) {
+	trimmed := strings.TrimSpace(line)
+	idx := strings.IndexAny(trimmed, " \t")
+	if idx <= 0 {
+		return time.Time{}, line
+	}
+
+	field, rest := trimmed[:idx], strings.TrimSpace(trimmed[idx+1:])
+	for _, layout := range timestampLayouts {
+		if ts, err := time.Parse(layout, field); err == nil {
+			return ts, rest
+		}
+	}
+	if len(field) == 10 {
+		if secs, err := strconv.ParseInt(field, 10, 64); err == nil {
+			return time.Unix(secs, 0).UTC(), rest
+		}
+	}
+	return time.Time{}, line
+}
+
+// prepareLine strips a timestamp prefix, a method prefix and a status
+// annotation from an input line when their handling is enabled. It reports
+// false for lines whose status is excluded.
+func (c *Config) prepareLine(line string) (inputLine, bool) {
+	var ts time.Time
+	if c.KeepNewest {
+		ts, line = ParseTimestampPrefix(line)
+	}
+
+	var method string
+	if c.KeepMethod {
+		method, line = ParseMethodPrefix(line)
+	}
+
+	if len(c.ExcludeStatus) == 0 && !c.KeepStatus {
+		return inputLine{text: line, method: method, time: ts}, true
+	}
+
+	stripped, status := ParseStatusAnnotation(line)
+	if _, excluded := c.ExcludeStatus[status]; excluded {
+		return inputLine{}, false
+	}
+	return inputLine{text: stripped, status: status, method: method, time: ts}, true
+}
+
+// lineScanner reads input lines on its own goroutine, so a run can stop on
+// cancellation even while a Read blocks on an idle pipe or tail -f. A Read
+// that never returns keeps the goroutine alive until the process exits.
+type lineScanner struct {
+	lines chan string
+	errc  chan error // receives the read error, if any, before lines closes
+}
+
+// scanLines starts reading input line by line until EOF, a read error or
+// ctx is done
+func scanLines(ctx context.Context, input io.Reader) *lineScanner {
+	s := &lineScanner{lines: make(chan string), errc: make(chan error, 1)}
+	go func() {
+		defer close(s.lines)
+
+		scanner := bufio.NewScanner(input)
+		buf := make([]byte, 0, defaultBufferSize)
+		scanner.Buffer(buf, maxLineLength)
+		for scanner.Scan() {
+			select {
+			case s.lines <- scanner.Text():
+			case <-ctx.Done():
+				return
+			}
+		}
+		if err := scanner.Err(); err != nil {
+			s.errc <- err
+		}
+	}()
+	return s
+}
+
+// next returns the next line, or false at the end of input or once ctx is
+// done, whichever comes first
+func (s *lineScanner) next(ctx context.Context) (string, bool) {
+	select {
+	case line, ok := <-s.lines:
+		return line, ok
+	case <-ctx.Done():
+		return "", false
+	}
+}
+
+// Err returns the read error that ended the input, if any. Input cut short
+// by cancellation reports none.
+func (s *lineScanner) Err() error {
+	select {
+	case err := <-s.errc:
+		return fmt.Errorf("error reading input: %w", err)
+	default:
+		return nil
+	}
+}
+
+// LimitedInput reads at most a fixed number of bytes from an input, cut back
+// to the last complete line so a line split by the limit is never processed
+type LimitedInput struct {
+	src       io.Reader
+	limited   *io.LimitedReader
+	buf       []byte
+	ready     []byte // complete lines not yet returned
+	held      []byte // bytes after the last newline seen so far
+	done      bool
+	truncated bool
+}
+
+// NewLimitedInput wraps r so that at most limit bytes are read from it
+func NewLimitedInput(r io.Reader, limit int64) *LimitedInput {
+	return &LimitedInput{
+		src:     r,
+		limited: &io.LimitedReader{R: r, N: limit},
+		buf:     make([]byte, defaultBufferSize),
+	}
+}
+
+// Read implements io.Reader
+func (l *LimitedInput) Read(p []byte) (int, error) {
+	for len(l.ready) == 0 {
+		if l.done {
+			return 0, io.EOF
+		}
+
+		n, err := l.limited.Read(l.buf)
+		l.held = append(l.held, l.buf[:n]...)
+		if idx := bytes.LastIndexByte(l.held, '\n'); idx != -1 {
+			l.ready = append(l.ready, l.held[:idx+1]...)
+			l.held = append([]byte(nil), l.held[idx+1:]...)
+		}
+
+		if err == io.EOF {
+			l.finish()
+		} else if err != nil {
+			return 0, err
+		}
+	}
+
+	n := copy(p, l.ready)
+	l.ready = l.ready[n:]
+	return n, nil
+}
+
+// finish decides what to do with the trailing partial line once the limit
+// or the end of the input is reached. Only when the limit was used up exactly
+// is one byte past it peeked to tell the two apart; on a live pipe that read
+// blocks until more input arrives or the writer closes it.
+func (l *LimitedInput) finish() {
+	l.done = true
+
+	if l.limited.N > 0 {
+		// The input ended before the limit; the last line just lacks a newline
+		l.ready = append(l.ready, l.held...)
+		l.held = nil
+		return
+	}
+
+	var peek [1]byte
+	n, _ := io.ReadFull(l.src, peek[:])
+	if n == 0 {
+		// The whole input fit; the last line just lacks a newline
+		l.ready = append(l.ready, l.held...)
+	} else {
+		l.truncated = true
+		if peek[0] == '\n' {
+			// The limit fell right before a newline, so the line is complete
+			l.ready = append(l.ready, l.held...)
+		}
+	}
+	l.held = nil
+}
+
+// Truncated reports whether input past the limit was dropped. It is only
+// meaningful once Read has returned io.EOF.
+func (l *LimitedInput) Truncated() bool {
+	return l.truncated
+}
+
+// Decompress returns r decompressed when it starts with the gzip magic bytes,
+// and r unchanged otherwise. The check peeks, so plain input loses nothing.
+func Decompress(r io.Reader) (io.Reader, error) {
+	br := bufio.NewReader(r)
+	if magic, err := br.Peek(2); err == nil && magic[0] == 0x1f && magic[1] == 0x8b {
+		zr, err := gzip.NewReader(br)
+		if err != nil {
+			return nil, fmt.Errorf("gzip: %w", err)
+		}
+		return zr, nil
+	}
+	return br, nil
+}
+
+// OpenFiles opens input files and returns them as one stream, in order, as
+// if they had been concatenated. Gzip-compressed files are decompressed. A
+// missing final newline is supplied so the last line of one file never runs
+// into the next. Files that can't be opened are skipped (reported when
+// verbose); it fails only if none can be opened.
+func OpenFiles(paths []string, verbose bool) (io.ReadCloser, error) {
+	in := &fileInput{}
+	readers := make([]io.Reader, 0, len(paths))
+
+	var firstErr error
+	for _, path := range paths {
+		f, err := os.Open(path)
+		if err != nil {
+			if verbose {
+				fmt.Fprintf(os.Stderr, "Skipping input: %v\n", err)
+			}
+			if firstErr == nil {
+				firstErr = err
+			}
+			continue
+		}
+		r, err := Decompress(f)
+		if err != nil {
+			f.Close()
+			err = fmt.Errorf("%s: %w", path, err)
+			if verbose {
+				fmt.Fprintf(os.Stderr, "Skipping input: %v\n", err)
+			}
+			if firstErr == nil {
+				firstErr = err
+			}
+			continue
+		}
+		in.files = append(in.files, f)
+		readers = append(readers, &terminatedReader{r: r})
+	}
+
+	if len(in.files) == 0 && firstErr != nil {
+		return nil, fmt.Errorf("no readable input: %w", firstErr)
+	}
+
+	in.Reader = io.MultiReader(readers...)
+	return in, nil
+}
+
+// fileInput is the concatenated stream of the files opened by OpenFiles
+type fileInput struct {
+	io.Reader
+	files []*os.File
+}
+
+// Close closes every input file
+func (in *fileInput) Close() error {
+	var firstErr error
+	for _, f := range in.files {
+		if err := f.Close(); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
+}
+
+// terminatedReader passes a reader through, adding a final newline when
+// the data doesn't end with one
+type terminatedReader struct {
+	r       io.Reader
+	last    byte
+	pending bool // final newline still to be returned
+	eof     bool
+}
+
+// Read reads from the underlying reader, then the missing newline if any
+func (t *terminatedReader) Read(p []byte) (int, error) {
+	if !t.eof {
+		n, err := t.r.Read(p)
+		if n > 0 {
+			t.last = p[n-1]
+		}
+		if err != io.EOF {
+			return n, err
+		}
+		t.eof = true
+		t.pending = t.last != 0 && t.last != '\n'
+		if n > 0 {
+			return n, nil
+		}
+	}
+
+	if !t.pending {
+		return 0, io.EOF
+	}
+	if len(p) == 0 {
+		return 0, nil
+	}
+	p[0] = '\n'
+	t.pending = false
+	return 1, nil
+}
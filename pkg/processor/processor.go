@@ -1,17 +1,22 @@
 package processor
 
 import (
-	"bufio"
+	"context"
+	"errors"
 	"fmt"
 	"io"
+	"net/url"
 	"os"
 	"runtime"
 	"strings"
 	"sync"
+	"time"
 
 	"github.com/lcalzada-xor/dupdurl/pkg/deduplicator"
+	"github.com/lcalzada-xor/dupdurl/pkg/locale"
 	"github.com/lcalzada-xor/dupdurl/pkg/normalizer"
 	"github.com/lcalzada-xor/dupdurl/pkg/stats"
+	"github.com/lcalzada-xor/dupdurl/pkg/storage"
 )
 
 const (
@@ -21,10 +26,71 @@ const (
 
 // Config holds processor configuration
 type Config struct {
-	Normalizer *normalizer.Config
-	Workers    int
-	BatchSize  int
-	Verbose    bool
+	Normalizer      *normalizer.Config
+	Workers         int
+	BatchSize       int
+	Verbose         bool
+	MaxHostsPerPath int // With PathNoHost, split paths seen on more hosts than this (0 = off)
+	MaxExamples     int // Concrete (unfuzzed) inputs kept per entry as examples (0 = off)
+	MaxUnique       int // Evict lowest-count entries beyond this many (0 = unbounded, lossy)
+	MaxPerHost      int // Keep the first N unique entries per host, dropping the rest (0 = off)
+
+	// ExcludeStatus drops lines annotated with these status codes
+	// ("url [404]" or "[404] url"). Annotations are stripped when set.
+	ExcludeStatus map[int]struct{}
+
+	// KeepStatus carries status annotations through to the output entries
+	KeepStatus bool
+
+	// KeepMethod strips a leading HTTP method ("POST https://...") before
+	// deduplication and reports the methods seen for each entry
+	KeepMethod bool
+
+	// SchemeBreakdown counts how often each entry was seen over http and
+	// https; meant for keys that already ignore the scheme
+	SchemeBreakdown bool
+
+	// InputLimitBytes stops reading input after this many bytes, at the
+	// last complete line (0 = no limit)
+	InputLimitBytes int64
+
+	// DetailedStats records the domain, query parameters and extension of
+	// every accepted URL for the detailed statistics sections
+	DetailedStats bool
+
+	// LocaleCoverage groups every input by locale so LocaleCoverage can
+	// report the locales found per endpoint
+	LocaleCoverage bool
+
+	// LocalePreferShortestPath makes the coverage report pick the variant
+	// with the shortest base path when no priority locale is present
+	LocalePreferShortestPath bool
+
+	// KeepMostCommon represents each entry by its most common concrete
+	// (unfuzzed) variant instead of the first-seen URL
+	KeepMostCommon bool
+
+	// DistinctVariants reports how many distinct concrete (unfuzzed)
+	// variants, such as query value combinations, each entry was seen with
+	DistinctVariants bool
+
+	// KeepNewest strips a leading timestamp ("2024-05-01T10:00:00Z url")
+	// and represents each entry by its most recently timestamped variant
+	// instead of the first-seen URL
+	KeepNewest bool
+
+	// LocaleDedup represents each entry by its variant in the most
+	// preferred locale of Normalizer.LocalePriority instead of the
+	// first-seen URL
+	LocaleDedup bool
+
+	// ReportDuplicates records every duplicate occurrence so Duplicates can
+	// report what was removed and which entry it matched
+	ReportDuplicates bool
+
+	// Storage persists unique URLs outside the in-memory deduplicator when
+	// set. The host safeguard and fingerprints need the deduplicator.
+	Storage storage.Backend
 }
 
 // NewConfig creates a default processor configuration
@@ -42,72 +108,206 @@ type Processor struct {
 	config *Config
 	stats  *stats.Statistics
 	dedup  *deduplicator.Deduplicator
+
+	storeBase  int   // unique entries already in storage before processing
+	storeAdded int   // items written to storage during processing
+	storeErr   error // first storage write error from the collector
+
+	exampleNorm *normalizer.Config // normalizer without fuzzing, for examples
+	grouper     *locale.Grouper    // locale grouping for coverage reports
+
+	duplicates []deduplicator.Duplicate // removed occurrences, when reported
 }
 
 // New creates a new Processor instance
 func New(config *Config) *Processor {
 	st := stats.NewStatistics()
-	return &Processor{
+	dedup := deduplicator.New(st)
+	dedup.SetMaxHostsPerKey(config.MaxHostsPerPath)
+	dedup.SetMaxExamples(config.MaxExamples)
+	dedup.SetMaxUnique(config.MaxUnique)
+	dedup.SetMaxPerHost(config.MaxPerHost)
+	dedup.SetKeepMostCommon(config.KeepMostCommon)
+	dedup.SetKeepNewest(config.KeepNewest)
+	dedup.SetCountVariants(config.DistinctVariants)
+	if config.LocaleDedup {
+		dedup.SetLocalePreference(config.Normalizer.LocalePriority, config.Normalizer.LocaleDetector)
+	}
+
+	p := &Processor{
 		config: config,
 		stats:  st,
-		dedup:  deduplicator.New(st),
+		dedup:  dedup,
+	}
+	if config.MaxExamples > 0 || config.KeepMostCommon || config.DistinctVariants {
+		unfuzzed := *config.Normalizer
+		unfuzzed.FuzzyMode = false
+		unfuzzed.StructuralPath = false
+		p.exampleNorm = &unfuzzed
+	}
+	if config.ReportDuplicates {
+		dedup.SetOnDuplicate(func(dup deduplicator.Duplicate) {
+			p.duplicates = append(p.duplicates, dup)
+		})
+	}
+	if config.LocaleCoverage {
+		detector := config.Normalizer.LocaleDetector
+		if detector == nil {
+			detector = locale.NewDetector()
+		}
+		p.grouper = locale.NewGrouperWithDetector(config.Normalizer.LocalePriority, detector)
+		p.grouper.PreferShortestPath = config.LocalePreferShortestPath
 	}
+	return p
 }
 
 // Process reads URLs from input and returns deduplicated entries
 func (p *Processor) Process(input io.Reader) ([]deduplicator.Entry, error) {
+	return p.ProcessContext(context.Background(), input)
+}
+
+// ProcessContext is Process, checking ctx between input lines. Once ctx is
+// done it stops reading and returns the entries deduplicated so far along
+// with ctx.Err().
+func (p *Processor) ProcessContext(ctx context.Context, input io.Reader) ([]deduplicator.Entry, error) {
+	if p.config.Storage != nil {
+		p.storeBase = p.config.Storage.Count()
+	}
+
+	var limited *LimitedInput
+	if p.config.InputLimitBytes > 0 {
+		limited = NewLimitedInput(input, p.config.InputLimitBytes)
+		input = limited
+	}
+
+	var entries []deduplicator.Entry
+	var err error
 	if p.config.Workers > 1 {
-		return p.processParallel(input)
+		entries, err = p.processParallel(ctx, input)
+	} else {
+		entries, err = p.processSequential(ctx, input)
 	}
-	return p.processSequential(input)
+
+	if limited != nil && limited.Truncated() {
+		p.stats.InputTruncated = true
+	}
+	if err == nil {
+		err = ctx.Err()
+	}
+	return entries, err
+}
+
+// DeduplicateSlice deduplicates an already collected list of URLs, one per
+// element, with the same normalization and deduplication as Process, and
+// returns the entries along with the run's statistics. Elements are
+// processed in order on the calling goroutine, so Workers, BatchSize and
+// InputLimitBytes are ignored; results match Process on the joined lines.
+func DeduplicateSlice(urls []string, cfg *Config) ([]deduplicator.Entry, *stats.Statistics, error) {
+	p := New(cfg)
+	if cfg.Storage != nil {
+		p.storeBase = cfg.Storage.Count()
+	}
+
+	for i, line := range urls {
+		if err := p.processLine(i+1, line); err != nil {
+			return nil, p.stats, err
+		}
+	}
+
+	p.stats.Finish()
+	entries, err := p.results()
+	if err != nil {
+		return nil, p.stats, err
+	}
+	return entries, p.stats, nil
+}
+
+// ProcessFiles reads URLs from several files as one concatenated input and
+// returns deduplicated entries. Unreadable files are skipped; see OpenFiles.
+func (p *Processor) ProcessFiles(paths []string) ([]deduplicator.Entry, error) {
+	return p.ProcessFilesContext(context.Background(), paths)
+}
+
+// ProcessFilesContext is ProcessFiles, stopping early like ProcessContext
+func (p *Processor) ProcessFilesContext(ctx context.Context, paths []string) ([]deduplicator.Entry, error) {
+	input, err := OpenFiles(paths, p.config.Verbose)
+	if err != nil {
+		return nil, err
+	}
+	defer input.Close()
+
+	return p.ProcessContext(ctx, input)
 }
 
 // processSequential processes URLs sequentially (original behavior)
-func (p *Processor) processSequential(input io.Reader) ([]deduplicator.Entry, error) {
-	scanner := bufio.NewScanner(input)
-	buf := make([]byte, 0, defaultBufferSize)
-	scanner.Buffer(buf, maxLineLength)
+func (p *Processor) processSequential(ctx context.Context, input io.Reader) ([]deduplicator.Entry, error) {
+	// The reader goroutine stops on a storage error too
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	scanner := scanLines(ctx, input)
 
 	lineNum := 0
-	for scanner.Scan() {
+	for {
+		line, ok := scanner.next(ctx)
+		if !ok {
+			break
+		}
 		lineNum++
-		line := scanner.Text()
-		p.stats.TotalProcessed++
-
-		if p.config.Normalizer.TrimSpaces && strings.TrimSpace(line) == "" {
-			continue
+		if err := p.processLine(lineNum, line); err != nil {
+			return nil, err
 		}
+	}
 
-		// Normalize according to mode
-		normalized, err := p.config.Normalizer.NormalizeLine(line)
-		if err != nil {
-			p.handleError(lineNum, line, err)
-			continue
-		}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
 
-		// For URL mode, create separate dedup key (params without values)
-		// For other modes, use normalized value as both key and output
-		var key string
-		if p.config.Normalizer.Mode == "url" {
-			key, err = p.config.Normalizer.CreateDedupKey(line)
-			if err != nil {
-				p.handleError(lineNum, line, err)
-				continue
-			}
-		} else {
-			key = normalized
-		}
+	p.stats.Finish()
+	return p.results()
+}
+
+// processLine normalizes and deduplicates a single input line. Only storage
+// errors are returned; bad lines are counted in the statistics.
+func (p *Processor) processLine(lineNum int, line string) error {
+	p.stats.RecordProcessed()
 
-		// Add to deduplicator
-		p.dedup.Add(key, normalized)
+	if p.config.Normalizer.TrimSpaces && strings.TrimSpace(line) == "" {
+		return nil
 	}
 
-	if err := scanner.Err(); err != nil {
-		return nil, fmt.Errorf("error reading input: %w", err)
+	in, ok := p.config.prepareLine(line)
+	if !ok {
+		p.stats.RecordFiltered()
+		return nil
 	}
+	line = in.text
 
-	p.stats.Finish()
-	return p.dedup.GetEntries(), nil
+	// Normalize according to mode
+	key, normalized, err := p.config.Normalizer.NormalizeWithKey(line)
+	if err != nil {
+		p.handleError(lineNum, line, err)
+		return nil
+	}
+
+	// Add to deduplicator
+	item := deduplicator.Item{
+		Key:     key,
+		URL:     normalized,
+		Host:    p.contributingHost(line),
+		Example: p.example(line),
+		Status:  in.status,
+		Method:  in.method,
+		Scheme:  p.config.scheme(line),
+		Line:    line,
+		CapHost: p.capHost(line),
+		Time:    in.time,
+	}
+	if err := p.add(item); err != nil {
+		return err
+	}
+	p.trackLocale(line)
+	p.config.recordDetails(p.stats, normalized, line)
+	return nil
 }
 
 // processedURL represents a URL that has been processed
@@ -116,12 +316,19 @@ type processedURL struct {
 	originalLine  string
 	dedupKey      string
 	normalizedURL string
+	host          string
+	example       string
+	status        int
+	method        string
+	scheme        string
+	capHost       string
+	time          time.Time
 	err           error
 }
 
 // processParallel processes URLs in parallel using worker pool
-func (p *Processor) processParallel(input io.Reader) ([]deduplicator.Entry, error) {
-	jobs := make(chan string, p.config.BatchSize)
+func (p *Processor) processParallel(ctx context.Context, input io.Reader) ([]deduplicator.Entry, error) {
+	jobs := make(chan inputLine, p.config.BatchSize)
 	results := make(chan processedURL, p.config.BatchSize)
 
 	// Start workers
@@ -136,21 +343,28 @@ func (p *Processor) processParallel(input io.Reader) ([]deduplicator.Entry, erro
 	go p.collector(results, done)
 
 	// Read and send jobs
-	scanner := bufio.NewScanner(input)
-	buf := make([]byte, 0, defaultBufferSize)
-	scanner.Buffer(buf, maxLineLength)
+	scanner := scanLines(ctx, input)
 
 	lineNum := 0
-	for scanner.Scan() {
+	for {
+		line, ok := scanner.next(ctx)
+		if !ok {
+			break
+		}
 		lineNum++
-		line := scanner.Text()
-		p.stats.TotalProcessed++
+		p.stats.RecordProcessed()
 
 		if p.config.Normalizer.TrimSpaces && strings.TrimSpace(line) == "" {
 			continue
 		}
 
-		jobs <- line
+		in, ok := p.config.prepareLine(line)
+		if !ok {
+			p.stats.RecordFiltered()
+			continue
+		}
+
+		jobs <- in
 	}
 
 	close(jobs)
@@ -159,69 +373,221 @@ func (p *Processor) processParallel(input io.Reader) ([]deduplicator.Entry, erro
 	<-done
 
 	if err := scanner.Err(); err != nil {
-		return nil, fmt.Errorf("error reading input: %w", err)
+		return nil, err
+	}
+
+	if p.storeErr != nil {
+		return nil, p.storeErr
 	}
 
 	p.stats.Finish()
-	return p.dedup.GetEntries(), nil
+	return p.results()
 }
 
 // worker processes URLs from the jobs channel
-func (p *Processor) worker(wg *sync.WaitGroup, jobs <-chan string, results chan<- processedURL) {
+func (p *Processor) worker(wg *sync.WaitGroup, jobs <-chan inputLine, results chan<- processedURL) {
 	defer wg.Done()
 
 	lineNum := 0
-	for line := range jobs {
+	for in := range jobs {
 		lineNum++
+		line := in.text
 
 		// Normalize according to mode
-		normalized, err := p.config.Normalizer.NormalizeLine(line)
+		key, normalized, err := p.config.Normalizer.NormalizeWithKey(line)
 		if err != nil {
 			results <- processedURL{lineNum: lineNum, originalLine: line, err: err}
 			continue
 		}
 
-		// For URL mode, create separate dedup key (params without values)
-		// For other modes, use normalized value as both key and output
-		var key string
-		if p.config.Normalizer.Mode == "url" {
-			key, err = p.config.Normalizer.CreateDedupKey(line)
-			if err != nil {
-				results <- processedURL{lineNum: lineNum, originalLine: line, err: err}
-				continue
-			}
-		} else {
-			key = normalized
-		}
-
 		results <- processedURL{
 			lineNum:       lineNum,
 			originalLine:  line,
 			dedupKey:      key,
 			normalizedURL: normalized,
+			host:          p.contributingHost(line),
+			example:       p.example(line),
+			status:        in.status,
+			method:        in.method,
+			scheme:        p.config.scheme(line),
+			capHost:       p.capHost(line),
+			time:          in.time,
 		}
 	}
 }
 
-// collector collects results from workers
+// collector collects results from workers. It runs on a single goroutine,
+// so storage and locale state need no locking; the deduplicator locks itself.
 func (p *Processor) collector(results <-chan processedURL, done chan<- struct{}) {
-	// Need mutex for parallel access to deduplicator
-	var mu sync.Mutex
-
 	for result := range results {
 		if result.err != nil {
 			p.handleError(result.lineNum, result.originalLine, result.err)
 			continue
 		}
 
-		mu.Lock()
-		p.dedup.Add(result.dedupKey, result.normalizedURL)
-		mu.Unlock()
+		err := p.add(deduplicator.Item{
+			Key:     result.dedupKey,
+			URL:     result.normalizedURL,
+			Host:    result.host,
+			Example: result.example,
+			Status:  result.status,
+			Method:  result.method,
+			Scheme:  result.scheme,
+			Line:    result.originalLine,
+			CapHost: result.capHost,
+			Time:    result.time,
+		})
+		if err != nil && p.storeErr == nil {
+			p.storeErr = err
+		}
+		p.trackLocale(result.originalLine)
+		p.config.recordDetails(p.stats, result.normalizedURL, result.originalLine)
 	}
 
 	done <- struct{}{}
 }
 
+// add records an item in the storage backend, or in the in-memory
+// deduplicator when no backend is configured
+func (p *Processor) add(item deduplicator.Item) error {
+	if p.config.Storage == nil {
+		p.dedup.AddItem(item)
+		return nil
+	}
+
+	p.storeAdded++
+	if err := p.config.Storage.Add(item.Key, item.URL); err != nil {
+		return fmt.Errorf("storage error: %w", err)
+	}
+	return nil
+}
+
+// results returns the final entries and, for storage backends, derives the
+// unique/duplicate statistics from the backend's growth
+func (p *Processor) results() ([]deduplicator.Entry, error) {
+	if p.config.Storage == nil {
+		return p.dedup.GetEntries(), nil
+	}
+
+	unique := p.config.Storage.Count() - p.storeBase
+	p.stats.UniqueURLs += unique
+	p.stats.Duplicates += p.storeAdded - unique
+
+	return p.config.Storage.GetEntries()
+}
+
+// Seed pre-populates the storage backend with baseline entries so new input
+// is deduplicated against them. In url mode entries are keyed with the
+// current normalization settings and ones it would filter out are skipped;
+// other modes already print keys, which are seeded as is, like ExportSQLite.
+func (p *Processor) Seed(entries []deduplicator.Entry) error {
+	if p.config.Storage == nil {
+		return fmt.Errorf("seeding requires a storage backend")
+	}
+
+	records := make([]storage.Record, 0, len(entries))
+	for _, entry := range entries {
+		if p.config.Normalizer.Mode != "url" {
+			records = append(records, storage.Record{Key: entry.URL, URL: entry.URL, Count: entry.Count})
+			continue
+		}
+
+		key, normalized, err := p.config.Normalizer.NormalizeWithKey(entry.URL)
+		if err != nil {
+			continue
+		}
+
+		records = append(records, storage.Record{Key: key, URL: normalized, Count: entry.Count})
+	}
+
+	return p.config.Storage.AddBatch(records)
+}
+
+// contributingHost returns the host to track for the cross-host safeguard,
+// or "" when the safeguard is off
+func (p *Processor) contributingHost(line string) string {
+	if p.config.MaxHostsPerPath <= 0 || !p.config.Normalizer.PathNoHost {
+		return ""
+	}
+	return p.config.Normalizer.HostOf(line)
+}
+
+// capHost returns the host to count against the per-host cap, or "" when
+// the cap is off
+func (p *Processor) capHost(line string) string {
+	if p.config.MaxPerHost <= 0 {
+		return ""
+	}
+	return p.config.Normalizer.HostOf(line)
+}
+
+// scheme returns the scheme of an input line to count per entry, or ""
+// when SchemeBreakdown is off
+func (c *Config) scheme(line string) string {
+	if !c.SchemeBreakdown {
+		return ""
+	}
+	scheme, _, found := strings.Cut(strings.TrimSpace(line), "://")
+	if !found {
+		return ""
+	}
+	return strings.ToLower(scheme)
+}
+
+// recordDetails records the domain, query parameters and extension of an
+// accepted URL when DetailedStats is on. The normalized value is used when
+// it is a URL (url mode); other modes fall back to the input line.
+func (c *Config) recordDetails(st *stats.Statistics, normalized, line string) {
+	if !c.DetailedStats {
+		return
+	}
+
+	u, err := url.Parse(normalized)
+	if err != nil || u.Host == "" {
+		if u, err = url.Parse(strings.TrimSpace(line)); err != nil || u.Host == "" {
+			return
+		}
+	}
+
+	st.RecordDomain(u.Hostname())
+	for param := range u.Query() {
+		st.RecordParam(param)
+	}
+	if ext := normalizer.PathExtension(u.Path); ext != "" {
+		st.RecordExtension(ext)
+	}
+}
+
+// trackLocale adds an accepted line to the locale grouper when coverage
+// reporting is on
+func (p *Processor) trackLocale(line string) {
+	if p.grouper != nil {
+		p.grouper.Add(strings.TrimSpace(line))
+	}
+}
+
+// LocaleCoverage returns the locales found for each endpoint, or nil when
+// coverage reporting is off
+func (p *Processor) LocaleCoverage() []locale.Coverage {
+	if p.grouper == nil {
+		return nil
+	}
+	return p.grouper.Coverage()
+}
+
+// example returns the unfuzzed form of a line to keep as an entry example,
+// or "" when example tracking is off
+func (p *Processor) example(line string) string {
+	if p.exampleNorm == nil {
+		return ""
+	}
+	concrete, err := p.exampleNorm.NormalizeLine(line)
+	if err != nil {
+		return ""
+	}
+	return concrete
+}
+
 // handleError handles processing errors
 func (p *Processor) handleError(lineNum int, line string, err error) {
 	if p.config.Verbose && line != "" {
@@ -229,16 +595,33 @@ func (p *Processor) handleError(lineNum int, line string, err error) {
 	}
 
 	errMsg := err.Error()
-	if strings.Contains(errMsg, "parse error") {
-		p.stats.ParseErrors++
+	if strings.Contains(errMsg, "parse error") || errors.Is(err, normalizer.ErrRegexTimeout) {
+		p.stats.RecordParseError()
 	} else if strings.Contains(errMsg, "ignored extension") ||
 		strings.Contains(errMsg, "blacklist") ||
 		strings.Contains(errMsg, "whitelist") ||
-		strings.Contains(errMsg, "domain") {
-		p.stats.Filtered++
+		strings.Contains(errMsg, "domain") ||
+		strings.Contains(errMsg, "path segments") {
+		p.stats.RecordFiltered()
 	}
 }
 
+// FingerprintKeys returns a stable hash of the dedup keys behind entries
+func (p *Processor) FingerprintKeys(entries []deduplicator.Entry) string {
+	return p.dedup.FingerprintKeys(entries)
+}
+
+// Duplicates returns the duplicate occurrences removed so far, in the order
+// they were deduplicated, or nil when ReportDuplicates is off
+func (p *Processor) Duplicates() []deduplicator.Duplicate {
+	return p.duplicates
+}
+
+// Examples returns the concrete examples tracked for each entry URL
+func (p *Processor) Examples() map[string][]string {
+	return p.dedup.Examples()
+}
+
 // GetStatistics returns the processor statistics
 func (p *Processor) GetStatistics() *stats.Statistics {
 	return p.stats
@@ -1,9 +1,12 @@
 package processor
 
 import (
-	"bufio"
+	"bytes"
+	"context"
 	"fmt"
+	"hash/fnv"
 	"io"
+	"os"
 	"strings"
 	"sync"
 	"time"
@@ -13,13 +16,34 @@ import (
 	"github.com/lcalzada-xor/dupdurl/pkg/stats"
 )
 
+// Flush modes select which triggers end a streaming window. The remaining
+// entries are always flushed when input ends.
+const (
+	FlushBoth = "both" // Flush on buffer size or interval, whichever comes first
+	FlushSize = "size" // Flush only when the buffer is full
+	FlushTime = "time" // Flush only on the interval; the buffer grows unbounded
+)
+
+// writeQueue is how many finalized windows may wait for the writer while
+// processing continues
+const writeQueue = 4
+
 // StreamingConfig holds streaming processor configuration
 type StreamingConfig struct {
 	*Config
 	FlushInterval time.Duration // Flush every N seconds
 	MaxBuffer     int           // Max entries before forced flush
+	FlushMode     string        // FlushBoth, FlushSize or FlushTime
 	Output        output.Formatter
 	OutputWriter  io.Writer
+
+	// GlobalDedup emits each key only in the first window it appears in;
+	// later occurrences are counted as duplicates but never output again.
+	// It relies on the 64-bit hash kept per distinct key for
+	// CumulativeUnique, so memory still grows with the number of distinct
+	// keys (about 8 bytes plus map overhead each, never freed), and a hash
+	// collision can, very rarely, hide a new URL.
+	GlobalDedup bool
 }
 
 // NewStreamingConfig creates a default streaming configuration
@@ -28,14 +52,40 @@ func NewStreamingConfig() *StreamingConfig {
 		Config:        NewConfig(),
 		FlushInterval: 5 * time.Second,
 		MaxBuffer:     10000,
+		FlushMode:     FlushBoth,
 	}
 }
 
+// flushOnSize reports whether a full buffer triggers a flush
+func (c *StreamingConfig) flushOnSize() bool {
+	return c.FlushMode != FlushTime
+}
+
+// flushOnTime reports whether the interval triggers a flush
+func (c *StreamingConfig) flushOnTime() bool {
+	return c.FlushMode != FlushSize
+}
+
 // StreamingProcessor handles streaming URL processing with periodic flushes
 type StreamingProcessor struct {
 	config *StreamingConfig
 	stats  *stats.Statistics
 	mu     sync.Mutex
+
+	// seen holds a hash of every key flushed so far, so uniqueness can be
+	// tracked across windows without keeping the keys themselves in memory
+	seen map[uint64]struct{}
+
+	// window holds the hashes of keys added to the current window, so
+	// GlobalDedup can tell them apart from keys already flushed
+	window map[uint64]struct{}
+
+	// windows feeds finalized windows, in flush order, to the single writer
+	// goroutine; writeErr holds the first error it hit
+	windows    chan []deduplicator.Entry
+	writerDone chan struct{}
+	writeMu    sync.Mutex
+	writeErr   error
 }
 
 // NewStreaming creates a new StreamingProcessor instance
@@ -43,89 +93,136 @@ func NewStreaming(config *StreamingConfig) *StreamingProcessor {
 	return &StreamingProcessor{
 		config: config,
 		stats:  stats.NewStatistics(),
+		seen:   make(map[uint64]struct{}),
+		window: make(map[uint64]struct{}),
 	}
 }
 
 // ProcessStreaming processes URLs in streaming mode with periodic flushes
 // This allows processing infinite datasets without loading everything in memory
 func (sp *StreamingProcessor) ProcessStreaming(input io.Reader) error {
-	scanner := bufio.NewScanner(input)
-	buf := make([]byte, 0, defaultBufferSize)
-	scanner.Buffer(buf, maxLineLength)
+	return sp.ProcessStreamingContext(context.Background(), input)
+}
+
+// ProcessStreamingContext is ProcessStreaming, checking ctx between input
+// lines. Once ctx is done it stops reading, flushes the current window and
+// returns ctx.Err().
+func (sp *StreamingProcessor) ProcessStreamingContext(ctx context.Context, input io.Reader) (err error) {
+	sp.startWriter()
+	defer func() {
+		if werr := sp.stopWriter(); err == nil {
+			err = werr
+		}
+	}()
+
+	if sp.config.InputLimitBytes > 0 {
+		limited := NewLimitedInput(input, sp.config.InputLimitBytes)
+		defer func() {
+			sp.stats.InputTruncated = limited.Truncated()
+		}()
+		input = limited
+	}
+
+	// The reader and ticker goroutines stop on every return, early ones
+	// included
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	scanner := scanLines(ctx, input)
 
 	// Create temporary deduplicator for current window
 	dedup := deduplicator.New(sp.stats)
 
-	// Setup periodic flush ticker
-	ticker := time.NewTicker(sp.config.FlushInterval)
-	defer ticker.Stop()
-
 	// Channel for flush signals
 	flushChan := make(chan struct{}, 1)
 	done := make(chan struct{})
+	defer close(done)
 
 	// Goroutine to handle periodic flushes
-	go func() {
-		for {
-			select {
-			case <-ticker.C:
-				flushChan <- struct{}{}
-			case <-done:
-				return
+	if sp.config.flushOnTime() {
+		ticker := time.NewTicker(sp.config.FlushInterval)
+		defer ticker.Stop()
+
+		go func() {
+			for {
+				select {
+				case <-ticker.C:
+					// A pending signal already covers this tick
+					select {
+					case flushChan <- struct{}{}:
+					default:
+					}
+				case <-done:
+					return
+				}
 			}
-		}
-	}()
+		}()
+	}
 
+	// Waiting on the lines, the flush signal and ctx together lets periodic
+	// flushes and Ctrl-C through while the input is idle
 	lineNum := 0
-	for scanner.Scan() {
+read:
+	for {
+		var line string
+		select {
+		case <-ctx.Done():
+			break read
+		case <-flushChan:
+			if dedup.Count() > 0 {
+				if err := sp.flush(dedup); err != nil {
+					return err
+				}
+				dedup = deduplicator.New(sp.stats) // Reset window
+			}
+			continue
+		case next, ok := <-scanner.lines:
+			if !ok {
+				break read
+			}
+			line = next
+		}
 		lineNum++
-		line := scanner.Text()
-		sp.stats.TotalProcessed++
+		sp.stats.RecordProcessed()
 
 		if sp.config.Normalizer.TrimSpaces && strings.TrimSpace(line) == "" {
 			continue
 		}
 
-		// Create dedup key
-		key, err := sp.config.Normalizer.CreateDedupKey(line)
+		in, ok := sp.config.prepareLine(line)
+		if !ok {
+			sp.stats.RecordFiltered()
+			continue
+		}
+		line = in.text
+
+		// Normalize according to mode, as batch processing does
+		key, normalizedURL, err := sp.config.Normalizer.NormalizeWithKey(line)
 		if err != nil {
 			sp.handleError(lineNum, line, err)
 			continue
 		}
+		sp.config.recordDetails(sp.stats, normalizedURL, line)
 
-		// Get normalized URL
-		normalizedURL, err := sp.config.Normalizer.NormalizeURL(line)
-		if err != nil {
+		// Keys flushed in an earlier window are only counted
+		if sp.config.GlobalDedup && sp.flushedBefore(key) {
+			sp.stats.RecordDuplicate()
 			continue
 		}
 
 		// Add to current window
-		dedup.Add(key, normalizedURL)
+		dedup.AddItem(deduplicator.Item{Key: key, URL: normalizedURL, Status: in.status, Method: in.method, Scheme: sp.config.scheme(line)})
+		sp.trackKey(key)
 
 		// Check if we need to flush due to buffer size
-		if dedup.Count() >= sp.config.MaxBuffer {
+		if sp.config.flushOnSize() && dedup.Count() >= sp.config.MaxBuffer {
 			if err := sp.flush(dedup); err != nil {
 				return err
 			}
 			dedup = deduplicator.New(sp.stats) // Reset window
 		}
-
-		// Check for periodic flush signal (non-blocking)
-		select {
-		case <-flushChan:
-			if dedup.Count() > 0 {
-				if err := sp.flush(dedup); err != nil {
-					return err
-				}
-				dedup = deduplicator.New(sp.stats) // Reset window
-			}
-		default:
-			// Continue processing
-		}
 	}
 
 	// Final flush of remaining entries
-	close(done)
 	if dedup.Count() > 0 {
 		if err := sp.flush(dedup); err != nil {
 			return err
@@ -133,43 +230,140 @@ func (sp *StreamingProcessor) ProcessStreaming(input io.Reader) error {
 	}
 
 	if err := scanner.Err(); err != nil {
-		return fmt.Errorf("error reading input: %w", err)
+		return err
 	}
 
-	return nil
+	sp.stats.Finish()
+	return ctx.Err()
 }
 
-// flush writes current buffer to output
+// flush finalizes the current window and queues it for the writer
 func (sp *StreamingProcessor) flush(dedup *deduplicator.Deduplicator) error {
-	sp.mu.Lock()
-	defer sp.mu.Unlock()
-
 	entries := dedup.GetEntries()
 	if len(entries) == 0 {
 		return nil
 	}
 
-	if sp.config.Output != nil && sp.config.OutputWriter != nil {
-		return sp.config.Output.Format(entries, sp.config.OutputWriter)
+	sp.mu.Lock()
+	sp.stats.Windows++
+	if sp.config.GlobalDedup {
+		sp.window = make(map[uint64]struct{})
 	}
+	sp.mu.Unlock()
 
+	if sp.windows == nil {
+		return nil
+	}
+	if err := sp.writeError(); err != nil {
+		return err
+	}
+	sp.windows <- entries
 	return nil
 }
 
+// startWriter starts the goroutine that writes finalized windows. All
+// output goes through it, so windows are written whole and in flush order.
+func (sp *StreamingProcessor) startWriter() {
+	sp.writeErr = nil
+	if sp.config.Output == nil || sp.config.OutputWriter == nil {
+		sp.windows = nil
+		return
+	}
+
+	sp.windows = make(chan []deduplicator.Entry, writeQueue)
+	sp.writerDone = make(chan struct{})
+	go func() {
+		defer close(sp.writerDone)
+		for entries := range sp.windows {
+			// Keep draining after an error so flush never blocks
+			if sp.writeError() != nil {
+				continue
+			}
+			if err := sp.writeWindow(entries); err != nil {
+				sp.writeMu.Lock()
+				sp.writeErr = err
+				sp.writeMu.Unlock()
+			}
+		}
+	}()
+}
+
+// stopWriter waits for queued windows to be written and returns the first
+// write error
+func (sp *StreamingProcessor) stopWriter() error {
+	if sp.windows == nil {
+		return nil
+	}
+	close(sp.windows)
+	<-sp.writerDone
+	sp.windows = nil
+	return sp.writeError()
+}
+
+// writeWindow formats a window into memory and writes it with a single
+// Write, so it is never interleaved with other writers of OutputWriter
+func (sp *StreamingProcessor) writeWindow(entries []deduplicator.Entry) error {
+	var buf bytes.Buffer
+	if err := sp.config.Output.Format(entries, &buf); err != nil {
+		return err
+	}
+	_, err := sp.config.OutputWriter.Write(buf.Bytes())
+	return err
+}
+
+// writeError returns the first error hit by the writer goroutine
+func (sp *StreamingProcessor) writeError() error {
+	sp.writeMu.Lock()
+	defer sp.writeMu.Unlock()
+	return sp.writeErr
+}
+
+// trackKey records a key in the cross-window unique count
+func (sp *StreamingProcessor) trackKey(key string) {
+	sp.mu.Lock()
+	defer sp.mu.Unlock()
+
+	h := hashKey(key)
+	sp.seen[h] = struct{}{}
+	sp.stats.CumulativeUnique = len(sp.seen)
+	if sp.config.GlobalDedup {
+		sp.window[h] = struct{}{}
+	}
+}
+
+// flushedBefore reports whether a key was output by an earlier window
+func (sp *StreamingProcessor) flushedBefore(key string) bool {
+	sp.mu.Lock()
+	defer sp.mu.Unlock()
+
+	h := hashKey(key)
+	_, seen := sp.seen[h]
+	_, inWindow := sp.window[h]
+	return seen && !inWindow
+}
+
+// hashKey returns a compact 64-bit hash of a dedup key
+func hashKey(key string) uint64 {
+	h := fnv.New64a()
+	h.Write([]byte(key))
+	return h.Sum64()
+}
+
 // handleError handles processing errors in streaming mode
 func (sp *StreamingProcessor) handleError(lineNum int, line string, err error) {
 	if sp.config.Verbose && line != "" {
-		fmt.Fprintf(sp.config.OutputWriter, "Line %d: %v - %s\n", lineNum, err, line)
+		fmt.Fprintf(os.Stderr, "Line %d: %v - %s\n", lineNum, err, line)
 	}
 
 	errMsg := err.Error()
 	if strings.Contains(errMsg, "parse error") {
-		sp.stats.ParseErrors++
+		sp.stats.RecordParseError()
 	} else if strings.Contains(errMsg, "ignored extension") ||
 		strings.Contains(errMsg, "blacklist") ||
 		strings.Contains(errMsg, "whitelist") ||
-		strings.Contains(errMsg, "domain") {
-		sp.stats.Filtered++
+		strings.Contains(errMsg, "domain") ||
+		strings.Contains(errMsg, "path segments") {
+		sp.stats.RecordFiltered()
 	}
 }
 
@@ -3,22 +3,33 @@ package scope
 import (
 	"bufio"
 	"fmt"
+	"net"
+	"net/url"
 	"os"
 	"strings"
+	"sync"
 )
 
+// lookupIP resolves hostnames for CIDR patterns; tests replace it to avoid DNS
+var lookupIP = net.LookupIP
+
 // Checker handles scope verification for URLs
 type Checker struct {
 	includes []pattern // Patterns to include
 	excludes []pattern // Patterns to exclude
+
+	mu       sync.Mutex
+	resolved map[string][]net.IP // Cached lookups for CIDR matching
 }
 
 // pattern represents a scope pattern with wildcard support
 type pattern struct {
 	raw       string // Original pattern
 	parts     []string
-	hasPrefix bool // Starts with *
-	hasSuffix bool // Ends with *
+	hasPrefix bool       // Starts with *
+	hasSuffix bool       // Ends with *
+	network   *net.IPNet // Set for CIDR patterns like 192.168.0.0/16
+	port      string     // Required port, from example.com:8443 ("" = any)
 }
 
 // NewChecker creates a new scope checker
@@ -26,6 +37,7 @@ func NewChecker() *Checker {
 	return &Checker{
 		includes: []pattern{},
 		excludes: []pattern{},
+		resolved: make(map[string][]net.IP),
 	}
 }
 
@@ -75,12 +87,21 @@ func (c *Checker) AddExclude(pattern string) {
 	c.excludes = append(c.excludes, parsePattern(pattern))
 }
 
-// parsePattern parses a pattern with wildcard support
+// parsePattern parses a pattern with wildcard, CIDR and port support
 func parsePattern(raw string) pattern {
 	p := pattern{
 		raw: raw,
 	}
 
+	// A trailing :port restricts the pattern to hosts on that port
+	raw, p.port = splitPatternPort(raw)
+
+	// CIDR ranges match by IP containment instead of by name
+	if _, network, err := net.ParseCIDR(raw); err == nil {
+		p.network = network
+		return p
+	}
+
 	// Check for wildcards
 	p.hasPrefix = strings.HasPrefix(raw, "*")
 	p.hasSuffix = strings.HasSuffix(raw, "*")
@@ -104,16 +125,40 @@ func parsePattern(raw string) pattern {
 	return p
 }
 
-// IsInScope checks if a host is in scope
+// splitPatternPort splits a trailing numeric port off a pattern
+// (example.com:8443, [2001:db8::1]:8443), leaving bare IPv6 addresses and
+// ranges alone
+func splitPatternPort(raw string) (string, string) {
+	if strings.HasPrefix(raw, "[") {
+		end := strings.Index(raw, "]")
+		if end == -1 {
+			return raw, ""
+		}
+		port, _ := strings.CutPrefix(raw[end+1:], ":")
+		return raw[1:end], port
+	}
+
+	if strings.Count(raw, ":") != 1 {
+		return raw, ""
+	}
+	host, port, _ := strings.Cut(raw, ":")
+	if port == "" || strings.Trim(port, "0123456789") != "" {
+		return raw, ""
+	}
+	return host, port
+}
+
+// IsInScope checks if a host is in scope. Patterns with a port only match
+// hosts on that explicit port; patterns without one match any port.
 func (c *Checker) IsInScope(host string) bool {
-	// Normalize host (remove port if present)
-	host = normalizeHost(host)
+	// Normalize host, keeping the port for port-specific patterns
+	host, port := normalizeHost(host)
 
 	// If no includes defined, everything is in scope by default
 	if len(c.includes) == 0 {
 		// But still check excludes
 		for _, excl := range c.excludes {
-			if matchPattern(host, excl) {
+			if c.match(host, port, excl) {
 				return false
 			}
 		}
@@ -123,7 +168,7 @@ func (c *Checker) IsInScope(host string) bool {
 	// Check if matches any include pattern
 	inScope := false
 	for _, incl := range c.includes {
-		if matchPattern(host, incl) {
+		if c.match(host, port, incl) {
 			inScope = true
 			break
 		}
@@ -136,7 +181,7 @@ func (c *Checker) IsInScope(host string) bool {
 
 	// Check if matches any exclude pattern
 	for _, excl := range c.excludes {
-		if matchPattern(host, excl) {
+		if c.match(host, port, excl) {
 			return false
 		}
 	}
@@ -144,25 +189,120 @@ func (c *Checker) IsInScope(host string) bool {
 	return true
 }
 
-// normalizeHost removes port and normalizes the host
-func normalizeHost(host string) string {
-	// Remove port if present
-	if idx := strings.Index(host, ":"); idx != -1 {
-		host = host[:idx]
+// EntryHost returns the host an output entry belongs to for the given
+// normalization mode, or "" when the entry carries no host (params mode,
+// or path mode with the host dropped)
+func EntryHost(entry, mode string) string {
+	switch mode {
+	case "host":
+		return entry
+	case "path":
+		// Path entries are host + path, or just the path with --path-no-host
+		if strings.HasPrefix(entry, "/") {
+			return ""
+		}
+		host, _, _ := strings.Cut(entry, "/")
+		return host
+	default:
+		u, err := url.Parse(entry)
+		if err != nil {
+			return ""
+		}
+		return u.Host
+	}
+}
+
+// schemePorts maps URL schemes to the port implied when none is given
+var schemePorts = map[string]string{
+	"http":  "80",
+	"https": "443",
+}
+
+// EntryHostPort is EntryHost with the port made explicit. URL entries on
+// their scheme's default port have it stripped by normalization, so the
+// port is inferred from the scheme (https 443, http 80) for patterns such
+// as example.com:443 to match. Host and path entries carry no scheme and
+// are returned as EntryHost returns them.
+func EntryHostPort(entry, mode string) string {
+	if mode == "host" || mode == "path" {
+		return EntryHost(entry, mode)
+	}
+
+	u, err := url.Parse(entry)
+	if err != nil {
+		return ""
+	}
+	if u.Host != "" && u.Port() == "" {
+		if port, ok := schemePorts[strings.ToLower(u.Scheme)]; ok {
+			return strings.TrimSuffix(u.Host, ":") + ":" + port
+		}
+	}
+	return u.Host
+}
+
+// normalizeHost splits off the port ("" when absent) and normalizes the host
+func normalizeHost(host string) (string, string) {
+	// Remove port if present, keeping IPv6 literals intact
+	var port string
+	if strings.HasPrefix(host, "[") {
+		if end := strings.Index(host, "]"); end != -1 {
+			port = strings.TrimPrefix(host[end+1:], ":")
+			host = host[1:end]
+		}
+	} else if net.ParseIP(host) == nil {
+		if idx := strings.Index(host, ":"); idx != -1 {
+			host, port = host[:idx], host[idx+1:]
+		}
 	}
 
-	// Convert to lowercase
-	host = strings.ToLower(host)
+	// Convert to lowercase and drop the trailing dot of an FQDN
+	host = strings.TrimSuffix(strings.ToLower(host), ".")
 
 	// Remove www. prefix for comparison
 	if strings.HasPrefix(host, "www.") {
 		host = host[4:]
 	}
 
-	return host
+	return host, port
+}
+
+// match checks if a host on a port matches a pattern, resolving the host
+// for CIDR patterns unless it is already an IP literal
+func (c *Checker) match(host, port string, p pattern) bool {
+	if p.port != "" && p.port != port {
+		return false
+	}
+	if p.network == nil {
+		return matchPattern(host, p)
+	}
+
+	for _, ip := range c.hostIPs(host) {
+		if p.network.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// hostIPs returns the addresses of a host, caching lookups so each host is
+// resolved once; hosts that fail to resolve match no CIDR pattern
+func (c *Checker) hostIPs(host string) []net.IP {
+	if ip := net.ParseIP(host); ip != nil {
+		return []net.IP{ip}
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if ips, ok := c.resolved[host]; ok {
+		return ips
+	}
+	ips, _ := lookupIP(host)
+	c.resolved[host] = ips
+	return ips
 }
 
-// matchPattern checks if a host matches a pattern
+// matchPattern checks if a host matches a hostname pattern
 func matchPattern(host string, p pattern) bool {
 	// Exact match (no wildcards)
 	if !p.hasPrefix && !p.hasSuffix && len(p.parts) == 1 {
@@ -1,6 +1,8 @@
 package scope
 
 import (
+	"fmt"
+	"net"
 	"testing"
 )
 
@@ -49,6 +51,138 @@ func TestScopeChecker_IsInScope(t *testing.T) {
 			host:     "www.example.com",
 			expected: true,
 		},
+		{
+			name:     "trailing FQDN dot",
+			includes: []string{"*.example.com"},
+			host:     "api.example.com.",
+			expected: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			checker := NewChecker()
+			for _, inc := range tt.includes {
+				checker.AddInclude(inc)
+			}
+			for _, exc := range tt.excludes {
+				checker.AddExclude(exc)
+			}
+
+			got := checker.IsInScope(tt.host)
+			if got != tt.expected {
+				t.Errorf("IsInScope(%q) = %v; want %v", tt.host, got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestScopeChecker_CIDR(t *testing.T) {
+	// Resolve hostnames from a fixed table instead of DNS
+	defer func(orig func(string) ([]net.IP, error)) { lookupIP = orig }(lookupIP)
+	lookupIP = func(host string) ([]net.IP, error) {
+		switch host {
+		case "intranet.example.com":
+			return []net.IP{net.ParseIP("192.168.10.5")}, nil
+		case "v6.example.com":
+			return []net.IP{net.ParseIP("2001:db8::10")}, nil
+		}
+		return nil, fmt.Errorf("no such host: %s", host)
+	}
+
+	tests := []struct {
+		name     string
+		includes []string
+		excludes []string
+		host     string
+		expected bool
+	}{
+		{"bare IPv4 in range", []string{"192.168.0.0/16"}, nil, "192.168.1.20", true},
+		{"bare IPv4 with port", []string{"192.168.0.0/16"}, nil, "192.168.1.20:8080", true},
+		{"bare IPv4 out of range", []string{"192.168.0.0/16"}, nil, "10.0.0.1", false},
+		{"IPv6 in range", []string{"2001:db8::/32"}, nil, "2001:db8::1", true},
+		{"bracketed IPv6 with port", []string{"2001:db8::/32"}, nil, "[2001:db8::1]:443", true},
+		{"IPv6 out of range", []string{"2001:db8::/32"}, nil, "2001:db9::1", false},
+		{"resolved hostname", []string{"192.168.0.0/16"}, nil, "intranet.example.com", true},
+		{"resolved IPv6 hostname", []string{"2001:db8::/32"}, nil, "v6.example.com", true},
+		{"unresolvable hostname", []string{"192.168.0.0/16"}, nil, "unknown.example.com", false},
+		{"mixed with hostname pattern", []string{"192.168.0.0/16", "*.example.org"}, nil, "api.example.org", true},
+		{"excluded subrange", []string{"10.0.0.0/8"}, []string{"10.1.0.0/16"}, "10.1.2.3", false},
+		{"CIDR exclude only", nil, []string{"192.168.0.0/16"}, "intranet.example.com", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			checker := NewChecker()
+			for _, inc := range tt.includes {
+				checker.AddInclude(inc)
+			}
+			for _, exc := range tt.excludes {
+				checker.AddExclude(exc)
+			}
+
+			got := checker.IsInScope(tt.host)
+			if got != tt.expected {
+				t.Errorf("IsInScope(%q) = %v; want %v", tt.host, got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestEntryHost(t *testing.T) {
+	tests := []struct {
+		entry    string
+		mode     string
+		expected string
+	}{
+		{"https://api.example.com/users?id=", "url", "api.example.com"},
+		{"api.example.com", "host", "api.example.com"},
+		{"api.example.com:8080", "host", "api.example.com:8080"},
+		{"api.example.com/users/{id}", "path", "api.example.com"},
+		{"api.example.com", "path", "api.example.com"},
+		{"/users/{id}", "path", ""},
+		{"id,page", "params", ""},
+	}
+
+	for _, tt := range tests {
+		got := EntryHost(tt.entry, tt.mode)
+		if got != tt.expected {
+			t.Errorf("EntryHost(%q, %q) = %q; want %q", tt.entry, tt.mode, got, tt.expected)
+		}
+	}
+
+	// Scope filtering works on the extracted host in every mode
+	checker := NewChecker()
+	checker.AddInclude("*.example.com")
+	for _, tt := range tests {
+		if tt.expected == "" {
+			continue
+		}
+		if !checker.IsInScope(EntryHost(tt.entry, tt.mode)) {
+			t.Errorf("entry %q in %s mode should be in scope", tt.entry, tt.mode)
+		}
+	}
+}
+
+func TestScopeChecker_Port(t *testing.T) {
+	tests := []struct {
+		name     string
+		includes []string
+		excludes []string
+		host     string
+		expected bool
+	}{
+		{"port pattern matches its port", []string{"example.com:8080"}, nil, "example.com:8080", true},
+		{"port pattern rejects default port", []string{"example.com:8080"}, nil, "example.com", false},
+		{"port pattern rejects other port", []string{"example.com:8080"}, nil, "example.com:8443", false},
+		{"portless pattern matches any port", []string{"example.com"}, nil, "example.com:8080", true},
+		{"wildcard with port", []string{"*.example.com:8443"}, nil, "api.example.com:8443", true},
+		{"www stripped before port match", []string{"example.com:8080"}, nil, "WWW.example.com:8080", true},
+		{"excluded port only", []string{"example.com"}, []string{"example.com:9000"}, "example.com:9000", false},
+		{"other ports stay in scope", []string{"example.com"}, []string{"example.com:9000"}, "example.com:443", true},
+		{"bracketed IPv6 with port", []string{"[2001:db8::1]:8080"}, nil, "[2001:db8::1]:8080", true},
+		{"bracketed IPv6 other port", []string{"[2001:db8::1]:8080"}, nil, "[2001:db8::1]:80", false},
+		{"CIDR with port", []string{"10.0.0.0/8:8080"}, nil, "10.1.2.3:8080", true},
 	}
 
 	for _, tt := range tests {
@@ -68,3 +202,33 @@ func TestScopeChecker_IsInScope(t *testing.T) {
 		})
 	}
 }
+
+func TestEntryHostPort(t *testing.T) {
+	tests := []struct {
+		name     string
+		pattern  string
+		entry    string
+		mode     string
+		expected bool
+	}{
+		{"https implies 443", "example.com:443", "https://example.com/a", "url", true},
+		{"http implies 80", "example.com:80", "http://example.com/a", "url", true},
+		{"https is not 80", "example.com:80", "https://example.com/a", "url", false},
+		{"explicit port kept", "example.com:8443", "https://example.com:8443/a", "url", true},
+		{"explicit port is not the default", "example.com:443", "https://example.com:8443/a", "url", false},
+		{"IPv6 implies 443", "[2001:db8::1]:443", "https://[2001:db8::1]/a", "url", true},
+		{"portless pattern still matches", "example.com", "https://example.com/a", "url", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			checker := NewChecker()
+			checker.AddInclude(tt.pattern)
+
+			host := EntryHostPort(tt.entry, tt.mode)
+			if got := checker.IsInScope(host); got != tt.expected {
+				t.Errorf("IsInScope(%q) for %q in scope %q = %v; want %v", host, tt.entry, tt.pattern, got, tt.expected)
+			}
+		})
+	}
+}
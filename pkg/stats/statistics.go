@@ -1,9 +1,13 @@
 package stats
 
 import (
+	"encoding/json"
 	"fmt"
 	"io"
 	"sort"
+	"strings"
+	"sync"
+	"text/template"
 	"time"
 )
 
@@ -14,14 +18,35 @@ type Statistics struct {
 	Duplicates     int
 	ParseErrors    int
 	Filtered       int
+	Evicted        int  // Unique entries dropped by a memory cap (results are lossy)
+	HostCapped     int  // URLs dropped because their host reached a per-host cap
+	InputTruncated bool // Input was cut short by a byte limit
 	StartTime      time.Time
 	EndTime        time.Time
 
+	// Streaming statistics (only set in streaming mode)
+	Windows          int // Number of windows flushed
+	CumulativeUnique int // Unique keys across all windows
+
 	// Enhanced statistics
 	TopDomains     map[string]int
 	ParamFrequency map[string]int
 	ExtensionCount map[string]int
 	totalParams    int
+
+	// mu guards updates made while workers are still running. Fields may be
+	// read directly once processing has finished; use Counts before that.
+	mu sync.Mutex
+}
+
+// Counters is a consistent copy of the core counters
+type Counters struct {
+	TotalProcessed int
+	UniqueURLs     int
+	Duplicates     int
+	ParseErrors    int
+	Filtered       int
+	Evicted        int
 }
 
 // NewStatistics creates a new Statistics instance
@@ -57,20 +82,87 @@ func (s *Statistics) AvgQueryParams() float64 {
 
 // RecordDomain records a domain occurrence
 func (s *Statistics) RecordDomain(domain string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
 	s.TopDomains[domain]++
 }
 
 // RecordParam records a parameter occurrence
 func (s *Statistics) RecordParam(param string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
 	s.ParamFrequency[param]++
 	s.totalParams++
 }
 
 // RecordExtension records an extension occurrence
 func (s *Statistics) RecordExtension(ext string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
 	s.ExtensionCount[ext]++
 }
 
+// RecordProcessed counts an input line
+func (s *Statistics) RecordProcessed() {
+	s.add(&s.TotalProcessed, 1)
+}
+
+// RecordUnique counts a newly seen unique entry
+func (s *Statistics) RecordUnique() {
+	s.add(&s.UniqueURLs, 1)
+}
+
+// RecordDuplicate counts an occurrence of an already seen entry
+func (s *Statistics) RecordDuplicate() {
+	s.add(&s.Duplicates, 1)
+}
+
+// RecordParseError counts a line that could not be parsed
+func (s *Statistics) RecordParseError() {
+	s.add(&s.ParseErrors, 1)
+}
+
+// RecordFiltered counts a line dropped by a filter
+func (s *Statistics) RecordFiltered() {
+	s.add(&s.Filtered, 1)
+}
+
+// RecordEvicted counts unique entries dropped by a memory cap. They no
+// longer count as unique, so UniqueURLs reports the entries retained.
+func (s *Statistics) RecordEvicted(n int) {
+	s.mu.Lock()
+	s.Evicted += n
+	s.UniqueURLs -= n
+	s.mu.Unlock()
+}
+
+// RecordHostCapped counts a URL dropped because its host reached a
+// per-host cap
+func (s *Statistics) RecordHostCapped() {
+	s.add(&s.HostCapped, 1)
+}
+
+// add increments one counter under the lock
+func (s *Statistics) add(counter *int, n int) {
+	s.mu.Lock()
+	*counter += n
+	s.mu.Unlock()
+}
+
+// Counts returns the core counters, safe to call while processing runs
+func (s *Statistics) Counts() Counters {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return Counters{
+		TotalProcessed: s.TotalProcessed,
+		UniqueURLs:     s.UniqueURLs,
+		Duplicates:     s.Duplicates,
+		ParseErrors:    s.ParseErrors,
+		Filtered:       s.Filtered,
+		Evicted:        s.Evicted,
+	}
+}
+
 // Print outputs basic statistics to the given writer
 func (s *Statistics) Print(w io.Writer) {
 	fmt.Fprintln(w, "\n=== Statistics ===")
@@ -79,10 +171,63 @@ func (s *Statistics) Print(w io.Writer) {
 	fmt.Fprintf(w, "Duplicates removed:   %d\n", s.Duplicates)
 	fmt.Fprintf(w, "Parse errors:         %d\n", s.ParseErrors)
 	fmt.Fprintf(w, "Filtered out:         %d\n", s.Filtered)
+	if s.Evicted > 0 {
+		fmt.Fprintf(w, "Evicted (lossy):      %d  WARNING: unique cap reached\n", s.Evicted)
+	}
+	if s.HostCapped > 0 {
+		fmt.Fprintf(w, "Capped per host:      %d\n", s.HostCapped)
+	}
+	if s.InputTruncated {
+		fmt.Fprintln(w, "Input truncated:      yes  WARNING: input byte limit reached")
+	}
+	if s.Windows > 0 {
+		fmt.Fprintf(w, "Windows flushed:      %d\n", s.Windows)
+		fmt.Fprintf(w, "Unique (all windows): %d\n", s.CumulativeUnique)
+	}
 	fmt.Fprintf(w, "Processing time:      %v\n", s.ProcessingTime())
 	fmt.Fprintln(w, "==================")
 }
 
+// PrintOneLine outputs a single grep-able summary line to the given writer
+func (s *Statistics) PrintOneLine(w io.Writer) {
+	fmt.Fprintf(w, "processed=%d unique=%d dupes=%d filtered=%d errors=%d time=%dms\n",
+		s.TotalProcessed, s.UniqueURLs, s.Duplicates, s.Filtered, s.ParseErrors,
+		s.ProcessingTime().Milliseconds())
+}
+
+// ParseTemplate parses a custom statistics template. Templates are executed
+// against the Statistics value, so every counter and method is available
+// ({{.UniqueURLs}}, {{.ProcessingTime}}), plus a "top" function returning
+// the N highest entries of a frequency map ({{range top .TopDomains 3}}).
+func ParseTemplate(text string) (*template.Template, error) {
+	return template.New("stats").Funcs(template.FuncMap{
+		"top": func(m map[string]int, n int) []KeyValue {
+			return (&Statistics{}).getTopN(m, n)
+		},
+	}).Parse(text)
+}
+
+// PrintTemplate renders a template from ParseTemplate to the given writer,
+// ending the output with a newline
+func (s *Statistics) PrintTemplate(w io.Writer, tmpl *template.Template) error {
+	var sb strings.Builder
+	if err := tmpl.Execute(&sb, s); err != nil {
+		return err
+	}
+
+	out := sb.String()
+	if !strings.HasSuffix(out, "\n") {
+		out += "\n"
+	}
+	_, err := io.WriteString(w, out)
+	return err
+}
+
+// PrintJSON writes ToJSON as a single JSON object followed by a newline
+func (s *Statistics) PrintJSON(w io.Writer) error {
+	return json.NewEncoder(w).Encode(s.ToJSON())
+}
+
 // PrintDetailed outputs detailed statistics to the given writer
 func (s *Statistics) PrintDetailed(w io.Writer) {
 	s.Print(w)
@@ -118,8 +263,8 @@ func (s *Statistics) PrintDetailed(w io.Writer) {
 
 // KeyValue represents a key-value pair for sorting
 type KeyValue struct {
-	Key   string
-	Value int
+	Key   string `json:"key"`
+	Value int    `json:"value"`
 }
 
 // getTopN returns the top N items from a map by value
@@ -130,7 +275,10 @@ func (s *Statistics) getTopN(m map[string]int, n int) []KeyValue {
 	}
 
 	sort.Slice(pairs, func(i, j int) bool {
-		return pairs[i].Value > pairs[j].Value
+		if pairs[i].Value != pairs[j].Value {
+			return pairs[i].Value > pairs[j].Value
+		}
+		return pairs[i].Key < pairs[j].Key
 	})
 
 	if len(pairs) > n {
@@ -142,7 +290,7 @@ func (s *Statistics) getTopN(m map[string]int, n int) []KeyValue {
 
 // ToJSON returns statistics as a JSON-compatible map
 func (s *Statistics) ToJSON() map[string]interface{} {
-	return map[string]interface{}{
+	result := map[string]interface{}{
 		"total_processed":    s.TotalProcessed,
 		"unique_urls":        s.UniqueURLs,
 		"duplicates":         s.Duplicates,
@@ -154,4 +302,20 @@ func (s *Statistics) ToJSON() map[string]interface{} {
 		"top_parameters":     s.getTopN(s.ParamFrequency, 10),
 		"extensions":         s.getTopN(s.ExtensionCount, 10),
 	}
+
+	if s.Evicted > 0 {
+		result["evicted"] = s.Evicted
+	}
+	if s.HostCapped > 0 {
+		result["host_capped"] = s.HostCapped
+	}
+	if s.InputTruncated {
+		result["input_truncated"] = true
+	}
+	if s.Windows > 0 {
+		result["windows"] = s.Windows
+		result["cumulative_unique"] = s.CumulativeUnique
+	}
+
+	return result
 }
@@ -30,6 +30,18 @@ func (m *MemoryBackend) Add(dedupKey, url string) error {
 	return nil
 }
 
+// AddBatch stores several records in memory
+func (m *MemoryBackend) AddBatch(records []Record) error {
+	for _, r := range records {
+		if _, exists := m.seen[r.Key]; !exists {
+			m.seen[r.Key] = r.URL
+			m.order = append(m.order, r.Key)
+		}
+		m.counts[r.Key] += r.occurrences()
+	}
+	return nil
+}
+
 // GetEntries returns all stored entries in first-seen order
 func (m *MemoryBackend) GetEntries() ([]deduplicator.Entry, error) {
 	entries := make([]deduplicator.Entry, len(m.order))
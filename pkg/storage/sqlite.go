@@ -2,25 +2,131 @@ package storage
 
 import (
 	"database/sql"
+	"errors"
 	"fmt"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
 
-	_ "github.com/mattn/go-sqlite3"
 	"github.com/lcalzada-xor/dupdurl/pkg/deduplicator"
 )
 
+const (
+	// busyTimeoutMs is how long SQLite itself waits on a locked database
+	busyTimeoutMs = 5000
+
+	// maxBusyRetries bounds the retries on SQLITE_BUSY after the timeout
+	maxBusyRetries = 5
+	busyBackoff    = 10 * time.Millisecond
+)
+
+// ErrSQLiteUnavailable is returned when the binary was built without cgo,
+// which the SQLite driver requires
+var ErrSQLiteUnavailable = errors.New("SQLite support is not available in this build (rebuild with CGO_ENABLED=1, or use --storage memory)")
+
+// SQLiteAvailable reports whether this build can open SQLite databases
+func SQLiteAvailable() bool {
+	return sqliteAvailable
+}
+
+var pragmaNameRegex = regexp.MustCompile(`^[a-z_]+$`)
+
+// SQLiteOptions tunes the SQLite performance pragmas
+type SQLiteOptions struct {
+	JournalMode string // DELETE, TRUNCATE, PERSIST, MEMORY, WAL or OFF
+	Synchronous string // OFF, NORMAL, FULL or EXTRA ("" keeps SQLite's default)
+	CacheSize   int    // Pages if positive, KiB if negative (0 keeps SQLite's default)
+}
+
+// DefaultSQLiteOptions returns the safe defaults used by NewSQLiteBackend
+func DefaultSQLiteOptions() SQLiteOptions {
+	return SQLiteOptions{
+		JournalMode: "WAL",
+	}
+}
+
+// Validate checks that the options are valid pragma values
+func (o SQLiteOptions) Validate() error {
+	journalModes := []string{"DELETE", "TRUNCATE", "PERSIST", "MEMORY", "WAL", "OFF"}
+	if o.JournalMode != "" && !containsFold(journalModes, o.JournalMode) {
+		return fmt.Errorf("invalid journal mode: %s (valid: %s)", o.JournalMode, strings.Join(journalModes, ", "))
+	}
+
+	syncModes := []string{"OFF", "NORMAL", "FULL", "EXTRA"}
+	if o.Synchronous != "" && !containsFold(syncModes, o.Synchronous) {
+		return fmt.Errorf("invalid synchronous mode: %s (valid: %s)", o.Synchronous, strings.Join(syncModes, ", "))
+	}
+
+	return nil
+}
+
+// dsn returns the driver connection string for dbPath. busy_timeout,
+// synchronous and cache_size are per-connection pragmas, so they go in the
+// DSN, which the driver applies to every connection the pool opens.
+func (o SQLiteOptions) dsn(dbPath string) string {
+	params := url.Values{}
+	params.Set("_busy_timeout", strconv.Itoa(busyTimeoutMs))
+	if o.JournalMode != "" {
+		params.Set("_journal_mode", strings.ToUpper(o.JournalMode))
+	}
+	if o.Synchronous != "" {
+		params.Set("_synchronous", strings.ToUpper(o.Synchronous))
+	}
+	if o.CacheSize != 0 {
+		params.Set("_cache_size", strconv.Itoa(o.CacheSize))
+	}
+
+	sep := "?"
+	if strings.Contains(dbPath, "?") {
+		sep = "&"
+	}
+	return dbPath + sep + params.Encode()
+}
+
+// containsFold checks if a slice contains a string, ignoring case
+func containsFold(slice []string, item string) bool {
+	for _, s := range slice {
+		if strings.EqualFold(s, item) {
+			return true
+		}
+	}
+	return false
+}
+
 // SQLiteBackend stores URLs in SQLite database for massive datasets
 type SQLiteBackend struct {
-	db *sql.DB
+	db      *sql.DB
+	options SQLiteOptions
 }
 
 // NewSQLiteBackend creates a new SQLite storage backend
 func NewSQLiteBackend(dbPath string) (*SQLiteBackend, error) {
-	db, err := sql.Open("sqlite3", dbPath)
+	return NewSQLiteBackendWithOptions(dbPath, DefaultSQLiteOptions())
+}
+
+// NewSQLiteBackendWithOptions creates a new SQLite storage backend with
+// custom pragma tuning
+func NewSQLiteBackendWithOptions(dbPath string, options SQLiteOptions) (*SQLiteBackend, error) {
+	if !sqliteAvailable {
+		return nil, ErrSQLiteUnavailable
+	}
+	if err := options.Validate(); err != nil {
+		return nil, err
+	}
+
+	db, err := sql.Open("sqlite3", options.dsn(dbPath))
 	if err != nil {
 		return nil, fmt.Errorf("failed to open database: %w", err)
 	}
 
-	backend := &SQLiteBackend{db: db}
+	// Every connection to :memory: opens a separate empty database
+	if dbPath == ":memory:" {
+		db.SetMaxOpenConns(1)
+	}
+
+	backend := &SQLiteBackend{db: db, options: options}
 	if err := backend.initialize(); err != nil {
 		db.Close()
 		return nil, err
@@ -29,10 +135,14 @@ func NewSQLiteBackend(dbPath string) (*SQLiteBackend, error) {
 	return backend, nil
 }
 
-// initialize creates the necessary tables
+// initialize creates the necessary tables. The pragmas, including WAL (the
+// default journal mode) which lets readers proceed while a writer holds the
+// lock, are set through the DSN.
 func (s *SQLiteBackend) initialize() error {
 	schema := `
 	CREATE TABLE IF NOT EXISTS urls (
+		-- id doubles as the insertion sequence: AUTOINCREMENT never reuses
+		-- or lowers it, and the upserts below keep a row's original id
 		id INTEGER PRIMARY KEY AUTOINCREMENT,
 		dedup_key TEXT UNIQUE NOT NULL,
 		url TEXT NOT NULL,
@@ -59,7 +169,10 @@ func (s *SQLiteBackend) Add(dedupKey, url string) error {
 	ON CONFLICT(dedup_key) DO UPDATE SET count = count + 1
 	`
 
-	_, err := s.db.Exec(query, dedupKey, url)
+	err := withBusyRetry(func() error {
+		_, err := s.db.Exec(query, dedupKey, url)
+		return err
+	})
 	if err != nil {
 		return fmt.Errorf("failed to insert URL: %w", err)
 	}
@@ -67,9 +180,69 @@ func (s *SQLiteBackend) Add(dedupKey, url string) error {
 	return nil
 }
 
-// GetEntries retrieves all stored entries ordered by first-seen
+// AddBatch stores or updates several URLs in a single transaction
+func (s *SQLiteBackend) AddBatch(records []Record) error {
+	if len(records) == 0 {
+		return nil
+	}
+
+	err := withBusyRetry(func() error {
+		return s.insertBatch(records)
+	})
+	if err != nil {
+		return fmt.Errorf("failed to insert batch: %w", err)
+	}
+
+	return nil
+}
+
+func (s *SQLiteBackend) insertBatch(records []Record) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+
+	stmt, err := tx.Prepare(`
+	INSERT INTO urls (dedup_key, url, count)
+	VALUES (?, ?, ?)
+	ON CONFLICT(dedup_key) DO UPDATE SET count = count + excluded.count
+	`)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+	defer stmt.Close()
+
+	for _, r := range records {
+		if _, err := stmt.Exec(r.Key, r.URL, r.occurrences()); err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+// withBusyRetry runs fn, retrying with exponential backoff while SQLite
+// reports the database as busy or locked
+func withBusyRetry(fn func() error) error {
+	backoff := busyBackoff
+	var err error
+	for attempt := 0; attempt <= maxBusyRetries; attempt++ {
+		if err = fn(); err == nil || !isBusy(err) {
+			return err
+		}
+		time.Sleep(backoff)
+		backoff *= 2
+	}
+	return err
+}
+
+// GetEntries retrieves all stored entries in first-seen order. Entries are
+// ordered by insertion sequence rather than the first_seen timestamp, which
+// only has one-second resolution.
 func (s *SQLiteBackend) GetEntries() ([]deduplicator.Entry, error) {
-	query := `SELECT url, count FROM urls ORDER BY first_seen`
+	query := `SELECT url, count FROM urls ORDER BY id`
 
 	rows, err := s.db.Query(query)
 	if err != nil {
@@ -103,6 +276,19 @@ func (s *SQLiteBackend) Count() int {
 	return count
 }
 
+// Pragma returns the current value of a SQLite pragma, e.g. "journal_mode"
+func (s *SQLiteBackend) Pragma(name string) (string, error) {
+	if !pragmaNameRegex.MatchString(name) {
+		return "", fmt.Errorf("invalid pragma name: %s", name)
+	}
+
+	var value string
+	if err := s.db.QueryRow("PRAGMA " + name).Scan(&value); err != nil {
+		return "", fmt.Errorf("failed to read pragma %s: %w", name, err)
+	}
+	return value, nil
+}
+
 // Close closes the database connection
 func (s *SQLiteBackend) Close() error {
 	return s.db.Close()
@@ -113,3 +299,24 @@ func (s *SQLiteBackend) Clear() error {
 	_, err := s.db.Exec("DELETE FROM urls")
 	return err
 }
+
+// ExportSQLite writes entries into a fresh SQLite database at path, replacing
+// any URLs already stored there. Each entry's URL doubles as its dedup key.
+func ExportSQLite(entries []deduplicator.Entry, path string, options SQLiteOptions) error {
+	backend, err := NewSQLiteBackendWithOptions(path, options)
+	if err != nil {
+		return err
+	}
+	defer backend.Close()
+
+	if err := backend.Clear(); err != nil {
+		return fmt.Errorf("failed to clear database: %w", err)
+	}
+
+	records := make([]Record, len(entries))
+	for i, entry := range entries {
+		records[i] = Record{Key: entry.URL, URL: entry.URL, Count: entry.Count}
+	}
+
+	return backend.AddBatch(records)
+}
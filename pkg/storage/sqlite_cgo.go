@@ -0,0 +1,20 @@
+//go:build cgo
+
+package storage
+
+import (
+	"errors"
+
+	"github.com/mattn/go-sqlite3"
+)
+
+const sqliteAvailable = true
+
+// isBusy reports whether err is a SQLITE_BUSY or SQLITE_LOCKED error
+func isBusy(err error) bool {
+	var sqliteErr sqlite3.Error
+	if !errors.As(err, &sqliteErr) {
+		return false
+	}
+	return sqliteErr.Code == sqlite3.ErrBusy || sqliteErr.Code == sqlite3.ErrLocked
+}
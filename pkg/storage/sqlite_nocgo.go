@@ -0,0 +1,12 @@
+//go:build !cgo
+
+package storage
+
+// Without cgo the SQLite driver is not registered, so every SQLite
+// constructor fails early with ErrSQLiteUnavailable
+const sqliteAvailable = false
+
+// isBusy never matches, since no SQLite operation can run in this build
+func isBusy(err error) bool {
+	return false
+}
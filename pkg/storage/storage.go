@@ -7,6 +7,9 @@ type Backend interface {
 	// Add stores a URL with its dedup key
 	Add(dedupKey, url string) error
 
+	// AddBatch stores several records at once
+	AddBatch(records []Record) error
+
 	// GetEntries retrieves all stored entries
 	GetEntries() ([]deduplicator.Entry, error)
 
@@ -16,3 +19,18 @@ type Backend interface {
 	// Close closes the backend and releases resources
 	Close() error
 }
+
+// Record is a dedup key/URL pair for batched writes
+type Record struct {
+	Key   string
+	URL   string
+	Count int // Occurrences to add (0 counts as 1)
+}
+
+// occurrences returns how many times the record should be counted
+func (r Record) occurrences() int {
+	if r.Count < 1 {
+		return 1
+	}
+	return r.Count
+}
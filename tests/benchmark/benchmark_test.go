@@ -44,6 +44,29 @@ func BenchmarkNormalizeURL(b *testing.B) {
 	}
 }
 
+// BenchmarkNormalizeURLSimple compares a query-less URL on the string fast
+// path with the same URL forced through url.Parse by a no-op depth limit
+func BenchmarkNormalizeURLSimple(b *testing.B) {
+	url := "https://www.example.com/api/users/profile#section"
+
+	b.Run("fast", func(b *testing.B) {
+		config := normalizer.NewConfig()
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			config.NormalizeURL(url)
+		}
+	})
+
+	b.Run("parsed", func(b *testing.B) {
+		config := normalizer.NewConfig()
+		config.MaxPathSegments = 1 << 20
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			config.NormalizeURL(url)
+		}
+	})
+}
+
 func BenchmarkProcessSequential(b *testing.B) {
 	// Generate test data
 	var input strings.Builder
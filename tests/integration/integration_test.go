@@ -2,13 +2,31 @@ package integration
 
 import (
 	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"runtime"
+	"sort"
 	"strings"
+	"sync"
 	"testing"
+	"testing/quick"
+	"time"
 
 	"github.com/lcalzada-xor/dupdurl/pkg/deduplicator"
+	"github.com/lcalzada-xor/dupdurl/pkg/diff"
 	"github.com/lcalzada-xor/dupdurl/pkg/normalizer"
 	"github.com/lcalzada-xor/dupdurl/pkg/output"
 	"github.com/lcalzada-xor/dupdurl/pkg/processor"
+	"github.com/lcalzada-xor/dupdurl/pkg/storage"
 )
 
 func TestEndToEndBasic(t *testing.T) {
@@ -155,6 +173,14 @@ func TestOutputFormatters(t *testing.T) {
 			format: "csv",
 			want:   []string{"url,count", "example.com/page1,2", "example.com/page2,1"},
 		},
+		{
+			name:   "ndjson format",
+			format: "ndjson",
+			want: []string{
+				`{"url":"https://example.com/page1","count":2}` + "\n",
+				`{"url":"https://example.com/page2","count":1}` + "\n",
+			},
+		},
 	}
 
 	for _, tt := range tests {
@@ -271,3 +297,1600 @@ https://allowed.com/page1
 		t.Error("Expected allowed.com in results")
 	}
 }
+
+func TestEndToEndMaxPathSegments(t *testing.T) {
+	input := `https://example.com/a/b/c/d/e/f/g/h/i/j
+https://example.com/a/b/c
+https://example.com/a/b/c/d/e
+`
+
+	config := processor.NewConfig()
+	config.Normalizer = normalizer.NewConfig()
+	config.Normalizer.MaxPathSegments = 5
+	config.Workers = 1
+
+	proc := processor.New(config)
+	entries, err := proc.Process(strings.NewReader(input))
+
+	if err != nil {
+		t.Fatalf("Process() error = %v", err)
+	}
+
+	// The 10-segment URL is dropped, shallower ones are kept
+	if len(entries) != 2 {
+		t.Errorf("Expected 2 unique URLs after depth filtering, got %d", len(entries))
+	}
+	for _, entry := range entries {
+		if strings.Contains(entry.URL, "/j") {
+			t.Errorf("Deep URL found in results: %s", entry.URL)
+		}
+	}
+
+	if stats := proc.GetStatistics(); stats.Filtered != 1 {
+		t.Errorf("Filtered = %d; want 1", stats.Filtered)
+	}
+}
+
+func TestEndToEndMinPathSegments(t *testing.T) {
+	input := `https://example.com/
+https://example.com/about
+https://example.com/api/users
+https://example.com/api/users/profile
+`
+
+	config := processor.NewConfig()
+	config.Normalizer = normalizer.NewConfig()
+	config.Normalizer.MinPathSegments = 2
+	config.Workers = 1
+
+	proc := processor.New(config)
+	entries, err := proc.Process(strings.NewReader(input))
+
+	if err != nil {
+		t.Fatalf("Process() error = %v", err)
+	}
+
+	// Root and single-segment URLs are dropped
+	if len(entries) != 2 {
+		t.Errorf("Expected 2 unique URLs after depth filtering, got %d", len(entries))
+	}
+	for _, entry := range entries {
+		if !strings.Contains(entry.URL, "/api/users") {
+			t.Errorf("Shallow URL found in results: %s", entry.URL)
+		}
+	}
+
+	if stats := proc.GetStatistics(); stats.Filtered != 2 {
+		t.Errorf("Filtered = %d; want 2", stats.Filtered)
+	}
+}
+
+func TestEndToEndCanonicalOutput(t *testing.T) {
+	input := `https://www.example.com/es/about
+https://example.com/en/about
+https://example.com/about
+`
+
+	config := processor.NewConfig()
+	config.Normalizer = normalizer.NewConfig()
+	config.Normalizer.CanonicalOutput = true
+	config.Workers = 1
+
+	proc := processor.New(config)
+	entries, err := proc.Process(strings.NewReader(input))
+
+	if err != nil {
+		t.Fatalf("Process() error = %v", err)
+	}
+
+	if len(entries) != 1 {
+		t.Fatalf("Expected 1 unique URL, got %d", len(entries))
+	}
+
+	// The emitted URL is the canonical base, not the first raw variant
+	if entries[0].URL != "https://example.com/about" {
+		t.Errorf("URL = %q; want https://example.com/about", entries[0].URL)
+	}
+	if entries[0].Count != 3 {
+		t.Errorf("Count = %d; want 3", entries[0].Count)
+	}
+
+	// Without the flag the first-seen variant is kept
+	config.Normalizer.CanonicalOutput = false
+	proc = processor.New(config)
+	entries, _ = proc.Process(strings.NewReader(input))
+	if len(entries) != 1 || entries[0].URL != "https://example.com/es/about" {
+		t.Errorf("default output = %v; want first-seen variant", entries)
+	}
+}
+
+func TestEndToEndPathNoHostSafeguard(t *testing.T) {
+	input := `https://shop.example.com/api/cart
+https://m.shop.example.com/api/cart
+https://a.com/robots.txt
+https://b.org/robots.txt
+https://c.net/robots.txt
+https://d.io/robots.txt
+https://a.com/robots.txt
+`
+
+	config := processor.NewConfig()
+	config.Normalizer = normalizer.NewConfig()
+	config.Normalizer.Mode = "path"
+	config.Normalizer.PathNoHost = true
+	config.MaxHostsPerPath = 2
+	config.Workers = 1
+
+	proc := processor.New(config)
+	entries, err := proc.Process(strings.NewReader(input))
+
+	if err != nil {
+		t.Fatalf("Process() error = %v", err)
+	}
+
+	// /api/cart spans 2 hosts and collapses; /robots.txt spans 4 unrelated
+	// hosts and is kept per host
+	want := []deduplicator.Entry{
+		{URL: "/api/cart", Count: 2},
+		{URL: "a.com/robots.txt", Count: 2},
+		{URL: "b.org/robots.txt", Count: 1},
+		{URL: "c.net/robots.txt", Count: 1},
+		{URL: "d.io/robots.txt", Count: 1},
+	}
+	if len(entries) != len(want) {
+		t.Fatalf("Expected %d entries, got %d: %v", len(want), len(entries), entries)
+	}
+	for i := range want {
+		if entries[i] != want[i] {
+			t.Errorf("entry[%d] = %+v; want %+v", i, entries[i], want[i])
+		}
+	}
+
+	// Without the safeguard every shared path collapses
+	config.MaxHostsPerPath = 0
+	proc = processor.New(config)
+	entries, _ = proc.Process(strings.NewReader(input))
+	if len(entries) != 2 {
+		t.Errorf("Expected 2 collapsed paths without safeguard, got %d", len(entries))
+	}
+}
+
+func TestEndToEndKeepQueryOrder(t *testing.T) {
+	input := `https://example.com/search?b=2&a=1
+https://example.com/search?a=1&b=2
+`
+
+	config := processor.NewConfig()
+	config.Normalizer = normalizer.NewConfig()
+	config.Normalizer.KeepQueryOrder = true
+	config.Workers = 1
+
+	proc := processor.New(config)
+	entries, err := proc.Process(strings.NewReader(input))
+
+	if err != nil {
+		t.Fatalf("Process() error = %v", err)
+	}
+
+	// Both orders collapse, output keeps the first source order
+	if len(entries) != 1 {
+		t.Fatalf("Expected 1 unique URL, got %d", len(entries))
+	}
+	if entries[0].URL != "https://example.com/search?b=2&a=1" {
+		t.Errorf("URL = %q; want source param order preserved", entries[0].URL)
+	}
+}
+
+func TestEndToEndExportSQLite(t *testing.T) {
+	input := `https://example.com/page1?a=1
+https://example.com/page1?a=2
+https://example.com/page2
+`
+
+	config := processor.NewConfig()
+	config.Normalizer = normalizer.NewConfig()
+	config.Workers = 1
+
+	proc := processor.New(config)
+	entries, err := proc.Process(strings.NewReader(input))
+	if err != nil {
+		t.Fatalf("Process() error = %v", err)
+	}
+
+	path := filepath.Join(t.TempDir(), "export.db")
+	if err := storage.ExportSQLite(entries, path, storage.DefaultSQLiteOptions()); err != nil {
+		t.Fatalf("ExportSQLite() error = %v", err)
+	}
+
+	backend, err := storage.NewSQLiteBackend(path)
+	if err != nil {
+		t.Fatalf("NewSQLiteBackend() error = %v", err)
+	}
+	defer backend.Close()
+
+	stored, err := backend.GetEntries()
+	if err != nil {
+		t.Fatalf("GetEntries() error = %v", err)
+	}
+
+	if len(stored) != len(entries) {
+		t.Fatalf("stored %d entries; want %d", len(stored), len(entries))
+	}
+	for i := range entries {
+		if stored[i] != entries[i] {
+			t.Errorf("stored[%d] = %+v; want %+v", i, stored[i], entries[i])
+		}
+	}
+}
+
+func TestEndToEndSeedStorageFromBaseline(t *testing.T) {
+	dir := t.TempDir()
+	baselinePath := filepath.Join(dir, "baseline.json")
+	baseline := []deduplicator.Entry{
+		{URL: "https://example.com/known?id=1", Count: 3},
+		{URL: "https://example.com/old", Count: 1},
+	}
+	if err := diff.SaveBaseline(baseline, baselinePath); err != nil {
+		t.Fatalf("SaveBaseline() error = %v", err)
+	}
+
+	backend, err := storage.NewSQLiteBackend(filepath.Join(dir, "urls.db"))
+	if err != nil {
+		t.Fatalf("NewSQLiteBackend() error = %v", err)
+	}
+	defer backend.Close()
+
+	config := processor.NewConfig()
+	config.Normalizer = normalizer.NewConfig()
+	config.Workers = 1
+	config.Storage = backend
+
+	proc := processor.New(config)
+
+	seed, err := diff.ReadBaseline(baselinePath)
+	if err != nil {
+		t.Fatalf("ReadBaseline() error = %v", err)
+	}
+	if err := proc.Seed(seed); err != nil {
+		t.Fatalf("Seed() error = %v", err)
+	}
+
+	input := `https://example.com/known?id=2
+https://example.com/new
+`
+	entries, err := proc.Process(strings.NewReader(input))
+	if err != nil {
+		t.Fatalf("Process() error = %v", err)
+	}
+
+	counts := make(map[string]int)
+	for _, entry := range entries {
+		counts[entry.URL] = entry.Count
+	}
+
+	// Seeded URLs are present and dedupe against new input
+	want := map[string]int{
+		"https://example.com/known?id=1": 4,
+		"https://example.com/old":        1,
+		"https://example.com/new":        1,
+	}
+	if len(counts) != len(want) {
+		t.Errorf("entries = %v; want %v", counts, want)
+	}
+	for url, count := range want {
+		if counts[url] != count {
+			t.Errorf("%s count = %d; want %d", url, counts[url], count)
+		}
+	}
+
+	// Statistics only cover the new input
+	stats := proc.GetStatistics()
+	if stats.UniqueURLs != 1 || stats.Duplicates != 1 {
+		t.Errorf("UniqueURLs = %d, Duplicates = %d; want 1, 1", stats.UniqueURLs, stats.Duplicates)
+	}
+}
+
+func TestEndToEndSeedStorageFromPathBaseline(t *testing.T) {
+	// A path mode baseline holds keys, not URLs
+	dir := t.TempDir()
+	backend, err := storage.NewSQLiteBackend(filepath.Join(dir, "urls.db"))
+	if err != nil {
+		t.Fatalf("NewSQLiteBackend() error = %v", err)
+	}
+	defer backend.Close()
+
+	config := processor.NewConfig()
+	config.Normalizer = normalizer.NewConfig()
+	config.Normalizer.Mode = "path"
+	config.Workers = 1
+	config.Storage = backend
+
+	proc := processor.New(config)
+	seed := []deduplicator.Entry{
+		{URL: "a.com/x", Count: 2},
+		{URL: "a.com/y", Count: 1},
+	}
+	if err := proc.Seed(seed); err != nil {
+		t.Fatalf("Seed() error = %v", err)
+	}
+
+	entries, err := proc.Process(strings.NewReader("https://a.com/x\nhttps://a.com/z\n"))
+	if err != nil {
+		t.Fatalf("Process() error = %v", err)
+	}
+
+	counts := make(map[string]int)
+	for _, entry := range entries {
+		counts[entry.URL] = entry.Count
+	}
+	want := map[string]int{"a.com/x": 3, "a.com/y": 1, "a.com/z": 1}
+	if len(counts) != len(want) {
+		t.Errorf("entries = %v; want %v", counts, want)
+	}
+	for key, count := range want {
+		if counts[key] != count {
+			t.Errorf("%s count = %d; want %d", key, counts[key], count)
+		}
+	}
+}
+
+func TestEndToEndCustomKeyRegex(t *testing.T) {
+	input := `https://example.com/api/v1/users
+https://example.com/api/v2/users
+https://example.com/api/v3/users
+https://example.com/api/v1/orders
+`
+
+	config := processor.NewConfig()
+	config.Normalizer = normalizer.NewConfig()
+	config.Normalizer.FuzzyMode = true
+	config.Workers = 1
+
+	// Built-in fuzzy matching keeps API versions apart
+	proc := processor.New(config)
+	entries, err := proc.Process(strings.NewReader(input))
+	if err != nil {
+		t.Fatalf("Process() error = %v", err)
+	}
+	if len(entries) != 4 {
+		t.Fatalf("Expected 4 unique URLs with built-ins, got %d", len(entries))
+	}
+
+	// A custom key groups every version of an endpoint
+	config.Normalizer.KeyRegex = regexp.MustCompile(`/v\d+/`)
+	config.Normalizer.KeyTemplate = "/v{n}/"
+
+	proc = processor.New(config)
+	entries, err = proc.Process(strings.NewReader(input))
+	if err != nil {
+		t.Fatalf("Process() error = %v", err)
+	}
+	if len(entries) != 2 {
+		t.Fatalf("Expected 2 unique URLs with custom key, got %d", len(entries))
+	}
+	if entries[0].URL != "https://example.com/api/v1/users" || entries[0].Count != 3 {
+		t.Errorf("entries[0] = %+v; want first users URL with count 3", entries[0])
+	}
+}
+
+func TestEndToEndFingerprintIgnoresInputOrder(t *testing.T) {
+	config := processor.NewConfig()
+	config.Normalizer = normalizer.NewConfig()
+	config.Normalizer.KeyRegex = regexp.MustCompile(`\?.*`)
+	config.Workers = 1
+
+	fingerprint := func(input string) string {
+		proc := processor.New(config)
+		entries, err := proc.Process(strings.NewReader(input))
+		if err != nil {
+			t.Fatalf("Process() error = %v", err)
+		}
+		return proc.FingerprintKeys(entries)
+	}
+
+	// The printed URL depends on the order, the key set does not
+	a := fingerprint("https://a.com/x?id=1\nhttps://a.com/x?id=2\n")
+	b := fingerprint("https://a.com/x?id=2\nhttps://a.com/x?id=1\n")
+	if a != b {
+		t.Errorf("fingerprints differ by input order: %s vs %s", a, b)
+	}
+}
+
+func TestStreamingCumulativeUnique(t *testing.T) {
+	// With a buffer of 2, page1 reappears in later windows
+	input := `https://example.com/page1
+https://example.com/page2
+https://example.com/page1
+https://example.com/page3
+https://example.com/page2
+https://example.com/page4
+`
+
+	config := processor.NewStreamingConfig()
+	config.Normalizer = normalizer.NewConfig()
+	config.MaxBuffer = 2
+
+	proc := processor.NewStreaming(config)
+	if err := proc.ProcessStreaming(strings.NewReader(input)); err != nil {
+		t.Fatalf("ProcessStreaming() error = %v", err)
+	}
+
+	stats := proc.GetStatistics()
+	if stats.Windows < 2 {
+		t.Fatalf("Windows = %d; want at least 2 flushes", stats.Windows)
+	}
+	if stats.CumulativeUnique != 4 {
+		t.Errorf("CumulativeUnique = %d; want 4", stats.CumulativeUnique)
+	}
+	if stats.UniqueURLs <= stats.CumulativeUnique {
+		t.Errorf("UniqueURLs = %d; expected per-window uniques to exceed cumulative %d", stats.UniqueURLs, stats.CumulativeUnique)
+	}
+}
+
+func TestStreamingMatchesBatchModes(t *testing.T) {
+	input := "https://a.com/x?q=1\nhttps://a.com/x?q=2\nhttps://b.com/y\n"
+
+	for _, mode := range []string{"presence", "path", "host"} {
+		norm := normalizer.NewConfig()
+		norm.Mode = mode
+
+		batchConfig := processor.NewConfig()
+		batchConfig.Normalizer = norm
+		batchConfig.Workers = 1
+		entries, err := processor.New(batchConfig).Process(strings.NewReader(input))
+		if err != nil {
+			t.Fatalf("%s: Process() error = %v", mode, err)
+		}
+		var want bytes.Buffer
+		(&output.TextFormatter{}).Format(entries, &want)
+
+		var got bytes.Buffer
+		streamConfig := processor.NewStreamingConfig()
+		streamConfig.Normalizer = norm
+		streamConfig.Output = &output.TextFormatter{}
+		streamConfig.OutputWriter = &got
+		if err := processor.NewStreaming(streamConfig).ProcessStreaming(strings.NewReader(input)); err != nil {
+			t.Fatalf("%s: ProcessStreaming() error = %v", mode, err)
+		}
+
+		if got.String() != want.String() {
+			t.Errorf("--mode %s --stream output = %q; want the batch output %q", mode, got.String(), want.String())
+		}
+	}
+}
+
+func TestStreamingVerboseErrorsStayOffOutput(t *testing.T) {
+	input := "https://example.com/a\nhttp://[::1\nhttps://example.com/b\n"
+
+	var got bytes.Buffer
+	config := processor.NewStreamingConfig()
+	config.Normalizer = normalizer.NewConfig()
+	config.Output = &output.TextFormatter{}
+	config.OutputWriter = &got
+	config.Verbose = true
+	proc := processor.NewStreaming(config)
+	if err := proc.ProcessStreaming(strings.NewReader(input)); err != nil {
+		t.Fatalf("ProcessStreaming() error = %v", err)
+	}
+
+	want := "https://example.com/a\nhttps://example.com/b\n"
+	if got.String() != want {
+		t.Errorf("--stream -v output = %q; want only the URLs %q", got.String(), want)
+	}
+	if proc.GetStatistics().ParseErrors != 1 {
+		t.Errorf("ParseErrors = %d; want 1", proc.GetStatistics().ParseErrors)
+	}
+}
+
+func TestStreamingGlobalDedup(t *testing.T) {
+	// With a buffer of 2, page1 and page2 reappear in later windows
+	input := `https://example.com/page1
+https://example.com/page2
+https://example.com/page1
+https://example.com/page3
+https://example.com/page2
+https://example.com/page4
+`
+
+	var buf bytes.Buffer
+	config := processor.NewStreamingConfig()
+	config.Normalizer = normalizer.NewConfig()
+	config.MaxBuffer = 2
+	config.FlushMode = processor.FlushSize
+	config.GlobalDedup = true
+	config.Output = &output.TextFormatter{}
+	config.OutputWriter = &buf
+
+	proc := processor.NewStreaming(config)
+	if err := proc.ProcessStreaming(strings.NewReader(input)); err != nil {
+		t.Fatalf("ProcessStreaming() error = %v", err)
+	}
+
+	want := "https://example.com/page1\nhttps://example.com/page2\nhttps://example.com/page3\nhttps://example.com/page4\n"
+	if buf.String() != want {
+		t.Errorf("output = %q; want each URL once: %q", buf.String(), want)
+	}
+
+	stats := proc.GetStatistics()
+	if stats.UniqueURLs != 4 || stats.Duplicates != 2 {
+		t.Errorf("UniqueURLs = %d, Duplicates = %d; want 4 and 2", stats.UniqueURLs, stats.Duplicates)
+	}
+}
+
+// chunkWriter records every Write call as one chunk
+type chunkWriter struct {
+	mu     sync.Mutex
+	chunks [][]byte
+}
+
+func (w *chunkWriter) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.chunks = append(w.chunks, append([]byte(nil), p...))
+	return len(p), nil
+}
+
+func TestStreamingWindowsNotInterleaved(t *testing.T) {
+	var w chunkWriter
+	var wg sync.WaitGroup
+
+	// Several processors flush small windows to one shared writer at once
+	for p := 0; p < 4; p++ {
+		var input strings.Builder
+		for i := 0; i < 500; i++ {
+			fmt.Fprintf(&input, "https://example.com/p%d/page%d\n", p, i)
+		}
+
+		config := processor.NewStreamingConfig()
+		config.Normalizer = normalizer.NewConfig()
+		config.MaxBuffer = 7
+		config.FlushMode = processor.FlushSize
+		config.Output = &output.TextFormatter{}
+		config.OutputWriter = &w
+
+		wg.Add(1)
+		go func(proc *processor.StreamingProcessor, input string) {
+			defer wg.Done()
+			if err := proc.ProcessStreaming(strings.NewReader(input)); err != nil {
+				t.Errorf("ProcessStreaming() error = %v", err)
+			}
+		}(processor.NewStreaming(config), input.String())
+	}
+	wg.Wait()
+
+	// Every write is one complete window from a single processor, and each
+	// processor's windows arrive in order
+	next := make(map[int]int)
+	total := 0
+	for _, chunk := range w.chunks {
+		lines := strings.Split(strings.TrimSuffix(string(chunk), "\n"), "\n")
+		owner := -1
+		for _, line := range lines {
+			var p, i int
+			if _, err := fmt.Sscanf(line, "https://example.com/p%d/page%d", &p, &i); err != nil {
+				t.Fatalf("unexpected line %q in chunk %q", line, chunk)
+			}
+			if owner == -1 {
+				owner = p
+			} else if p != owner {
+				t.Fatalf("chunk mixes processors %d and %d: %q", owner, p, chunk)
+			}
+			if i != next[p] {
+				t.Errorf("processor %d: got page%d, want page%d", p, i, next[p])
+			}
+			next[p] = i + 1
+			total++
+		}
+		// 500 URLs in windows of 7 leave a final window of 3
+		if len(lines) != 7 && !(len(lines) == 3 && next[owner] == 500) {
+			t.Errorf("chunk has %d lines; want a whole window: %q", len(lines), chunk)
+		}
+	}
+	if total != 2000 {
+		t.Errorf("wrote %d entries; want 2000", total)
+	}
+}
+
+func TestStreamingFlushMode(t *testing.T) {
+	var input strings.Builder
+	for i := 0; i < 10; i++ {
+		fmt.Fprintf(&input, "https://example.com/page%d\n", i)
+	}
+
+	tests := []struct {
+		mode    string
+		windows int
+	}{
+		{processor.FlushBoth, 5},
+		{processor.FlushSize, 5},
+		{processor.FlushTime, 1}, // no size-triggered flush; only the final one
+	}
+
+	for _, tt := range tests {
+		config := processor.NewStreamingConfig()
+		config.Normalizer = normalizer.NewConfig()
+		config.MaxBuffer = 2
+		config.FlushInterval = time.Hour
+		config.FlushMode = tt.mode
+
+		var buf bytes.Buffer
+		config.Output = &output.TextFormatter{}
+		config.OutputWriter = &buf
+
+		proc := processor.NewStreaming(config)
+		if err := proc.ProcessStreaming(strings.NewReader(input.String())); err != nil {
+			t.Fatalf("%s: ProcessStreaming() error = %v", tt.mode, err)
+		}
+
+		if got := proc.GetStatistics().Windows; got != tt.windows {
+			t.Errorf("%s: Windows = %d; want %d", tt.mode, got, tt.windows)
+		}
+		if lines := strings.Count(buf.String(), "\n"); lines != 10 {
+			t.Errorf("%s: output has %d lines; want 10", tt.mode, lines)
+		}
+	}
+}
+
+// cancelReader serves its first chunk whole, then cancels and blocks until
+// released, as if Ctrl-C arrived while an idle pipe waits for more input
+type cancelReader struct {
+	chunks  []string
+	cancel  context.CancelFunc
+	release chan struct{}
+	reads   int
+}
+
+func (r *cancelReader) Read(p []byte) (int, error) {
+	if r.reads >= len(r.chunks) {
+		return 0, io.EOF
+	}
+	if r.reads == 1 {
+		r.cancel()
+		<-r.release
+	}
+	n := copy(p, r.chunks[r.reads])
+	r.reads++
+	return n, nil
+}
+
+// failingWriter rejects every write
+type failingWriter struct{}
+
+func (failingWriter) Write(p []byte) (int, error) {
+	return 0, errors.New("disk full")
+}
+
+func TestStreamingWriteErrorStopsGoroutines(t *testing.T) {
+	var input strings.Builder
+	for i := 0; i < 1000; i++ {
+		fmt.Fprintf(&input, "https://example.com/page%d\n", i)
+	}
+
+	before := runtime.NumGoroutine()
+
+	config := processor.NewStreamingConfig()
+	config.Normalizer = normalizer.NewConfig()
+	config.MaxBuffer = 1
+	config.FlushInterval = time.Millisecond
+	config.Output = &output.TextFormatter{}
+	config.OutputWriter = failingWriter{}
+
+	err := processor.NewStreaming(config).ProcessStreaming(strings.NewReader(input.String()))
+	if err == nil {
+		t.Fatal("ProcessStreaming() error = nil; want the write error")
+	}
+
+	// The reader and ticker goroutines exit after an early return
+	deadline := time.Now().Add(time.Second)
+	for runtime.NumGoroutine() > before && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if after := runtime.NumGoroutine(); after > before {
+		t.Errorf("goroutines = %d after the error; want at most %d", after, before)
+	}
+}
+
+// failingBackend rejects every write
+type failingBackend struct{}
+
+func (failingBackend) Add(dedupKey, url string) error            { return errors.New("disk full") }
+func (failingBackend) AddBatch(records []storage.Record) error   { return errors.New("disk full") }
+func (failingBackend) GetEntries() ([]deduplicator.Entry, error) { return nil, nil }
+func (failingBackend) Count() int                                { return 0 }
+func (failingBackend) Close() error                              { return nil }
+
+func TestStorageErrorStopsReader(t *testing.T) {
+	var input strings.Builder
+	for i := 0; i < 1000; i++ {
+		fmt.Fprintf(&input, "https://example.com/page%d\n", i)
+	}
+
+	before := runtime.NumGoroutine()
+
+	config := processor.NewConfig()
+	config.Normalizer = normalizer.NewConfig()
+	config.Workers = 1
+	config.Storage = failingBackend{}
+
+	if _, err := processor.New(config).Process(strings.NewReader(input.String())); err == nil {
+		t.Fatal("Process() error = nil; want the storage error")
+	}
+
+	deadline := time.Now().Add(time.Second)
+	for runtime.NumGoroutine() > before && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if after := runtime.NumGoroutine(); after > before {
+		t.Errorf("goroutines = %d after the error; want at most %d", after, before)
+	}
+}
+
+func TestStreamingContextCancel(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	input := &cancelReader{
+		chunks: []string{
+			"https://example.com/page1\nhttps://example.com/page2\n",
+			"https://example.com/page3\nhttps://example.com/page4\n",
+		},
+		cancel:  cancel,
+		release: make(chan struct{}),
+	}
+	defer close(input.release)
+
+	var buf bytes.Buffer
+	config := processor.NewStreamingConfig()
+	config.Normalizer = normalizer.NewConfig()
+	config.FlushInterval = time.Hour
+	config.Output = &output.TextFormatter{}
+	config.OutputWriter = &buf
+
+	proc := processor.NewStreaming(config)
+	err := proc.ProcessStreamingContext(ctx, input)
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("ProcessStreamingContext() error = %v; want context.Canceled", err)
+	}
+
+	// The buffered window is flushed; lines after the cancellation are not read
+	want := "https://example.com/page1\nhttps://example.com/page2\n"
+	if buf.String() != want {
+		t.Errorf("output = %q; want the partial window %q", buf.String(), want)
+	}
+	if got := proc.GetStatistics().TotalProcessed; got != 2 {
+		t.Errorf("TotalProcessed = %d; want 2", got)
+	}
+
+	// Batch processing returns what it deduplicated before the cancellation
+	for _, workers := range []int{1, 4} {
+		ctx, cancel := context.WithCancel(context.Background())
+		input := &cancelReader{chunks: input.chunks, cancel: cancel, release: make(chan struct{})}
+		defer close(input.release)
+
+		config := processor.NewConfig()
+		config.Normalizer = normalizer.NewConfig()
+		config.Workers = workers
+		entries, err := processor.New(config).ProcessContext(ctx, input)
+		cancel()
+		if !errors.Is(err, context.Canceled) {
+			t.Fatalf("workers=%d: ProcessContext() error = %v; want context.Canceled", workers, err)
+		}
+		if len(entries) != 2 {
+			t.Errorf("workers=%d: entries = %v; want the 2 URLs read before cancelling", workers, entries)
+		}
+	}
+}
+
+func TestEndToEndGroupByTemplate(t *testing.T) {
+	input := `https://example.com/users/1
+https://example.com/users/2
+https://example.com/users/2
+https://example.com/about
+`
+
+	config := processor.NewConfig()
+	config.Normalizer = normalizer.NewConfig()
+	config.Normalizer.Mode = "path"
+	config.Normalizer.FuzzyMode = true
+	config.Workers = 1
+	config.MaxExamples = 5
+
+	proc := processor.New(config)
+	entries, err := proc.Process(strings.NewReader(input))
+	if err != nil {
+		t.Fatalf("Process() error = %v", err)
+	}
+
+	var buf bytes.Buffer
+	formatter := &output.TemplateFormatter{Examples: proc.Examples()}
+	if err := formatter.Format(entries, &buf); err != nil {
+		t.Fatalf("Format() error = %v", err)
+	}
+
+	var groups []output.TemplateGroup
+	if err := json.Unmarshal(buf.Bytes(), &groups); err != nil {
+		t.Fatalf("output is not valid JSON: %v\n%s", err, buf.String())
+	}
+
+	if len(groups) != 2 {
+		t.Fatalf("Expected 2 template groups, got %d", len(groups))
+	}
+
+	users := groups[0]
+	if users.Template != "example.com/users/{id}" {
+		t.Errorf("Template = %q; want example.com/users/{id}", users.Template)
+	}
+	if users.Count != 3 {
+		t.Errorf("Count = %d; want 3", users.Count)
+	}
+	want := []string{"example.com/users/1", "example.com/users/2"}
+	if strings.Join(users.Examples, ",") != strings.Join(want, ",") {
+		t.Errorf("Examples = %v; want %v", users.Examples, want)
+	}
+}
+
+func TestEndToEndTemplateFormatURLMode(t *testing.T) {
+	input := "https://a.com/u/1\nhttps://a.com/u/2\n"
+
+	config := processor.NewConfig()
+	config.Normalizer = normalizer.NewConfig()
+	config.Normalizer.FuzzyMode = true
+	config.Workers = 1
+	config.MaxExamples = 5
+
+	proc := processor.New(config)
+	entries, err := proc.Process(strings.NewReader(input))
+	if err != nil {
+		t.Fatalf("Process() error = %v", err)
+	}
+	// An entry escaped by a URL round trip still reads as a template
+	entries = append(entries, deduplicator.Entry{URL: "https://a.com/p/%7Bid%7D", Count: 1})
+
+	var buf bytes.Buffer
+	formatter := &output.TemplateFormatter{Examples: proc.Examples()}
+	if err := formatter.Format(entries, &buf); err != nil {
+		t.Fatalf("Format() error = %v", err)
+	}
+
+	var groups []output.TemplateGroup
+	if err := json.Unmarshal(buf.Bytes(), &groups); err != nil {
+		t.Fatalf("output is not valid JSON: %v\n%s", err, buf.String())
+	}
+	if len(groups) != 2 {
+		t.Fatalf("Expected 2 template groups, got %d", len(groups))
+	}
+
+	if groups[0].Template != "https://a.com/u/{id}" || groups[0].Count != 2 {
+		t.Errorf("groups[0] = %+v; want https://a.com/u/{id} with count 2", groups[0])
+	}
+	want := []string{"https://a.com/u/1", "https://a.com/u/2"}
+	if strings.Join(groups[0].Examples, ",") != strings.Join(want, ",") {
+		t.Errorf("Examples = %v; want %v", groups[0].Examples, want)
+	}
+	if groups[1].Template != "https://a.com/p/{id}" {
+		t.Errorf("groups[1].Template = %q; want https://a.com/p/{id}", groups[1].Template)
+	}
+}
+
+func TestEndToEndExcludeStatus(t *testing.T) {
+	input := `https://example.com/ok [200]
+https://example.com/missing [404]
+[404] https://example.com/gone
+[200] https://example.com/also-ok
+https://example.com/plain
+`
+
+	config := processor.NewConfig()
+	config.Normalizer = normalizer.NewConfig()
+	config.Workers = 1
+	config.ExcludeStatus = map[int]struct{}{404: {}}
+
+	proc := processor.New(config)
+	entries, err := proc.Process(strings.NewReader(input))
+	if err != nil {
+		t.Fatalf("Process() error = %v", err)
+	}
+
+	want := []string{
+		"https://example.com/ok",
+		"https://example.com/also-ok",
+		"https://example.com/plain",
+	}
+	if len(entries) != len(want) {
+		t.Fatalf("Expected %d entries, got %d: %v", len(want), len(entries), entries)
+	}
+	for i, url := range want {
+		if entries[i].URL != url {
+			t.Errorf("Entry[%d] = %q; want %q", i, entries[i].URL, url)
+		}
+	}
+
+	if filtered := proc.GetStatistics().Filtered; filtered != 2 {
+		t.Errorf("Filtered = %d; want 2", filtered)
+	}
+}
+
+func TestParseStatusAnnotation(t *testing.T) {
+	tests := []struct {
+		line   string
+		url    string
+		status int
+	}{
+		{"https://example.com/a [404]", "https://example.com/a", 404},
+		{"[301] https://example.com/a", "https://example.com/a", 301},
+		{"https://example.com/a", "https://example.com/a", 0},
+		{"https://example.com/a [title]", "https://example.com/a [title]", 0},
+		{"https://example.com/a [999]", "https://example.com/a [999]", 0},
+	}
+
+	for _, tt := range tests {
+		url, status := processor.ParseStatusAnnotation(tt.line)
+		if url != tt.url || status != tt.status {
+			t.Errorf("ParseStatusAnnotation(%q) = (%q, %d); want (%q, %d)", tt.line, url, status, tt.url, tt.status)
+		}
+	}
+}
+
+func TestEndToEndKeepStatus(t *testing.T) {
+	input := `https://example.com/a [200]
+https://example.com/a [500]
+https://example.com/a [500]
+[301] https://example.com/b
+https://example.com/b [404]
+https://example.com/c
+`
+
+	config := processor.NewConfig()
+	config.Normalizer = normalizer.NewConfig()
+	config.Workers = 1
+	config.KeepStatus = true
+
+	proc := processor.New(config)
+	entries, err := proc.Process(strings.NewReader(input))
+	if err != nil {
+		t.Fatalf("Process() error = %v", err)
+	}
+
+	// Most common status wins; ties go to the higher code
+	want := []deduplicator.Entry{
+		{URL: "https://example.com/a", Count: 3, Status: 500},
+		{URL: "https://example.com/b", Count: 2, Status: 404},
+		{URL: "https://example.com/c", Count: 1},
+	}
+	if len(entries) != len(want) {
+		t.Fatalf("Expected %d entries, got %d: %v", len(want), len(entries), entries)
+	}
+	for i := range want {
+		if entries[i] != want[i] {
+			t.Errorf("Entry[%d] = %+v; want %+v", i, entries[i], want[i])
+		}
+	}
+
+	var buf bytes.Buffer
+	formatter := &output.TextFormatter{}
+	if err := formatter.Format(entries, &buf); err != nil {
+		t.Fatalf("Format() error = %v", err)
+	}
+	if !strings.HasPrefix(buf.String(), "[500] https://example.com/a\n") {
+		t.Errorf("text output should prefix the status, got:\n%s", buf.String())
+	}
+}
+
+func TestEndToEndKeepMethod(t *testing.T) {
+	input := `GET https://example.com/users
+POST https://example.com/users
+get https://example.com/users
+https://example.com/about
+`
+
+	for _, workers := range []int{1, 4} {
+		config := processor.NewConfig()
+		config.Normalizer = normalizer.NewConfig()
+		config.Workers = workers
+		config.KeepMethod = true
+
+		proc := processor.New(config)
+		entries, err := proc.Process(strings.NewReader(input))
+		if err != nil {
+			t.Fatalf("Process() error = %v", err)
+		}
+
+		// The method is not part of the key; every verb seen is reported
+		want := []deduplicator.Entry{
+			{URL: "https://example.com/users", Count: 3, Methods: "GET,POST"},
+			{URL: "https://example.com/about", Count: 1},
+		}
+		if len(entries) != len(want) {
+			t.Fatalf("workers=%d: expected %d entries, got %d: %v", workers, len(want), len(entries), entries)
+		}
+		for i := range want {
+			if entries[i] != want[i] {
+				t.Errorf("workers=%d: Entry[%d] = %+v; want %+v", workers, i, entries[i], want[i])
+			}
+		}
+	}
+}
+
+func TestEndToEndKeepNewest(t *testing.T) {
+	input := `2024-05-01T10:00:00Z https://example.com/page?v=old
+20240601120000 https://example.com/page?v=newest
+2024-05-15 https://example.com/page?v=mid
+https://example.com/about
+`
+
+	for _, workers := range []int{1, 4} {
+		config := processor.NewConfig()
+		config.Normalizer = normalizer.NewConfig()
+		config.Workers = workers
+		config.KeepNewest = true
+
+		proc := processor.New(config)
+		entries, err := proc.Process(strings.NewReader(input))
+		if err != nil {
+			t.Fatalf("Process() error = %v", err)
+		}
+
+		// The latest capture represents the group, whatever the input order
+		want := []deduplicator.Entry{
+			{URL: "https://example.com/page?v=newest", Count: 3},
+			{URL: "https://example.com/about", Count: 1},
+		}
+		if len(entries) != len(want) {
+			t.Fatalf("workers=%d: expected %d entries, got %d: %v", workers, len(want), len(entries), entries)
+		}
+		for i := range want {
+			if entries[i] != want[i] {
+				t.Errorf("workers=%d: Entry[%d] = %+v; want %+v", workers, i, entries[i], want[i])
+			}
+		}
+	}
+}
+
+func TestEndToEndDistinctVariants(t *testing.T) {
+	input := `https://example.com/api?id=1&sort=asc
+https://example.com/api?id=2&sort=asc
+https://example.com/api?id=1&sort=asc
+https://example.com/api?id=1&sort=desc
+https://example.com/about
+`
+
+	for _, workers := range []int{1, 4} {
+		config := processor.NewConfig()
+		config.Normalizer = normalizer.NewConfig()
+		config.Workers = workers
+		config.DistinctVariants = true
+
+		proc := processor.New(config)
+		entries, err := proc.Process(strings.NewReader(input))
+		if err != nil {
+			t.Fatalf("Process() error = %v", err)
+		}
+
+		want := []deduplicator.Entry{
+			{URL: "https://example.com/api?id=1&sort=asc", Count: 4, Variants: 3},
+			{URL: "https://example.com/about", Count: 1, Variants: 1},
+		}
+		if len(entries) != len(want) {
+			t.Fatalf("workers=%d: expected %d entries, got %d: %v", workers, len(want), len(entries), entries)
+		}
+		for i := range want {
+			if entries[i] != want[i] {
+				t.Errorf("workers=%d: Entry[%d] = %+v; want %+v", workers, i, entries[i], want[i])
+			}
+		}
+	}
+}
+
+func TestEndToEndHashMode(t *testing.T) {
+	input := "https://example.com/about\t9F86D081\n" +
+		"https://example.com/about-us?ref=nav\t9f86d081\n" +
+		"https://example.com/contact\t60303ae2\n" +
+		"https://example.com/no-hash\n" +
+		"https://example.com/about\t60303ae2\n"
+
+	for _, workers := range []int{1, 4} {
+		config := processor.NewConfig()
+		config.Normalizer = normalizer.NewConfig()
+		config.Normalizer.Mode = "hash"
+		config.Workers = workers
+
+		proc := processor.New(config)
+		entries, err := proc.Process(strings.NewReader(input))
+		if err != nil {
+			t.Fatalf("Process() error = %v", err)
+		}
+
+		// One representative URL per content hash, whatever the URL shape
+		want := []deduplicator.Entry{
+			{URL: "https://example.com/about", Count: 2},
+			{URL: "https://example.com/contact", Count: 2},
+		}
+		if len(entries) != len(want) {
+			t.Fatalf("workers=%d: expected %d entries, got %d: %v", workers, len(want), len(entries), entries)
+		}
+		for i := range want {
+			if entries[i] != want[i] {
+				t.Errorf("workers=%d: Entry[%d] = %+v; want %+v", workers, i, entries[i], want[i])
+			}
+		}
+
+		// The line without a hash is a parse error
+		if got := proc.GetStatistics().ParseErrors; got != 1 {
+			t.Errorf("workers=%d: ParseErrors = %d; want 1", workers, got)
+		}
+	}
+}
+
+func TestEndToEndMaxPerHost(t *testing.T) {
+	input := `https://big.example.com/a
+https://big.example.com/b
+https://small.example.com/a
+https://big.example.com/c
+https://big.example.com/a
+https://big.example.com/d
+https://small.example.com/b
+`
+
+	for _, workers := range []int{1, 4} {
+		config := processor.NewConfig()
+		config.Normalizer = normalizer.NewConfig()
+		config.Workers = workers
+		config.MaxPerHost = 2
+
+		proc := processor.New(config)
+		entries, err := proc.Process(strings.NewReader(input))
+		if err != nil {
+			t.Fatalf("Process() error = %v", err)
+		}
+
+		perHost := make(map[string]int)
+		for _, entry := range entries {
+			perHost[config.Normalizer.HostOf(entry.URL)]++
+		}
+		if perHost["big.example.com"] != 2 {
+			t.Errorf("workers=%d: big.example.com kept %d entries; want 2: %v", workers, perHost["big.example.com"], entries)
+		}
+		if perHost["small.example.com"] != 2 {
+			t.Errorf("workers=%d: small.example.com kept %d entries; want 2: %v", workers, perHost["small.example.com"], entries)
+		}
+
+		// Repeats of a kept URL are duplicates, not capped. Workers may
+		// reorder lines, which changes which URLs are kept.
+		st := proc.GetStatistics()
+		if st.HostCapped+st.Duplicates != 3 {
+			t.Errorf("workers=%d: HostCapped = %d, Duplicates = %d; want 3 dropped in total", workers, st.HostCapped, st.Duplicates)
+		}
+		if workers == 1 && (st.HostCapped != 2 || st.Duplicates != 1) {
+			t.Errorf("HostCapped = %d, Duplicates = %d; want 2 and 1", st.HostCapped, st.Duplicates)
+		}
+	}
+}
+
+func TestEndToEndSchemeBreakdown(t *testing.T) {
+	input := `http://example.com/login
+https://example.com/login
+HTTPS://example.com/login?next=/
+https://example.com/about
+`
+
+	for _, workers := range []int{1, 4} {
+		config := processor.NewConfig()
+		config.Normalizer = normalizer.NewConfig()
+		config.Normalizer.Mode = "path"
+		config.Workers = workers
+		config.SchemeBreakdown = true
+
+		proc := processor.New(config)
+		entries, err := proc.Process(strings.NewReader(input))
+		if err != nil {
+			t.Fatalf("Process() error = %v", err)
+		}
+
+		want := []deduplicator.Entry{
+			{URL: "example.com/login", Count: 3, Schemes: deduplicator.SchemeCounts{HTTP: 1, HTTPS: 2}},
+			{URL: "example.com/about", Count: 1, Schemes: deduplicator.SchemeCounts{HTTPS: 1}},
+		}
+		if len(entries) != len(want) {
+			t.Fatalf("workers=%d: expected %d entries, got %d: %v", workers, len(want), len(entries), entries)
+		}
+		for i := range want {
+			if entries[i] != want[i] {
+				t.Errorf("workers=%d: Entry[%d] = %+v; want %+v", workers, i, entries[i], want[i])
+			}
+		}
+	}
+
+	// The breakdown is a JSON object, omitted when scheme tracking is off
+	var buf bytes.Buffer
+	entries := []deduplicator.Entry{
+		{URL: "example.com/login", Count: 3, Schemes: deduplicator.SchemeCounts{HTTP: 1, HTTPS: 2}},
+		{URL: "example.com/about", Count: 1},
+	}
+	if err := (&output.NDJSONFormatter{}).Format(entries, &buf); err != nil {
+		t.Fatalf("Format() error = %v", err)
+	}
+	want := `{"url":"example.com/login","count":3,"schemes":{"http":1,"https":2}}
+{"url":"example.com/about","count":1}
+`
+	if buf.String() != want {
+		t.Errorf("NDJSON = %q; want %q", buf.String(), want)
+	}
+}
+
+func TestDetailedStatistics(t *testing.T) {
+	input := `https://api.example.com/app.js?v=1
+https://api.example.com/app.js?v=2&utm_source=x
+https://other.com/login
+https://api.example.com/style.CSS
+`
+
+	for _, workers := range []int{1, 4} {
+		config := processor.NewConfig()
+		config.Normalizer = normalizer.NewConfig()
+		config.Normalizer.IgnoreParams = normalizer.ParseSet("utm_source")
+		config.Workers = workers
+		config.DetailedStats = true
+
+		proc := processor.New(config)
+		if _, err := proc.Process(strings.NewReader(input)); err != nil {
+			t.Fatalf("Process() error = %v", err)
+		}
+
+		st := proc.GetStatistics()
+		if st.TopDomains["api.example.com"] != 3 || st.TopDomains["other.com"] != 1 {
+			t.Errorf("workers=%d: TopDomains = %v", workers, st.TopDomains)
+		}
+		// Parameters are counted after normalization, so ignored ones are absent
+		if st.ParamFrequency["v"] != 2 || st.ParamFrequency["utm_source"] != 0 {
+			t.Errorf("workers=%d: ParamFrequency = %v", workers, st.ParamFrequency)
+		}
+		if st.ExtensionCount["js"] != 2 || st.ExtensionCount["css"] != 1 {
+			t.Errorf("workers=%d: ExtensionCount = %v", workers, st.ExtensionCount)
+		}
+	}
+
+	// Without DetailedStats nothing extra is recorded
+	proc := processor.New(&processor.Config{Normalizer: normalizer.NewConfig(), Workers: 1})
+	if _, err := proc.Process(strings.NewReader(input)); err != nil {
+		t.Fatalf("Process() error = %v", err)
+	}
+	if len(proc.GetStatistics().TopDomains) != 0 {
+		t.Errorf("TopDomains = %v; want empty without DetailedStats", proc.GetStatistics().TopDomains)
+	}
+}
+
+// TestParallelStatisticsRace exercises concurrent stats updates from the
+// reader and collector goroutines; run with -race to catch regressions
+func TestParallelStatisticsRace(t *testing.T) {
+	var input strings.Builder
+	for i := 0; i < 2000; i++ {
+		switch i % 4 {
+		case 0:
+			fmt.Fprintf(&input, "https://example.com/img%d.jpg\n", i) // filtered by a worker
+		case 1:
+			fmt.Fprintf(&input, "https://example.com/gone%d [404]\n", i) // filtered by the reader
+		default:
+			fmt.Fprintf(&input, "https://example.com/page%d\n", i%100)
+		}
+	}
+
+	config := processor.NewConfig()
+	config.Normalizer = normalizer.NewConfig()
+	config.Normalizer.IgnoreExtensions = normalizer.ParseSet("jpg")
+	config.ExcludeStatus = map[int]struct{}{404: {}}
+	config.Workers = 4
+
+	proc := processor.New(config)
+
+	// Poll the counters while processing runs
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 1000; i++ {
+			proc.GetStatistics().Counts()
+		}
+	}()
+
+	if _, err := proc.Process(strings.NewReader(input.String())); err != nil {
+		t.Fatalf("Process() error = %v", err)
+	}
+	<-done
+
+	counts := proc.GetStatistics().Counts()
+	if counts.TotalProcessed != 2000 {
+		t.Errorf("TotalProcessed = %d; want 2000", counts.TotalProcessed)
+	}
+	if counts.Filtered != 1000 {
+		t.Errorf("Filtered = %d; want 1000", counts.Filtered)
+	}
+	if counts.UniqueURLs+counts.Duplicates != 1000 {
+		t.Errorf("UniqueURLs + Duplicates = %d; want 1000", counts.UniqueURLs+counts.Duplicates)
+	}
+}
+
+func TestLimitedInput(t *testing.T) {
+	input := "https://example.com/a\nhttps://example.com/b\nhttps://example.com/c\n" // 22 bytes per line
+
+	tests := []struct {
+		limit     int64
+		want      string
+		truncated bool
+	}{
+		{30, "https://example.com/a\n", true},                        // cut mid-line
+		{43, "https://example.com/a\nhttps://example.com/b", true},   // cut right before a newline
+		{44, "https://example.com/a\nhttps://example.com/b\n", true}, // cut on a line boundary
+		{66, input, false}, // exact fit
+		{1000, input, false},
+		{10, "", true},
+	}
+
+	for _, tt := range tests {
+		limited := processor.NewLimitedInput(strings.NewReader(input), tt.limit)
+		data, err := io.ReadAll(limited)
+		if err != nil {
+			t.Fatalf("limit %d: ReadAll() error = %v", tt.limit, err)
+		}
+		if string(data) != tt.want {
+			t.Errorf("limit %d: read %q; want %q", tt.limit, data, tt.want)
+		}
+		if limited.Truncated() != tt.truncated {
+			t.Errorf("limit %d: Truncated() = %v; want %v", tt.limit, limited.Truncated(), tt.truncated)
+		}
+	}
+}
+
+// eofOnceReader serves data, reports io.EOF once and fails any read after
+// that, like a pipe whose next read would block
+type eofOnceReader struct {
+	t    *testing.T
+	data string
+	eof  bool
+}
+
+func (r *eofOnceReader) Read(p []byte) (int, error) {
+	if r.eof {
+		r.t.Error("read past io.EOF")
+		return 0, io.EOF
+	}
+	if r.data == "" {
+		r.eof = true
+		return 0, io.EOF
+	}
+	n := copy(p, r.data)
+	r.data = r.data[n:]
+	return n, nil
+}
+
+func TestLimitedInputNoPeekBelowLimit(t *testing.T) {
+	input := "https://example.com/a\nhttps://example.com/b"
+
+	limited := processor.NewLimitedInput(&eofOnceReader{t: t, data: input}, 1000)
+	data, err := io.ReadAll(limited)
+	if err != nil {
+		t.Fatalf("ReadAll() error = %v", err)
+	}
+	if string(data) != input {
+		t.Errorf("read %q; want %q", data, input)
+	}
+	if limited.Truncated() {
+		t.Error("Truncated() = true; want false when the input ends below the limit")
+	}
+}
+
+func TestEndToEndInputLimitBytes(t *testing.T) {
+	var input strings.Builder
+	for i := 0; i < 100; i++ {
+		fmt.Fprintf(&input, "https://example.com/page%03d\n", i) // 28 bytes per line
+	}
+
+	config := processor.NewConfig()
+	config.Normalizer = normalizer.NewConfig()
+	config.Workers = 1
+	config.InputLimitBytes = 28*10 + 5
+
+	proc := processor.New(config)
+	entries, err := proc.Process(strings.NewReader(input.String()))
+	if err != nil {
+		t.Fatalf("Process() error = %v", err)
+	}
+
+	// Stops at the last line boundary before the cap
+	if len(entries) != 10 {
+		t.Errorf("got %d entries; want 10", len(entries))
+	}
+	if last := entries[len(entries)-1].URL; last != "https://example.com/page009" {
+		t.Errorf("last entry = %q; want https://example.com/page009", last)
+	}
+	if !proc.GetStatistics().InputTruncated {
+		t.Error("InputTruncated should be set when the cap is reached")
+	}
+}
+
+func TestEndToEndProcessFiles(t *testing.T) {
+	dir := t.TempDir()
+	first := filepath.Join(dir, "first.txt")
+	second := filepath.Join(dir, "second.txt")
+	// The first file has no trailing newline
+	if err := os.WriteFile(first, []byte("https://example.com/a\nhttps://example.com/b"), 0644); err != nil {
+		t.Fatalf("WriteFile() error = %v", err)
+	}
+	if err := os.WriteFile(second, []byte("https://example.com/b\nhttps://example.com/c\n"), 0644); err != nil {
+		t.Fatalf("WriteFile() error = %v", err)
+	}
+
+	config := processor.NewConfig()
+	config.Normalizer = normalizer.NewConfig()
+	config.Workers = 1
+
+	proc := processor.New(config)
+	entries, err := proc.ProcessFiles([]string{first, filepath.Join(dir, "missing.txt"), second})
+	if err != nil {
+		t.Fatalf("ProcessFiles() error = %v", err)
+	}
+
+	if len(entries) != 3 {
+		t.Errorf("got %d entries; want 3: %v", len(entries), entries)
+	}
+	if total := proc.GetStatistics().TotalProcessed; total != 4 {
+		t.Errorf("TotalProcessed = %d; want 4 across both files", total)
+	}
+
+	// Only fails when no input can be read
+	proc = processor.New(config)
+	if _, err := proc.ProcessFiles([]string{filepath.Join(dir, "missing.txt")}); err == nil {
+		t.Error("ProcessFiles() should fail when every input is missing")
+	}
+}
+
+func TestEndToEndGzipInput(t *testing.T) {
+	var compressed bytes.Buffer
+	zw := gzip.NewWriter(&compressed)
+	zw.Write([]byte("https://example.com/a?id=1\nhttps://example.com/a?id=2\nhttps://example.com/b\n"))
+	if err := zw.Close(); err != nil {
+		t.Fatalf("gzip Close() error = %v", err)
+	}
+
+	dir := t.TempDir()
+	gzipped := filepath.Join(dir, "urls.txt.gz")
+	plain := filepath.Join(dir, "urls.txt")
+	if err := os.WriteFile(gzipped, compressed.Bytes(), 0644); err != nil {
+		t.Fatalf("WriteFile() error = %v", err)
+	}
+	// Plain input must survive the magic-byte peek untouched
+	if err := os.WriteFile(plain, []byte("https://example.com/b\nhttps://example.com/c"), 0644); err != nil {
+		t.Fatalf("WriteFile() error = %v", err)
+	}
+
+	config := processor.NewConfig()
+	config.Normalizer = normalizer.NewConfig()
+	config.Workers = 1
+
+	proc := processor.New(config)
+	entries, err := proc.ProcessFiles([]string{gzipped, plain})
+	if err != nil {
+		t.Fatalf("ProcessFiles() error = %v", err)
+	}
+	if len(entries) != 3 {
+		t.Errorf("got %d entries; want 3: %v", len(entries), entries)
+	}
+	st := proc.GetStatistics()
+	if st.TotalProcessed != 5 || st.Duplicates != 2 {
+		t.Errorf("TotalProcessed = %d, Duplicates = %d; want 5 and 2", st.TotalProcessed, st.Duplicates)
+	}
+
+	// Stdin is decompressed through the same helper
+	r, err := processor.Decompress(bytes.NewReader(compressed.Bytes()))
+	if err != nil {
+		t.Fatalf("Decompress() error = %v", err)
+	}
+	proc = processor.New(config)
+	if entries, err = proc.Process(r); err != nil {
+		t.Fatalf("Process() error = %v", err)
+	}
+	if len(entries) != 2 {
+		t.Errorf("got %d entries from gzipped stream; want 2: %v", len(entries), entries)
+	}
+}
+
+// noisyURL is one logical URL written out with its params in a random order
+// and an optional fragment, as generated for TestQueryFragmentOrderNoise
+type noisyURL struct {
+	Params []string // "name=value" pairs of the logical URL
+}
+
+// Generate builds a logical URL with up to six distinct params
+func (noisyURL) Generate(r *rand.Rand, size int) reflect.Value {
+	n := 1 + r.Intn(6)
+	params := make([]string, n)
+	for i := range params {
+		params[i] = fmt.Sprintf("p%d=%d", i, r.Intn(100))
+	}
+	return reflect.ValueOf(noisyURL{Params: params})
+}
+
+// variant renders the URL with shuffled params and maybe a fragment
+func (u noisyURL) variant(r *rand.Rand) string {
+	params := append([]string(nil), u.Params...)
+	r.Shuffle(len(params), func(i, j int) { params[i], params[j] = params[j], params[i] })
+
+	line := "https://example.com/search?" + strings.Join(params, "&")
+	if r.Intn(2) == 0 {
+		line += fmt.Sprintf("#section%d", r.Intn(10))
+	}
+	return line
+}
+
+func TestQueryFragmentOrderNoise(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+
+	property := func(u noisyURL) bool {
+		var input strings.Builder
+		for i := 0; i < 8; i++ {
+			input.WriteString(u.variant(r) + "\n")
+		}
+
+		// What --strip-query-fragment-order-noise configures
+		config := processor.NewConfig()
+		config.Normalizer = normalizer.NewConfig()
+		config.Normalizer.SortParams = true
+		config.Normalizer.IgnoreFragment = true
+		config.Workers = 1
+
+		proc := processor.New(config)
+		entries, err := proc.Process(strings.NewReader(input.String()))
+		if err != nil {
+			t.Logf("Process() error = %v", err)
+			return false
+		}
+		if len(entries) != 1 || entries[0].Count != 8 {
+			t.Logf("input:\n%s got %v", input.String(), entries)
+			return false
+		}
+
+		// The output is the same whichever variant came first
+		sorted := append([]string(nil), u.Params...)
+		sort.Strings(sorted)
+		return entries[0].URL == "https://example.com/search?"+strings.Join(sorted, "&")
+	}
+
+	if err := quick.Check(property, &quick.Config{MaxCount: 200, Rand: r}); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestDeduplicateSliceMatchesProcess(t *testing.T) {
+	urls := []string{
+		"https://www.example.com/users/123?utm_source=x#top",
+		"https://example.com/users/456",
+		"",
+		"https://example.com/about?b=2&a=1",
+		"https://example.com/about?a=1&b=2",
+		"https://example.com/logo.png",
+	}
+
+	newConfig := func() *processor.Config {
+		config := processor.NewConfig()
+		config.Normalizer = normalizer.NewConfig()
+		config.Normalizer.FuzzyMode = true
+		config.Normalizer.SortParams = true
+		config.Normalizer.IgnoreParams = map[string]struct{}{"utm_source": {}}
+		config.Normalizer.IgnoreExtensions = map[string]struct{}{"png": {}}
+		config.Workers = 1
+		return config
+	}
+
+	want, err := processor.New(newConfig()).Process(strings.NewReader(strings.Join(urls, "\n")))
+	if err != nil {
+		t.Fatalf("Process() error = %v", err)
+	}
+
+	got, st, err := processor.DeduplicateSlice(urls, newConfig())
+	if err != nil {
+		t.Fatalf("DeduplicateSlice() error = %v", err)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("DeduplicateSlice() = %v; want %v from Process", got, want)
+	}
+	if st.TotalProcessed != len(urls) || st.UniqueURLs != 2 || st.Filtered != 1 {
+		t.Errorf("stats processed=%d unique=%d filtered=%d; want 6, 2, 1", st.TotalProcessed, st.UniqueURLs, st.Filtered)
+	}
+}
@@ -0,0 +1,64 @@
+package unit
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/lcalzada-xor/dupdurl/pkg/config"
+	"github.com/lcalzada-xor/dupdurl/pkg/normalizer"
+	"github.com/lcalzada-xor/dupdurl/pkg/processor"
+)
+
+func TestConfigLocalePriority(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yml")
+	data := `locale-priority: [en, es, de]
+profiles:
+  spanish:
+    locale-priority: [es, en]
+    locale-dedup: true
+`
+	if err := os.WriteFile(path, []byte(data), 0644); err != nil {
+		t.Fatalf("WriteFile() error = %v", err)
+	}
+
+	file, err := config.Load(path)
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	if got := strings.Join(file.LocalePriority, ","); got != "en,es,de" || file.LocaleDedup {
+		t.Errorf("Load() locale priority = %q, dedup = %v; want en,es,de and false", got, file.LocaleDedup)
+	}
+
+	file, err = config.LoadWithProfile(path, "spanish")
+	if err != nil {
+		t.Fatalf("LoadWithProfile() error = %v", err)
+	}
+	if got := strings.Join(file.LocalePriority, ","); got != "es,en" || !file.LocaleDedup {
+		t.Errorf("profile locale priority = %q, dedup = %v; want es,en and true", got, file.LocaleDedup)
+	}
+
+	// The priority reaches both the deduplicator and the coverage grouper
+	procConfig := processor.NewConfig()
+	procConfig.Normalizer = normalizer.NewConfig()
+	procConfig.Normalizer.LocalePriority = file.LocalePriority
+	procConfig.LocaleDedup = file.LocaleDedup
+	procConfig.LocaleCoverage = true
+	procConfig.Workers = 1
+
+	proc := processor.New(procConfig)
+	input := "https://example.com/fr/about\nhttps://example.com/en/about\nhttps://example.com/es/about\n"
+	entries, err := proc.Process(strings.NewReader(input))
+	if err != nil {
+		t.Fatalf("Process() error = %v", err)
+	}
+	if len(entries) != 1 || entries[0].URL != "https://example.com/es/about" || entries[0].Count != 3 {
+		t.Errorf("entries = %+v; want the es variant with count 3", entries)
+	}
+
+	coverage := proc.LocaleCoverage()
+	if len(coverage) != 1 || coverage[0].URL != "https://example.com/es/about" {
+		t.Errorf("LocaleCoverage() = %+v; want the es variant as best URL", coverage)
+	}
+}
@@ -1,7 +1,10 @@
 package unit
 
 import (
+	"fmt"
+	"sync"
 	"testing"
+	"time"
 
 	"github.com/lcalzada-xor/dupdurl/pkg/deduplicator"
 	"github.com/lcalzada-xor/dupdurl/pkg/stats"
@@ -125,3 +128,278 @@ func TestDeduplicatorClear(t *testing.T) {
 		t.Errorf("GetEntries() after Clear() length = %d; want 0", len(entries))
 	}
 }
+
+func TestDeduplicatorFingerprintKeys(t *testing.T) {
+	first := deduplicator.New(stats.NewStatistics())
+	for _, add := range [][2]string{{"key1", "url-1a"}, {"key2", "url-2"}, {"key3", "url-3"}, {"key1", "url-1b"}} {
+		first.Add(add[0], add[1])
+	}
+
+	// Same keys in another order, so key1 is printed as another URL
+	second := deduplicator.New(stats.NewStatistics())
+	for _, add := range [][2]string{{"key3", "url-3"}, {"key1", "url-1b"}, {"key2", "url-2"}, {"key1", "url-1a"}} {
+		second.Add(add[0], add[1])
+	}
+
+	a := first.FingerprintKeys(first.GetEntries())
+	b := second.FingerprintKeys(second.GetEntries())
+	if a != b {
+		t.Errorf("FingerprintKeys() differs for the same key set: %s vs %s", a, b)
+	}
+
+	// Dropping an entry, e.g. by scope filtering, changes it
+	filtered := first.GetEntries()[:2]
+	if c := first.FingerprintKeys(filtered); c == a {
+		t.Errorf("FingerprintKeys() should differ once an entry is filtered out")
+	}
+}
+
+func TestDeduplicatorMaxUnique(t *testing.T) {
+	st := stats.NewStatistics()
+	dedup := deduplicator.New(st)
+	dedup.SetMaxUnique(3)
+
+	// key1 and key2 are popular, the rest are seen once
+	for i := 0; i < 3; i++ {
+		dedup.Add("key1", "url1")
+		dedup.Add("key2", "url2")
+	}
+	dedup.Add("key3", "url3")
+	dedup.Add("key4", "url4")
+	if dedup.Count() > 3 {
+		t.Fatalf("Count() = %d; exceeds cap of 3", dedup.Count())
+	}
+	dedup.Add("key5", "url5")
+	if dedup.Count() > 3 {
+		t.Fatalf("Count() = %d; exceeds cap of 3", dedup.Count())
+	}
+
+	entries := dedup.GetEntries()
+	urls := make(map[string]bool)
+	for _, entry := range entries {
+		urls[entry.URL] = true
+	}
+	if !urls["url1"] || !urls["url2"] {
+		t.Errorf("high-count entries should survive eviction, got %v", entries)
+	}
+	if urls["url3"] {
+		t.Errorf("oldest low-count entry should be evicted first, got %v", entries)
+	}
+	if !urls["url5"] {
+		t.Errorf("newest entry should be kept, got %v", entries)
+	}
+
+	if st.Evicted != 2 {
+		t.Errorf("Evicted = %d; want 2", st.Evicted)
+	}
+	if st.UniqueURLs != len(entries) {
+		t.Errorf("UniqueURLs = %d; want the %d retained entries", st.UniqueURLs, len(entries))
+	}
+}
+
+func TestDeduplicatorAddWithOriginalMaxUnique(t *testing.T) {
+	st := stats.NewStatistics()
+	dedup := deduplicator.New(st)
+	dedup.SetMaxUnique(2)
+
+	for _, key := range []string{"key1", "key1", "key2", "key3", "key4"} {
+		dedup.AddWithOriginal(key, "url-"+key, "orig-"+key)
+	}
+
+	if dedup.Count() > 2 {
+		t.Fatalf("Count() = %d; exceeds cap of 2", dedup.Count())
+	}
+	if st.Evicted == 0 {
+		t.Error("Evicted = 0; AddWithOriginal should evict past the cap")
+	}
+}
+
+func TestClusterNear(t *testing.T) {
+	entries := []deduplicator.Entry{
+		{URL: "https://example.com/report-a1b2", Count: 2},
+		{URL: "https://example.com/login", Count: 1},
+		{URL: "https://example.com/report-a1b3", Count: 1},
+		{URL: "https://example.com/report-x1b3", Count: 1},
+		{URL: "https://example.com/account/settings", Count: 4},
+		{URL: "https://other.org/report-a1b2", Count: 1},
+	}
+
+	clusters, ok := deduplicator.ClusterNear(entries, 2, 100)
+	if !ok {
+		t.Fatal("ClusterNear() skipped below the candidate cap")
+	}
+
+	want := []deduplicator.Entry{
+		{URL: "https://example.com/report-a1b2", Count: 4},
+		{URL: "https://example.com/login", Count: 1},
+		{URL: "https://example.com/account/settings", Count: 4},
+		{URL: "https://other.org/report-a1b2", Count: 1},
+	}
+	if len(clusters) != len(want) {
+		t.Fatalf("ClusterNear() = %v; want %v", clusters, want)
+	}
+	for i := range want {
+		if clusters[i] != want[i] {
+			t.Errorf("cluster[%d] = %v; want %v", i, clusters[i], want[i])
+		}
+	}
+
+	// Above the candidate cap the pass is skipped
+	skipped, ok := deduplicator.ClusterNear(entries, 2, 3)
+	if ok || len(skipped) != len(entries) {
+		t.Errorf("ClusterNear() above cap = (%d entries, %v); want unchanged and false", len(skipped), ok)
+	}
+}
+
+func TestDeduplicatorKeepMostCommon(t *testing.T) {
+	dedup := deduplicator.New(stats.NewStatistics())
+	dedup.SetKeepMostCommon(true)
+
+	template := "https://example.com/user/{id}"
+	for _, example := range []string{
+		"https://example.com/user/1",
+		"https://example.com/user/7",
+		"https://example.com/user/7",
+		"https://example.com/user/2",
+		"https://example.com/user/7",
+	} {
+		dedup.AddItem(deduplicator.Item{Key: template, URL: template, Example: example})
+	}
+
+	// A tie goes to the variant seen first
+	dedup.AddItem(deduplicator.Item{Key: "k2", URL: "t2", Example: "b"})
+	dedup.AddItem(deduplicator.Item{Key: "k2", URL: "t2", Example: "a"})
+
+	entries := dedup.GetEntries()
+	if len(entries) != 2 {
+		t.Fatalf("GetEntries() = %v; want 2 entries", entries)
+	}
+	if entries[0].URL != "https://example.com/user/7" || entries[0].Count != 5 {
+		t.Errorf("entry[0] = %v; want majority variant user/7 with count 5", entries[0])
+	}
+	if entries[1].URL != "b" {
+		t.Errorf("entry[1].URL = %q; want first-seen variant b on a tie", entries[1].URL)
+	}
+}
+
+func TestDeduplicatorCountVariants(t *testing.T) {
+	dedup := deduplicator.New(stats.NewStatistics())
+	dedup.SetCountVariants(true)
+
+	key := "https://example.com/search?q&page"
+	for _, example := range []string{
+		"https://example.com/search?q=a&page=1",
+		"https://example.com/search?q=b&page=1",
+		"https://example.com/search?q=a&page=1",
+		"https://example.com/search?q=a&page=2",
+	} {
+		dedup.AddItem(deduplicator.Item{Key: key, URL: example, Example: example})
+	}
+	dedup.AddItem(deduplicator.Item{Key: "k2", URL: "u2", Example: "u2"})
+
+	entries := dedup.GetEntries()
+	if len(entries) != 2 {
+		t.Fatalf("GetEntries() = %v; want 2 entries", entries)
+	}
+	// Counting variants does not change the representative
+	if entries[0].URL != "https://example.com/search?q=a&page=1" || entries[0].Count != 4 || entries[0].Variants != 3 {
+		t.Errorf("entry[0] = %+v; want first-seen URL, count 4 and 3 variants", entries[0])
+	}
+	if entries[1].Variants != 1 {
+		t.Errorf("entry[1].Variants = %d; want 1", entries[1].Variants)
+	}
+}
+
+func TestDeduplicatorKeepNewest(t *testing.T) {
+	dedup := deduplicator.New(stats.NewStatistics())
+	dedup.SetKeepNewest(true)
+
+	at := func(s string) time.Time {
+		ts, err := time.Parse(time.RFC3339, s)
+		if err != nil {
+			t.Fatalf("time.Parse(%q) error = %v", s, err)
+		}
+		return ts
+	}
+
+	dedup.AddItem(deduplicator.Item{Key: "k1", URL: "https://example.com/a?v=1", Time: at("2024-01-01T00:00:00Z")})
+	dedup.AddItem(deduplicator.Item{Key: "k1", URL: "https://example.com/a?v=3", Time: at("2024-03-01T00:00:00Z")})
+	dedup.AddItem(deduplicator.Item{Key: "k1", URL: "https://example.com/a?v=2", Time: at("2024-02-01T00:00:00Z")})
+
+	// Untimestamped items never replace a timestamped variant
+	dedup.AddItem(deduplicator.Item{Key: "k2", URL: "b-untimed"})
+	dedup.AddItem(deduplicator.Item{Key: "k2", URL: "b-timed", Time: at("2024-01-01T00:00:00Z")})
+	dedup.AddItem(deduplicator.Item{Key: "k2", URL: "b-untimed-later"})
+
+	entries := dedup.GetEntries()
+	if len(entries) != 2 {
+		t.Fatalf("GetEntries() = %v; want 2 entries", entries)
+	}
+	if entries[0].URL != "https://example.com/a?v=3" || entries[0].Count != 3 {
+		t.Errorf("entry[0] = %v; want newest variant a?v=3 with count 3", entries[0])
+	}
+	if entries[1].URL != "b-timed" {
+		t.Errorf("entry[1].URL = %q; want the only timestamped variant b-timed", entries[1].URL)
+	}
+}
+
+func TestDeduplicatorOnDuplicate(t *testing.T) {
+	dedup := deduplicator.New(stats.NewStatistics())
+
+	var dups []deduplicator.Duplicate
+	dedup.SetOnDuplicate(func(dup deduplicator.Duplicate) {
+		dups = append(dups, dup)
+	})
+
+	dedup.AddItem(deduplicator.Item{Key: "k1", URL: "https://example.com/a", Line: "https://EXAMPLE.com/a"})
+	dedup.AddItem(deduplicator.Item{Key: "k2", URL: "https://example.com/b"})
+	dedup.AddItem(deduplicator.Item{Key: "k1", URL: "https://example.com/a", Line: "https://www.example.com/a/"})
+	dedup.AddItem(deduplicator.Item{Key: "k2", URL: "https://example.com/b"})
+
+	want := []deduplicator.Duplicate{
+		{Line: "https://www.example.com/a/", Key: "k1", Representative: "https://example.com/a"},
+		{Line: "https://example.com/b", Key: "k2", Representative: "https://example.com/b"},
+	}
+	if len(dups) != len(want) {
+		t.Fatalf("got %d duplicate events; want %d: %v", len(dups), len(want), dups)
+	}
+	for i := range want {
+		if dups[i] != want[i] {
+			t.Errorf("duplicate[%d] = %+v; want %+v", i, dups[i], want[i])
+		}
+	}
+}
+
+func TestDeduplicatorConcurrentAdd(t *testing.T) {
+	st := stats.NewStatistics()
+	dedup := deduplicator.New(st)
+
+	const goroutines, adds, keys = 8, 10000, 100
+	var wg sync.WaitGroup
+	for g := 0; g < goroutines; g++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < adds; i++ {
+				key := fmt.Sprintf("https://example.com/%d", i%keys)
+				dedup.Add(key, key)
+				if i%1000 == 0 {
+					dedup.Count()
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	if dedup.Count() != keys {
+		t.Fatalf("Count() = %d; want %d", dedup.Count(), keys)
+	}
+	for _, entry := range dedup.GetEntries() {
+		if entry.Count != goroutines*adds/keys {
+			t.Errorf("%s count = %d; want %d", entry.URL, entry.Count, goroutines*adds/keys)
+		}
+	}
+	if st.UniqueURLs != keys || st.Duplicates != goroutines*adds-keys {
+		t.Errorf("stats unique=%d dupes=%d; want %d and %d", st.UniqueURLs, st.Duplicates, keys, goroutines*adds-keys)
+	}
+}
@@ -0,0 +1,223 @@
+package unit
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/lcalzada-xor/dupdurl/pkg/deduplicator"
+	"github.com/lcalzada-xor/dupdurl/pkg/diff"
+	"github.com/lcalzada-xor/dupdurl/pkg/output"
+)
+
+func TestDiffWebhookPayload(t *testing.T) {
+	differ := diff.NewDiffer()
+	differ.LoadBaselineFromEntries([]deduplicator.Entry{
+		{URL: "https://example.com/old", Count: 1},
+	})
+
+	report := differ.Compare([]deduplicator.Entry{
+		{URL: "https://example.com/old", Count: 1},
+		{URL: "https://example.com/new1", Count: 2},
+		{URL: "https://example.com/new2", Count: 1},
+	})
+
+	path := filepath.Join(t.TempDir(), "webhook.json")
+	if err := report.SaveWebhookJSON(path); err != nil {
+		t.Fatalf("SaveWebhookJSON() error = %v", err)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("ReadFile() error = %v", err)
+	}
+
+	var payload map[string]interface{}
+	if err := json.Unmarshal(data, &payload); err != nil {
+		t.Fatalf("payload is not valid JSON: %v", err)
+	}
+
+	if len(payload) != 2 {
+		t.Errorf("payload has %d fields; want only added and count", len(payload))
+	}
+	if count, _ := payload["count"].(float64); count != 2 {
+		t.Errorf("count = %v; want 2", payload["count"])
+	}
+	added, _ := payload["added"].([]interface{})
+	if len(added) != 2 || added[0] != "https://example.com/new1" || added[1] != "https://example.com/new2" {
+		t.Errorf("added = %v; want the two new URLs", payload["added"])
+	}
+}
+
+func TestDiffTextBaseline(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "baseline.txt")
+	baseline := "https://example.com/kept\n\n# previous run\nhttps://example.com/gone\n"
+	if err := os.WriteFile(path, []byte(baseline), 0644); err != nil {
+		t.Fatalf("WriteFile() error = %v", err)
+	}
+
+	differ := diff.NewDiffer()
+	if err := differ.LoadBaselineText(path); err != nil {
+		t.Fatalf("LoadBaselineText() error = %v", err)
+	}
+
+	report := differ.Compare([]deduplicator.Entry{
+		{URL: "https://example.com/kept", Count: 5},
+		{URL: "https://example.com/new", Count: 1},
+	})
+
+	if len(report.Added) != 1 || report.Added[0] != "https://example.com/new" {
+		t.Errorf("Added = %v; want [https://example.com/new]", report.Added)
+	}
+	if len(report.Removed) != 1 || report.Removed[0] != "https://example.com/gone" {
+		t.Errorf("Removed = %v; want [https://example.com/gone]", report.Removed)
+	}
+	// Text baselines have no counts, so nothing is reported as changed
+	if len(report.Changed) != 0 {
+		t.Errorf("Changed = %v; want none for a text baseline", report.Changed)
+	}
+
+	// LoadBaselineFile detects the format on its own
+	auto := diff.NewDiffer()
+	if err := auto.LoadBaselineFile(path); err != nil {
+		t.Fatalf("LoadBaselineFile() error = %v", err)
+	}
+	if got := auto.Compare(nil); len(got.Removed) != 2 {
+		t.Errorf("LoadBaselineFile() loaded %d URLs; want 2", len(got.Removed))
+	}
+}
+
+func TestDiffCSVBaseline(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "counts.csv")
+	err := output.WriteCountsFile([]deduplicator.Entry{
+		{URL: "https://example.com/kept", Count: 2},
+		{URL: "https://example.com/list?ids=1,2", Count: 1},
+		{URL: "https://example.com/gone", Count: 4},
+	}, path)
+	if err != nil {
+		t.Fatalf("WriteCountsFile() error = %v", err)
+	}
+
+	differ := diff.NewDiffer()
+	if err := differ.LoadBaselineFile(path); err != nil {
+		t.Fatalf("LoadBaselineFile() error = %v", err)
+	}
+
+	report := differ.Compare([]deduplicator.Entry{
+		{URL: "https://example.com/kept", Count: 5},
+		{URL: "https://example.com/list?ids=1,2", Count: 1},
+		{URL: "https://example.com/new", Count: 1},
+	})
+
+	// The header is skipped and quoted URLs with commas are read back whole
+	if len(report.Added) != 1 || report.Added[0] != "https://example.com/new" {
+		t.Errorf("Added = %v; want [https://example.com/new]", report.Added)
+	}
+	if len(report.Removed) != 1 || report.Removed[0] != "https://example.com/gone" {
+		t.Errorf("Removed = %v; want [https://example.com/gone]", report.Removed)
+	}
+	// CSV rows carry counts, so changes are reported
+	if len(report.Changed) != 1 || report.Changed[0].OldCount != 2 || report.Changed[0].NewCount != 5 {
+		t.Errorf("Changed = %+v; want kept going from 2 to 5", report.Changed)
+	}
+}
+
+func TestDiffReportJSONSchema(t *testing.T) {
+	differ := diff.NewDiffer()
+	differ.LoadBaselineFromEntries([]deduplicator.Entry{
+		{URL: "https://example.com/a", Count: 5},
+		{URL: "https://example.com/b", Count: 1},
+	})
+
+	report := differ.Compare([]deduplicator.Entry{
+		{URL: "https://example.com/a", Count: 2},
+		{URL: "https://example.com/b", Count: 4},
+	})
+
+	data, err := report.ToJSON()
+	if err != nil {
+		t.Fatalf("ToJSON() error = %v", err)
+	}
+
+	var decoded diff.DiffReport
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		t.Fatalf("report is not valid JSON: %v", err)
+	}
+
+	if decoded.Schema != "dupdurl.diff.v1" {
+		t.Errorf("schema = %q; want dupdurl.diff.v1", decoded.Schema)
+	}
+
+	deltas := make(map[string]int)
+	for _, change := range decoded.Changed {
+		deltas[change.URL] = change.Delta
+	}
+	if deltas["https://example.com/a"] != -3 {
+		t.Errorf("delta for /a = %d; want -3", deltas["https://example.com/a"])
+	}
+	if deltas["https://example.com/b"] != 3 {
+		t.Errorf("delta for /b = %d; want 3", deltas["https://example.com/b"])
+	}
+}
+
+func TestDiffPrintSummary(t *testing.T) {
+	differ := diff.NewDiffer()
+	differ.LoadBaselineFromEntries([]deduplicator.Entry{
+		{URL: "https://example.com/old", Count: 1},
+		{URL: "https://example.com/kept", Count: 1},
+	})
+
+	report := differ.Compare([]deduplicator.Entry{
+		{URL: "https://example.com/kept", Count: 3},
+		{URL: "https://example.com/new", Count: 1},
+	})
+
+	var buf bytes.Buffer
+	report.PrintSummary(&buf)
+
+	want := "Added: 1, Removed: 1, Changed: 1\n"
+	if buf.String() != want {
+		t.Errorf("PrintSummary() = %q; want only %q", buf.String(), want)
+	}
+}
+
+func TestDiffFilterNewAcrossBaselines(t *testing.T) {
+	dir := t.TempDir()
+	jsonPath := filepath.Join(dir, "day1.json")
+	if err := diff.SaveBaseline([]deduplicator.Entry{{URL: "https://example.com/a", Count: 3}}, jsonPath); err != nil {
+		t.Fatalf("SaveBaseline() error = %v", err)
+	}
+	textPath := filepath.Join(dir, "day2.txt")
+	if err := os.WriteFile(textPath, []byte("https://example.com/b\n"), 0644); err != nil {
+		t.Fatalf("WriteFile() error = %v", err)
+	}
+
+	differ := diff.NewDiffer()
+	for _, path := range []string{jsonPath, textPath} {
+		if err := differ.LoadBaselineFile(path); err != nil {
+			t.Fatalf("LoadBaselineFile(%s) error = %v", path, err)
+		}
+	}
+
+	// A URL present in any baseline is excluded
+	fresh := differ.FilterNew([]deduplicator.Entry{
+		{URL: "https://example.com/c", Count: 1},
+		{URL: "https://example.com/a", Count: 5},
+		{URL: "https://example.com/b", Count: 1},
+		{URL: "https://example.com/d", Count: 2},
+	})
+	want := []deduplicator.Entry{
+		{URL: "https://example.com/c", Count: 1},
+		{URL: "https://example.com/d", Count: 2},
+	}
+	if len(fresh) != len(want) {
+		t.Fatalf("FilterNew() = %v; want %v", fresh, want)
+	}
+	for i := range want {
+		if fresh[i] != want[i] {
+			t.Errorf("FilterNew()[%d] = %+v; want %+v", i, fresh[i], want[i])
+		}
+	}
+}
@@ -1,8 +1,13 @@
 package unit
 
 import (
+	"errors"
+	"regexp"
+	"strings"
 	"testing"
+	"time"
 
+	"github.com/lcalzada-xor/dupdurl/pkg/locale"
 	"github.com/lcalzada-xor/dupdurl/pkg/normalizer"
 )
 
@@ -18,6 +23,9 @@ func TestNormalizePath(t *testing.T) {
 		{"trailing slash", "/api/users/", "/api/users"},
 		{"multiple slashes", "/api//users///profile", "/api/users/profile"},
 		{"no leading slash", "api/users", "/api/users"},
+		{"dot segments", "/api/./users/../teams/", "/api/teams"},
+		{"dot at root", "/.", "/"},
+		{"climb above root", "/../api", "/api"},
 	}
 
 	for _, tt := range tests {
@@ -40,6 +48,7 @@ func TestFuzzyPath(t *testing.T) {
 		{"multiple IDs", "/api/123/items/456", "/api/{id}/items/{id}"},
 		{"no ID", "/api/users/profile", "/api/users/profile"},
 		{"trailing ID", "/api/users/123", "/api/users/{id}"},
+		{"consecutive IDs", "/a/1/2/3/b", "/a/{id}/{id}/{id}/b"},
 	}
 
 	for _, tt := range tests {
@@ -265,3 +274,1093 @@ func TestExtensionFilter(t *testing.T) {
 		})
 	}
 }
+
+func TestKeepFragment(t *testing.T) {
+	config := normalizer.NewConfig()
+	config.IgnoreFragment = false
+
+	keyA, err := config.CreateDedupKey("https://example.com/page#a")
+	if err != nil {
+		t.Fatalf("CreateDedupKey() error = %v", err)
+	}
+	keyB, err := config.CreateDedupKey("https://example.com/page#b")
+	if err != nil {
+		t.Fatalf("CreateDedupKey() error = %v", err)
+	}
+	if keyA == keyB {
+		t.Errorf("fragments should stay distinct when kept, both got %q", keyA)
+	}
+
+	normalized, err := config.NormalizeURL("https://example.com/page#a")
+	if err != nil {
+		t.Fatalf("NormalizeURL() error = %v", err)
+	}
+	if normalized != "https://example.com/page#a" {
+		t.Errorf("NormalizeURL() = %q; want fragment preserved", normalized)
+	}
+
+	config.Mode = "path"
+	pathA, _ := config.NormalizeLine("https://example.com/page#a")
+	pathB, _ := config.NormalizeLine("https://example.com/page#b")
+	if pathA != "example.com/page#a" || pathB != "example.com/page#b" {
+		t.Errorf("path mode = %q, %q; want fragments preserved", pathA, pathB)
+	}
+
+	// Default behavior still discards fragments
+	defaults := normalizer.NewConfig()
+	keyA, _ = defaults.CreateDedupKey("https://example.com/page#a")
+	keyB, _ = defaults.CreateDedupKey("https://example.com/page#b")
+	if keyA != keyB {
+		t.Errorf("fragments should collapse by default: %q vs %q", keyA, keyB)
+	}
+}
+
+func TestFuzzyHostNumbers(t *testing.T) {
+	config := normalizer.NewConfig()
+	config.FuzzyHostNumbers = true
+
+	hosts := []string{
+		"https://web01.example.com/index",
+		"https://web02.example.com/index",
+		"https://WEB17.example.com/index",
+	}
+
+	var first string
+	for i, raw := range hosts {
+		key, err := config.CreateDedupKey(raw)
+		if err != nil {
+			t.Fatalf("CreateDedupKey(%q) error = %v", raw, err)
+		}
+		if i == 0 {
+			first = key
+		} else if key != first {
+			t.Errorf("CreateDedupKey(%q) = %q; want %q", raw, key, first)
+		}
+	}
+
+	// Registered domain and non-numbered labels are not fuzzed
+	a, _ := config.CreateDedupKey("https://api.example1.com/index")
+	b, _ := config.CreateDedupKey("https://api.example2.com/index")
+	if a == b {
+		t.Errorf("registered domain digits should not be fuzzed, both got %q", a)
+	}
+
+	// Output keeps the original host
+	normalized, _ := config.NormalizeURL("https://web01.example.com/index")
+	if normalized != "https://web01.example.com/index" {
+		t.Errorf("NormalizeURL() = %q; want original host preserved", normalized)
+	}
+
+	// Host and path modes fold the key too, keeping the output host
+	for _, mode := range []string{"host", "path"} {
+		config.Mode = mode
+		key1, out1, _ := config.NormalizeWithKey(hosts[0])
+		key2, _, _ := config.NormalizeWithKey(hosts[1])
+		if key1 != key2 {
+			t.Errorf("%s mode keys %q and %q; want numbered hosts folded", mode, key1, key2)
+		}
+		if !strings.HasPrefix(out1, "web01.example.com") {
+			t.Errorf("%s mode output = %q; want original host preserved", mode, out1)
+		}
+	}
+
+	// Disabled by default
+	defaults := normalizer.NewConfig()
+	a, _ = defaults.CreateDedupKey(hosts[0])
+	b, _ = defaults.CreateDedupKey(hosts[1])
+	if a == b {
+		t.Errorf("numbered hosts should stay distinct by default")
+	}
+}
+
+func TestCollapseIDRuns(t *testing.T) {
+	config := normalizer.NewConfig()
+	config.FuzzyMode = true
+	config.CollapseIDRuns = true
+	config.Mode = "path"
+
+	tests := []struct {
+		name     string
+		input    string
+		expected string
+	}{
+		{"id run", "https://example.com/a/1/2/3/b", "example.com/a/{ids}/b"},
+		{"trailing run", "https://example.com/a/1/2", "example.com/a/{ids}"},
+		{"single id untouched", "https://example.com/a/1/b", "example.com/a/{id}/b"},
+		{"separate ids untouched", "https://example.com/a/1/b/2", "example.com/a/{id}/b/{id}"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := config.NormalizeLine(tt.input)
+			if err != nil {
+				t.Fatalf("NormalizeLine() error = %v", err)
+			}
+			if result != tt.expected {
+				t.Errorf("NormalizeLine(%q) = %q; want %q", tt.input, result, tt.expected)
+			}
+		})
+	}
+
+	if got := normalizer.CollapseIDRuns("/a/{id}/{uuid}/b"); got != "/a/{ids}/b" {
+		t.Errorf("CollapseIDRuns() = %q; want /a/{ids}/b", got)
+	}
+}
+
+func TestStructuralPath(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		expected string
+	}{
+		{"numeric and mixed", "/a/123/b/ab12cd/c", "/a/{dyn}/b/{dyn}/c"},
+		{"uuid", "/orders/550E8400-e29b-41d4-a716-446655440000", "/orders/{dyn}"},
+		{"hash", "/files/d41d8cd98f00b204e9800998ecf8427e", "/files/{dyn}"},
+		{"long token", "/reset/Xk9qLmZp2RtYvB7w", "/reset/{dyn}"},
+		{"mixed case with a digit", "/s/aB3x", "/s/{dyn}"},
+		{"placeholder", "/users/{id}/posts", "/users/{dyn}/posts"},
+		{"structural segments kept", "/api/v2/oauth2/login-page/file.json", "/api/v2/oauth2/login-page/file.json"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := normalizer.StructuralPath(tt.input); got != tt.expected {
+				t.Errorf("StructuralPath(%q) = %q; want %q", tt.input, got, tt.expected)
+			}
+		})
+	}
+
+	// Applies on top of --fuzzy placeholders
+	config := normalizer.NewConfig()
+	config.StructuralPath = true
+	config.FuzzyMode = true
+	config.Mode = "path"
+	result, err := config.NormalizeLine("https://example.com/a/123/b/ab12cd/c")
+	if err != nil {
+		t.Fatalf("NormalizeLine() error = %v", err)
+	}
+	if result != "example.com/a/{dyn}/b/{dyn}/c" {
+		t.Errorf("NormalizeLine() = %q; want example.com/a/{dyn}/b/{dyn}/c", result)
+	}
+
+	// Path mode sees the original case, like url mode, and url mode keeps
+	// the placeholder readable
+	want := map[string]string{
+		"path": "a.com/a/{dyn}",
+		"url":  "https://a.com/a/{dyn}",
+	}
+	for mode, expected := range want {
+		config := normalizer.NewConfig()
+		config.StructuralPath = true
+		config.Mode = mode
+		result, err := config.NormalizeLine("https://a.com/a/aB3x")
+		if err != nil {
+			t.Fatalf("%s: NormalizeLine() error = %v", mode, err)
+		}
+		if result != expected {
+			t.Errorf("%s: NormalizeLine() = %q; want %q", mode, result, expected)
+		}
+	}
+}
+
+func TestKeepQueryOrder(t *testing.T) {
+	config := normalizer.NewConfig()
+	config.KeepQueryOrder = true
+	config.IgnoreParams = normalizer.ParseSet("utm_source")
+
+	keyAB, _ := config.CreateDedupKey("https://example.com/p?a=1&b=2")
+	keyBA, _ := config.CreateDedupKey("https://example.com/p?b=2&a=1")
+	if keyAB != keyBA {
+		t.Errorf("dedup keys should be order-insensitive: %q vs %q", keyAB, keyBA)
+	}
+
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"https://example.com/p?b=2&a=1", "https://example.com/p?b=2&a=1"},
+		{"https://example.com/p?z=1&utm_source=x&a=2", "https://example.com/p?z=1&a=2"},
+		{"https://example.com/p?q=a%20b&c", "https://example.com/p?q=a%20b&c"},
+	}
+
+	for _, tt := range tests {
+		result, err := config.NormalizeURL(tt.input)
+		if err != nil {
+			t.Fatalf("NormalizeURL(%q) error = %v", tt.input, err)
+		}
+		if result != tt.expected {
+			t.Errorf("NormalizeURL(%q) = %q; want %q", tt.input, result, tt.expected)
+		}
+	}
+}
+
+func TestPathExtension(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		expected string
+	}{
+		{"simple", "/static/app.js", "js"},
+		{"uppercase", "/IMAGE.PNG", "png"},
+		{"double extension", "/backup.tar.gz", "gz"},
+		{"dot in earlier segment", "/api.v1/users", ""},
+		{"trailing dot", "/file.", ""},
+		{"encoded slash", "/file.tar%2Fsomething", ""},
+		{"double encoded slash", "/file.tar%252Fsomething", ""},
+		{"encoded slash before extension", "/dir%2Ffile.pdf", "pdf"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := normalizer.PathExtension(tt.input); got != tt.expected {
+				t.Errorf("PathExtension(%q) = %q; want %q", tt.input, got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestExtensionFilterEncodedSlash(t *testing.T) {
+	blacklist := normalizer.NewConfig()
+	blacklist.IgnoreExtensions = normalizer.ParseSet("tar")
+
+	whitelist := normalizer.NewConfig()
+	whitelist.FilterExtensions = normalizer.ParseSet("tar")
+
+	for _, raw := range []string{
+		"https://example.com/file.tar%2Fsomething",
+		"https://example.com/file.tar%252Fsomething",
+	} {
+		// Not a .tar file, so the blacklist keeps it...
+		if _, err := blacklist.NormalizeURL(raw); err != nil {
+			t.Errorf("blacklist NormalizeURL(%q) error = %v; want kept", raw, err)
+		}
+		// ...and the whitelist sees no extension at all
+		if _, err := whitelist.NormalizeURL(raw); err == nil || !strings.Contains(err.Error(), "no extension") {
+			t.Errorf("whitelist NormalizeURL(%q) error = %v; want no extension", raw, err)
+		}
+	}
+}
+
+func TestDropEmptyQuery(t *testing.T) {
+	config := normalizer.NewConfig()
+	config.Mode = "path"
+	config.PathIncludeQuery = true
+	config.IgnoreParams = normalizer.ParseSet("utm")
+
+	bare, _ := config.NormalizeLine("https://example.com/x")
+	ignored, _ := config.NormalizeLine("https://example.com/x?utm=y")
+	if bare == ignored {
+		t.Fatalf("without the option %q and %q should stay distinct", bare, ignored)
+	}
+
+	config.DropEmptyQuery = true
+	bare, _ = config.NormalizeLine("https://example.com/x")
+	ignored, _ = config.NormalizeLine("https://example.com/x?utm=y")
+	if bare != "example.com/x" || ignored != "example.com/x" {
+		t.Errorf("NormalizeLine() = %q, %q; want both example.com/x", bare, ignored)
+	}
+
+	// Meaningful params are still kept
+	kept, _ := config.NormalizeLine("https://example.com/x?utm=y&a=1")
+	if kept != "example.com/x?a=1" {
+		t.Errorf("NormalizeLine() = %q; want example.com/x?a=1", kept)
+	}
+}
+
+func TestIgnoreParamsKeyEncoding(t *testing.T) {
+	config := normalizer.NewConfig()
+	config.IgnoreParams = normalizer.ParseSet("utm_source")
+
+	inputs := []string{
+		"https://example.com/p?%75tm_source=x&id=1",
+		"https://example.com/p?utm%5Fsource=x&id=1",
+		"https://example.com/p?UTM_Source=x&id=1",
+	}
+
+	for _, input := range inputs {
+		result, err := config.NormalizeURL(input)
+		if err != nil {
+			t.Fatalf("NormalizeURL(%q) error = %v", input, err)
+		}
+		if result != "https://example.com/p?id=1" {
+			t.Errorf("NormalizeURL(%q) = %q; want https://example.com/p?id=1", input, result)
+		}
+
+		key, err := config.CreateDedupKey(input)
+		if err != nil {
+			t.Fatalf("CreateDedupKey(%q) error = %v", input, err)
+		}
+		if key != "https://example.com/p?id=" {
+			t.Errorf("CreateDedupKey(%q) = %q; want https://example.com/p?id=", input, key)
+		}
+	}
+
+	config.KeepQueryOrder = true
+	result, _ := config.NormalizeURL("https://example.com/p?id=1&%55TM_source=x")
+	if result != "https://example.com/p?id=1" {
+		t.Errorf("KeepQueryOrder result = %q; want https://example.com/p?id=1", result)
+	}
+}
+
+func TestLocaleScanAllSegments(t *testing.T) {
+	config := normalizer.NewConfig()
+	config.LocaleDetector = locale.NewDetector()
+	config.LocaleDetector.ScanAllSegments = true
+
+	keyEN, _ := config.CreateDedupKey("https://example.com/docs/help/en/article")
+	keyFR, _ := config.CreateDedupKey("https://example.com/docs/help/fr/article")
+	if keyEN != keyFR {
+		t.Errorf("mid-path locales should share a dedup key: %q vs %q", keyEN, keyFR)
+	}
+
+	// API paths keep their segments
+	keyV1, _ := config.CreateDedupKey("https://example.com/api/v1/de/items")
+	keyV2, _ := config.CreateDedupKey("https://example.com/api/v1/fr/items")
+	if keyV1 == keyV2 {
+		t.Errorf("API path segments should not be treated as locales: %q", keyV1)
+	}
+}
+
+func TestIgnoreScheme(t *testing.T) {
+	config := normalizer.NewConfig()
+	config.IgnoreScheme = true
+
+	inputs := []string{
+		"http://example.com/a/",
+		"https://example.com/a",
+		"HTTP://Example.com:80/a",
+		"https://example.com:443/a/",
+	}
+
+	for _, input := range inputs {
+		result, err := config.NormalizeURL(input)
+		if err != nil {
+			t.Fatalf("NormalizeURL(%q) error = %v", input, err)
+		}
+		if result != "https://example.com/a" {
+			t.Errorf("NormalizeURL(%q) = %q; want https://example.com/a", input, result)
+		}
+	}
+
+	// Non-default ports stay part of the URL
+	result, _ := config.NormalizeURL("http://example.com:8080/a")
+	if result != "https://example.com:8080/a" {
+		t.Errorf("NormalizeURL with port = %q; want https://example.com:8080/a", result)
+	}
+}
+
+func TestNormalizeURLIdempotent(t *testing.T) {
+	inputs := []string{
+		"http://Example.com/a/",
+		"https://www.example.com/a//b/",
+		"HTTPS://example.com:443/a?b=1&a=2",
+		"https://example.com/a%20b?q=a+b&x=%2F",
+		"https://example.com/a b?q=a b",
+		"https://example.com/users/123/?id=1#frag",
+		"https://example.com/caf%C3%A9/",
+		"https://example.com/café?q=é",
+		"https://example.com/a?x",
+		"https://example.com/a?b=1&b=2",
+		"https://example.com/a%2fb/c",
+		"https://example.com/a?q=%7e&r=%41",
+		"http://example.com:80/a?",
+		"https://example.com/a;p?x=1",
+	}
+
+	configs := map[string]func(*normalizer.Config){
+		"default":      func(c *normalizer.Config) {},
+		"fuzzy-sorted": func(c *normalizer.Config) { c.FuzzyMode = true; c.SortParams = true },
+		"query-order":  func(c *normalizer.Config) { c.KeepQueryOrder = true },
+		"cross-scheme": func(c *normalizer.Config) { c.IgnoreScheme = true },
+		"ignore-param": func(c *normalizer.Config) { c.IgnoreParams = normalizer.ParseSet("b") },
+	}
+
+	for name, apply := range configs {
+		config := normalizer.NewConfig()
+		apply(config)
+
+		for _, input := range inputs {
+			once, err := config.NormalizeURL(input)
+			if err != nil {
+				t.Fatalf("%s: NormalizeURL(%q) error = %v", name, input, err)
+			}
+			twice, err := config.NormalizeURL(once)
+			if err != nil {
+				t.Fatalf("%s: NormalizeURL(%q) error = %v", name, once, err)
+			}
+			if once != twice {
+				t.Errorf("%s: not idempotent for %q: %q -> %q", name, input, once, twice)
+			}
+
+			keyOnce, _ := config.CreateDedupKey(input)
+			keyTwice, _ := config.CreateDedupKey(once)
+			if keyOnce != keyTwice {
+				t.Errorf("%s: dedup key changed after normalizing %q: %q -> %q", name, input, keyOnce, keyTwice)
+			}
+		}
+	}
+}
+
+func TestBareHostFormsCollapse(t *testing.T) {
+	forms := []string{
+		"https://example.com",
+		"https://example.com/",
+		"https://example.com//",
+		"https://example.com?",
+		"https://example.com/?",
+		"https://example.com#top",
+		"https://example.com:443",
+		"https://example.com/.",
+		"https://EXAMPLE.com/./",
+	}
+
+	for _, mode := range []string{"url", "path", "host"} {
+		config := normalizer.NewConfig()
+		config.Mode = mode
+		config.PathIncludeQuery = true
+
+		wantKey, wantOut, err := config.NormalizeWithKey(forms[0])
+		if err != nil {
+			t.Fatalf("%s: NormalizeWithKey(%q) error = %v", mode, forms[0], err)
+		}
+
+		for _, form := range forms[1:] {
+			key, out, err := config.NormalizeWithKey(form)
+			if err != nil {
+				t.Fatalf("%s: NormalizeWithKey(%q) error = %v", mode, form, err)
+			}
+			if key != wantKey || out != wantOut {
+				t.Errorf("%s: %q -> (%q, %q); want (%q, %q)", mode, form, key, out, wantKey, wantOut)
+			}
+		}
+	}
+}
+
+func TestDedupParamValues(t *testing.T) {
+	config := normalizer.NewConfig()
+	config.DedupValues = true
+
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"https://example.com/p?tag=a&tag=b&tag=a", "https://example.com/p?tag=a&tag=b"},
+		{"https://example.com/p?tag=b&tag=a&tag=b", "https://example.com/p?tag=a&tag=b"},
+		{"https://example.com/p?id=1&tag=x&tag=x", "https://example.com/p?id=1&tag=x"},
+		{"https://example.com/p?tag=&tag=", "https://example.com/p?tag="},
+	}
+
+	for _, tt := range tests {
+		result, err := config.NormalizeURL(tt.input)
+		if err != nil {
+			t.Fatalf("NormalizeURL(%q) error = %v", tt.input, err)
+		}
+		if result != tt.expected {
+			t.Errorf("NormalizeURL(%q) = %q; want %q", tt.input, result, tt.expected)
+		}
+	}
+
+	// Without the option repeated values are kept
+	config.DedupValues = false
+	result, _ := config.NormalizeURL("https://example.com/p?tag=a&tag=b&tag=a")
+	if result != "https://example.com/p?tag=a&tag=b&tag=a" {
+		t.Errorf("NormalizeURL without DedupValues = %q", result)
+	}
+}
+
+func TestCollapseAMP(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"/amp/article", "/article"},
+		{"/article/amp", "/article"},
+		{"/news/article/AMP", "/news/article"},
+		{"/amp", "/amp"},
+		{"/ampersand/article", "/ampersand/article"},
+		{"/news/amp/article", "/news/amp/article"},
+	}
+
+	for _, tt := range tests {
+		if got := normalizer.CollapseAMP(tt.input); got != tt.expected {
+			t.Errorf("CollapseAMP(%q) = %q; want %q", tt.input, got, tt.expected)
+		}
+	}
+
+	config := normalizer.NewConfig()
+	config.CollapseAMP = true
+	for _, input := range []string{
+		"https://example.com/amp/article",
+		"https://example.com/article/amp",
+		"https://example.com/article",
+	} {
+		key, _ := config.CreateDedupKey(input)
+		if key != "https://example.com/article" {
+			t.Errorf("CreateDedupKey(%q) = %q; want https://example.com/article", input, key)
+		}
+	}
+}
+
+func TestRegisteredDomain(t *testing.T) {
+	tests := []struct {
+		host string
+		want string
+	}{
+		{"example.com", "example.com"},
+		{"api.example.com", "example.com"},
+		{"a.b.Example.COM:8443", "example.com"},
+		{"shop.example.co.uk", "example.co.uk"},
+		{"example.co.uk", "example.co.uk"},
+		{"localhost", "localhost"},
+		{"192.168.1.10:8080", "192.168.1.10"},
+		{"", ""},
+	}
+
+	for _, tt := range tests {
+		if got := normalizer.RegisteredDomain(tt.host); got != tt.want {
+			t.Errorf("RegisteredDomain(%q) = %q; want %q", tt.host, got, tt.want)
+		}
+	}
+}
+
+func TestFQDNTrailingDot(t *testing.T) {
+	forms := []string{
+		"https://example.com./about",
+		"https://Example.COM.:443/about",
+	}
+
+	for _, mode := range []string{"url", "host"} {
+		config := normalizer.NewConfig()
+		config.Mode = mode
+
+		wantKey, wantOut, err := config.NormalizeWithKey("https://example.com/about")
+		if err != nil {
+			t.Fatalf("%s: NormalizeWithKey() error = %v", mode, err)
+		}
+		for _, form := range forms {
+			key, out, err := config.NormalizeWithKey(form)
+			if err != nil {
+				t.Fatalf("%s: NormalizeWithKey(%q) error = %v", mode, form, err)
+			}
+			if key != wantKey || out != wantOut {
+				t.Errorf("%s: %q -> (%q, %q); want (%q, %q)", mode, form, key, out, wantKey, wantOut)
+			}
+		}
+	}
+
+	// --keep-fqdn-dot keeps the two forms apart
+	config := normalizer.NewConfig()
+	config.KeepFQDNDot = true
+	got, err := config.NormalizeURL("https://example.com./about")
+	if err != nil {
+		t.Fatalf("NormalizeURL() error = %v", err)
+	}
+	if got != "https://example.com./about" {
+		t.Errorf("NormalizeURL() with KeepFQDNDot = %q; want https://example.com./about", got)
+	}
+}
+
+func TestIgnoreStateParams(t *testing.T) {
+	config := normalizer.NewConfig()
+	config.StateParams = normalizer.ParseSet(normalizer.DefaultStateParams)
+
+	wantKey, err := config.CreateDedupKey("https://example.com/dashboard")
+	if err != nil {
+		t.Fatalf("CreateDedupKey() error = %v", err)
+	}
+
+	for param := range config.StateParams {
+		for _, value := range []string{"1", "2"} {
+			input := "https://example.com/dashboard?" + param + "=" + value
+			key, out, err := config.NormalizeWithKey(input)
+			if err != nil {
+				t.Fatalf("NormalizeWithKey(%q) error = %v", input, err)
+			}
+			if key != wantKey {
+				t.Errorf("key(%q) = %q; want %q", input, key, wantKey)
+			}
+			// Output keeps the state param
+			if out != input {
+				t.Errorf("output(%q) = %q; want unchanged", input, out)
+			}
+		}
+	}
+
+	// Other params still distinguish endpoints
+	key, _ := config.CreateDedupKey("https://example.com/dashboard?id=1&Tab=2")
+	if key != "https://example.com/dashboard?id=" {
+		t.Errorf("CreateDedupKey() = %q; want https://example.com/dashboard?id=", key)
+	}
+
+	// Path mode with the query keys on the same params
+	pathConfig := normalizer.NewConfig()
+	pathConfig.Mode = "path"
+	pathConfig.PathIncludeQuery = true
+	pathConfig.StateParams = config.StateParams
+	out, err := pathConfig.NormalizeLine("https://example.com/dashboard?id=1&tab=2")
+	if err != nil {
+		t.Fatalf("NormalizeLine() error = %v", err)
+	}
+	if out != "example.com/dashboard?id=1" {
+		t.Errorf("path mode NormalizeLine() = %q; want example.com/dashboard?id=1", out)
+	}
+
+	// An overridden set only drops the listed params
+	config.StateParams = normalizer.ParseSet("panel")
+	key, _ = config.CreateDedupKey("https://example.com/dashboard?tab=1")
+	if key == wantKey {
+		t.Errorf("tab should not be ignored with an overridden set, got %q", key)
+	}
+}
+
+func TestIPHostNormalization(t *testing.T) {
+	config := normalizer.NewConfig()
+	config.FuzzyHostNumbers = true
+
+	tests := []struct {
+		input       string
+		expected    string
+		expectedKey string
+	}{
+		{"http://192.168.1.1:80/a", "http://192.168.1.1/a", "http://192.168.1.1/a"},
+		{"https://10.0.0.12:8443/a", "https://10.0.0.12:8443/a", "https://10.0.0.12:8443/a"},
+		{"http://[::1]:8080/a", "http://[::1]:8080/a", "http://[::1]:8080/a"},
+		{"http://en.192.168.1.1/about", "http://en.192.168.1.1/about", "http://en.192.168.1.1/about"},
+	}
+
+	for _, tt := range tests {
+		key, out, err := config.NormalizeWithKey(tt.input)
+		if err != nil {
+			t.Fatalf("NormalizeWithKey(%q) error = %v", tt.input, err)
+		}
+		if out != tt.expected {
+			t.Errorf("NormalizeWithKey(%q) output = %q; want %q", tt.input, out, tt.expected)
+		}
+		if key != tt.expectedKey {
+			t.Errorf("NormalizeWithKey(%q) key = %q; want %q", tt.input, key, tt.expectedKey)
+		}
+	}
+
+	if got := normalizer.RegisteredDomain("192.168.1.1"); got != "192.168.1.1" {
+		t.Errorf("RegisteredDomain(192.168.1.1) = %q; want 192.168.1.1", got)
+	}
+}
+
+func TestEncodedHostPort(t *testing.T) {
+	for _, mode := range []string{"url", "host", "path"} {
+		config := normalizer.NewConfig()
+		config.Mode = mode
+
+		wantKey, wantOut, err := config.NormalizeWithKey("https://example.com:8080/a")
+		if err != nil {
+			t.Fatalf("%s: NormalizeWithKey() error = %v", mode, err)
+		}
+
+		for _, form := range []string{
+			"https://example.com%3A8080/a",
+			"https://example.com%3a8080/a",
+			"https://EXAMPLE.com%3A8080/a",
+		} {
+			key, out, err := config.NormalizeWithKey(form)
+			if err != nil {
+				t.Fatalf("%s: NormalizeWithKey(%q) error = %v", mode, form, err)
+			}
+			if key != wantKey || out != wantOut {
+				t.Errorf("%s: %q -> (%q, %q); want (%q, %q)", mode, form, key, out, wantKey, wantOut)
+			}
+		}
+	}
+
+	// Escapes that decode to characters not allowed in a host stay an error
+	config := normalizer.NewConfig()
+	if _, err := config.NormalizeURL("https://example.com%2Fevil/a"); err == nil {
+		t.Error("NormalizeURL() should reject a host that decodes to a slash")
+	}
+}
+
+func TestWWWApexOnly(t *testing.T) {
+	config := normalizer.NewConfig()
+	config.WWWApexOnly = true
+
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"https://www.example.com/about", "https://example.com/about"},
+		{"https://www.example.co.uk/about", "https://example.co.uk/about"},
+		{"https://www.example.com:8443/about", "https://example.com:8443/about"},
+		{"https://www.blog.example.com/about", "https://www.blog.example.com/about"},
+		{"https://www.blog.example.co.uk/about", "https://www.blog.example.co.uk/about"},
+	}
+
+	for _, tt := range tests {
+		got, err := config.NormalizeURL(tt.input)
+		if err != nil {
+			t.Fatalf("NormalizeURL(%q) error = %v", tt.input, err)
+		}
+		if got != tt.expected {
+			t.Errorf("NormalizeURL(%q) = %q; want %q", tt.input, got, tt.expected)
+		}
+	}
+
+	// Without the option every www. prefix is stripped
+	got, err := normalizer.NewConfig().NormalizeURL("https://www.blog.example.com/about")
+	if err != nil {
+		t.Fatalf("NormalizeURL() error = %v", err)
+	}
+	if got != "https://blog.example.com/about" {
+		t.Errorf("NormalizeURL() = %q; want https://blog.example.com/about", got)
+	}
+}
+
+func TestSemicolonQuery(t *testing.T) {
+	config := normalizer.NewConfig()
+	config.SemicolonQuery = true
+
+	wantKey, wantOut, err := config.NormalizeWithKey("https://example.com/page?a=1&b=2")
+	if err != nil {
+		t.Fatalf("NormalizeWithKey() error = %v", err)
+	}
+	key, out, err := config.NormalizeWithKey("https://example.com/page?a=1;b=2")
+	if err != nil {
+		t.Fatalf("NormalizeWithKey() error = %v", err)
+	}
+	if key != wantKey || out != wantOut {
+		t.Errorf("?a=1;b=2 -> (%q, %q); want (%q, %q)", key, out, wantKey, wantOut)
+	}
+
+	config.Mode = "params"
+	params, err := config.NormalizeLine("https://example.com/page?a=1;b=2")
+	if err != nil {
+		t.Fatalf("NormalizeLine() error = %v", err)
+	}
+	if params != "a,b" {
+		t.Errorf("params mode = %q; want a,b", params)
+	}
+
+	// Only the query is split; path and fragment keep their semicolons
+	got := normalizer.SplitSemicolonQuery("https://example.com/a;v=1?x=1;y=2#f;g")
+	if got != "https://example.com/a;v=1?x=1&y=2#f;g" {
+		t.Errorf("SplitSemicolonQuery() = %q", got)
+	}
+}
+
+func TestParseCustomPattern(t *testing.T) {
+	pattern, err := normalizer.ParseCustomPattern("v[0-9]+=ver")
+	if err != nil {
+		t.Fatalf("ParseCustomPattern() error = %v", err)
+	}
+	patterns := append(normalizer.GetDefaultPatterns(), pattern)
+
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"/api/v2/users/123", "/api/{ver}/users/{id}"},
+		{"/api/v1/v2", "/api/{ver}/{ver}"},
+		{"/api/v2beta/users", "/api/v2beta/users"},
+		{"/api/dev1/users", "/api/dev1/users"},
+	}
+	for _, tt := range tests {
+		if got := normalizer.ApplyFuzzyPatterns(tt.input, patterns); got != tt.expected {
+			t.Errorf("ApplyFuzzyPatterns(%q) = %q; want %q", tt.input, got, tt.expected)
+		}
+	}
+
+	for _, spec := range []string{"v[0-9+=ver", "v[0-9]+", "=ver", "(v)[0-9]+=ver"} {
+		if _, err := normalizer.ParseCustomPattern(spec); err == nil {
+			t.Errorf("ParseCustomPattern(%q) should fail", spec)
+		}
+	}
+}
+
+func TestSchemeDefaultPorts(t *testing.T) {
+	config := normalizer.NewConfig()
+	config.KeepScheme = true
+
+	tests := []struct {
+		withPort string
+		without  string
+	}{
+		{"ftp://host.example.com:21/x", "ftp://host.example.com/x"},
+		{"ws://host.example.com:80/socket", "ws://host.example.com/socket"},
+		{"wss://host.example.com:443/socket", "wss://host.example.com/socket"},
+		{"ssh://host.example.com:22/repo", "ssh://host.example.com/repo"},
+	}
+
+	for _, tt := range tests {
+		key, out, err := config.NormalizeWithKey(tt.withPort)
+		if err != nil {
+			t.Fatalf("NormalizeWithKey(%q) error = %v", tt.withPort, err)
+		}
+		wantKey, wantOut, err := config.NormalizeWithKey(tt.without)
+		if err != nil {
+			t.Fatalf("NormalizeWithKey(%q) error = %v", tt.without, err)
+		}
+		if key != wantKey || out != wantOut {
+			t.Errorf("%q -> (%q, %q); want (%q, %q)", tt.withPort, key, out, wantKey, wantOut)
+		}
+		if out != tt.without {
+			t.Errorf("NormalizeWithKey(%q) output = %q; want %q", tt.withPort, out, tt.without)
+		}
+	}
+
+	// A port is only dropped for the scheme it is the default of
+	got, err := config.NormalizeURL("ftp://host.example.com:22/x")
+	if err != nil {
+		t.Fatalf("NormalizeURL() error = %v", err)
+	}
+	if got != "ftp://host.example.com:22/x" {
+		t.Errorf("NormalizeURL() = %q; want the non-default port kept", got)
+	}
+}
+
+func TestPathRegisteredDomain(t *testing.T) {
+	config := normalizer.NewConfig()
+	config.Mode = "path"
+	config.PathRegisteredDomain = true
+
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"https://api.example.com/users", "example.com/users"},
+		{"http://www.example.com/users/", "example.com/users"},
+		{"https://example.com:8443/users", "example.com/users"},
+		{"https://a.b.example.co.uk/users", "example.co.uk/users"},
+		{"https://api.example.com/orders", "example.com/orders"},
+		{"https://api.other.com/users", "other.com/users"},
+	}
+
+	for _, tt := range tests {
+		key, out, err := config.NormalizeWithKey(tt.input)
+		if err != nil {
+			t.Fatalf("NormalizeWithKey(%q) error = %v", tt.input, err)
+		}
+		if key != tt.expected || out != tt.expected {
+			t.Errorf("NormalizeWithKey(%q) = (%q, %q); want %q", tt.input, key, out, tt.expected)
+		}
+	}
+}
+
+func TestPresenceMode(t *testing.T) {
+	config := normalizer.NewConfig()
+	config.Mode = "presence"
+
+	inputs := []string{
+		"https://example.com/search?a",
+		"https://example.com/search?a=",
+		"https://example.com/search?a=x",
+	}
+	for _, input := range inputs {
+		key, out, err := config.NormalizeWithKey(input)
+		if err != nil {
+			t.Fatalf("NormalizeWithKey(%q) error = %v", input, err)
+		}
+		if key != "https://example.com/search?a=" || out != key {
+			t.Errorf("NormalizeWithKey(%q) = (%q, %q); want https://example.com/search?a=", input, key, out)
+		}
+	}
+
+	// Every name is normalized, not just the last one
+	want := "https://example.com/search?a=&b="
+	for _, input := range []string{
+		"https://example.com/search?b&a=1",
+		"https://example.com/search?a=&b=2",
+		"https://example.com/search?a&b",
+	} {
+		got, err := config.NormalizeLine(input)
+		if err != nil {
+			t.Fatalf("NormalizeLine(%q) error = %v", input, err)
+		}
+		if got != want {
+			t.Errorf("NormalizeLine(%q) = %q; want %q", input, got, want)
+		}
+	}
+}
+
+func TestEmptyQueryCollapses(t *testing.T) {
+	forms := []string{
+		"https://example.com/x",
+		"https://example.com/x?",
+		"https://example.com/x?&",
+		"https://example.com/x?&&",
+	}
+
+	configs := map[string]func(*normalizer.Config){
+		"url":         func(c *normalizer.Config) {},
+		"query-order": func(c *normalizer.Config) { c.KeepQueryOrder = true },
+		"presence":    func(c *normalizer.Config) { c.Mode = "presence" },
+		"path-query":  func(c *normalizer.Config) { c.Mode = "path"; c.PathIncludeQuery = true },
+	}
+
+	for name, apply := range configs {
+		config := normalizer.NewConfig()
+		apply(config)
+
+		wantKey, wantOut, err := config.NormalizeWithKey(forms[0])
+		if err != nil {
+			t.Fatalf("%s: NormalizeWithKey(%q) error = %v", name, forms[0], err)
+		}
+		if strings.Contains(wantOut, "?") {
+			t.Errorf("%s: %q should have no query, got %q", name, forms[0], wantOut)
+		}
+
+		for _, form := range forms[1:] {
+			key, out, err := config.NormalizeWithKey(form)
+			if err != nil {
+				t.Fatalf("%s: NormalizeWithKey(%q) error = %v", name, form, err)
+			}
+			if key != wantKey || out != wantOut {
+				t.Errorf("%s: %q -> (%q, %q); want (%q, %q)", name, form, key, out, wantKey, wantOut)
+			}
+		}
+	}
+}
+
+func TestKeepParamValues(t *testing.T) {
+	inputs := []string{
+		"https://example.com/search?q=foo",
+		"https://example.com/search?q=bar",
+		"https://example.com/search?page=2&q=foo",
+		"https://example.com/search?q=foo&page=2",
+	}
+
+	keys := func(config *normalizer.Config) []string {
+		out := make([]string, len(inputs))
+		for i, input := range inputs {
+			key, err := config.CreateDedupKey(input)
+			if err != nil {
+				t.Fatalf("CreateDedupKey(%q) error = %v", input, err)
+			}
+			out[i] = key
+		}
+		return out
+	}
+
+	// By default only names count, so q=foo and q=bar collapse
+	names := keys(normalizer.NewConfig())
+	if names[0] != names[1] || names[2] != names[3] {
+		t.Errorf("names-only keys = %q; want values ignored", names)
+	}
+
+	config := normalizer.NewConfig()
+	config.KeepParamValues = true
+	values := keys(config)
+	if values[0] == values[1] {
+		t.Errorf("KeepParamValues: %q and %q should differ", inputs[0], inputs[1])
+	}
+	// Param order still doesn't matter
+	if values[2] != values[3] || values[2] != "https://example.com/search?page=2&q=foo" {
+		t.Errorf("KeepParamValues keys = %q, %q; want both https://example.com/search?page=2&q=foo", values[2], values[3])
+	}
+}
+
+func TestIgnoreValueCase(t *testing.T) {
+	config := normalizer.NewConfig()
+	config.KeepParamValues = true
+
+	red, _ := config.CreateDedupKey("https://example.com/shop?color=Red&Size=M")
+	lower, _ := config.CreateDedupKey("https://example.com/shop?color=red&Size=m")
+	if red == lower {
+		t.Errorf("without IgnoreValueCase, %q should differ from %q", red, lower)
+	}
+
+	config.IgnoreValueCase = true
+	red, _ = config.CreateDedupKey("https://example.com/shop?color=Red&Size=M")
+	lower, _ = config.CreateDedupKey("https://example.com/shop?color=red&Size=m")
+	if red != lower || red != "https://example.com/shop?Size=m&color=red" {
+		t.Errorf("IgnoreValueCase keys = %q, %q; want both https://example.com/shop?Size=m&color=red", red, lower)
+	}
+
+	// Only the key is folded; the output keeps the original case
+	key, normalized, err := config.NormalizeWithKey("https://example.com/shop?color=Red")
+	if err != nil {
+		t.Fatalf("NormalizeWithKey() error = %v", err)
+	}
+	if key != "https://example.com/shop?color=red" || normalized != "https://example.com/shop?color=Red" {
+		t.Errorf("NormalizeWithKey() = %q, %q; want a lowercased key and the original output", key, normalized)
+	}
+}
+
+func TestExplain(t *testing.T) {
+	config := normalizer.NewConfig()
+	config.FuzzyMode = true
+	config.SortParams = true
+
+	steps, err := config.Explain("HTTPS://WWW.Example.com/fr/users/123/?b=2&a=1#top")
+	if err != nil {
+		t.Fatalf("Explain() error = %v", err)
+	}
+
+	want := []normalizer.Step{
+		{Stage: normalizer.StageInput, Value: "HTTPS://WWW.Example.com/fr/users/123/?b=2&a=1#top"},
+		{Stage: normalizer.StageLocale, Value: "https://www.example.com/users/123?b=2&a=1#top"},
+		{Stage: normalizer.StageScheme, Value: "https://www.example.com/users/123?b=2&a=1#top"},
+		{Stage: normalizer.StageHost, Value: "https://example.com/users/123?b=2&a=1#top"},
+		{Stage: normalizer.StagePath, Value: "https://example.com/users/123?b=2&a=1"},
+		{Stage: normalizer.StageFuzzy, Value: "https://example.com/users/{id}?b=2&a=1"},
+		{Stage: normalizer.StageQuery, Value: "https://example.com/users/{id}?a=&b="},
+		{Stage: normalizer.StageKey, Value: "https://example.com/users/{id}?a=&b="},
+		{Stage: normalizer.StageOutput, Value: "https://example.com/fr/users/{id}?a=1&b=2"},
+	}
+	if len(steps) != len(want) {
+		t.Fatalf("Explain() returned %d steps, want %d: %v", len(steps), len(want), steps)
+	}
+	for i := range want {
+		if steps[i] != want[i] {
+			t.Errorf("step %d = %+v; want %+v", i, steps[i], want[i])
+		}
+	}
+
+	// The trace stops at the stage that rejects the URL
+	config.IgnoreExtensions = normalizer.ParseSet("png")
+	steps, err = config.Explain("https://example.com/logo.png")
+	if err == nil {
+		t.Fatal("Explain() should fail for an ignored extension")
+	}
+	if last := steps[len(steps)-1].Stage; last != normalizer.StageQuery {
+		t.Errorf("last stage = %q; want %q", last, normalizer.StageQuery)
+	}
+}
+
+func TestCheckRegex(t *testing.T) {
+	for _, expr := range []string{`v[0-9]+`, `(?:[a-z]+-)+[0-9]+`, `[a-f0-9]{32}`, `(?:ab){2,5}`} {
+		if err := normalizer.CheckRegex(expr); err != nil {
+			t.Errorf("CheckRegex(%q) error = %v; want nil", expr, err)
+		}
+	}
+	for _, expr := range []string{`(a+)+`, `(?:.*)*x`, `(\d{2,})+`, `((x{100}){100}){10}`} {
+		if err := normalizer.CheckRegex(expr); err == nil {
+			t.Errorf("CheckRegex(%q) should fail", expr)
+		}
+	}
+
+	if _, err := normalizer.ParseCustomPattern("(?:[a-z]+)+=word"); err == nil {
+		t.Error("ParseCustomPattern() should reject nested quantifiers")
+	}
+}
+
+func TestRegexTimeout(t *testing.T) {
+	config := normalizer.NewConfig()
+	config.KeyRegex = regexp.MustCompile(`(?:[a-z]*[a-z]*[a-z]*){50}\d`)
+	config.RegexTimeout = time.Millisecond
+
+	// No match anywhere, so every start position is tried: slow, not stuck
+	line := "https://example.com/" + strings.Repeat("abc", 20000)
+	start := time.Now()
+	_, _, err := config.NormalizeWithKey(line)
+	if !errors.Is(err, normalizer.ErrRegexTimeout) {
+		t.Fatalf("NormalizeWithKey() error = %v; want ErrRegexTimeout", err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("NormalizeWithKey() returned after %v; want the timeout to cut it short", elapsed)
+	}
+
+	// Fast URLs are unaffected
+	config.RegexTimeout = time.Second
+	key, _, err := config.NormalizeWithKey("https://example.com/about")
+	if err != nil || key != "https://example.com/about" {
+		t.Errorf("NormalizeWithKey() = %q, %v; want the key unchanged", key, err)
+	}
+}
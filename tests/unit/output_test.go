@@ -0,0 +1,265 @@
+package unit
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/lcalzada-xor/dupdurl/pkg/deduplicator"
+	"github.com/lcalzada-xor/dupdurl/pkg/normalizer"
+	"github.com/lcalzada-xor/dupdurl/pkg/output"
+	"github.com/lcalzada-xor/dupdurl/pkg/scope"
+)
+
+// markdownFormatter is a minimal custom formatter used to exercise the registry
+type markdownFormatter struct {
+	printCounts bool
+}
+
+func (f *markdownFormatter) Format(entries []deduplicator.Entry, w io.Writer) error {
+	for _, entry := range entries {
+		if f.printCounts {
+			fmt.Fprintf(w, "- %s (%d)\n", entry.URL, entry.Count)
+		} else {
+			fmt.Fprintf(w, "- %s\n", entry.URL)
+		}
+	}
+	return nil
+}
+
+func TestRegisterFormatter(t *testing.T) {
+	output.RegisterFormatter("markdown", func(printCounts bool) output.Formatter {
+		return &markdownFormatter{printCounts: printCounts}
+	})
+	defer output.RegisterFormatter("markdown", nil)
+
+	formatter, err := output.GetFormatter("markdown", true)
+	if err != nil {
+		t.Fatalf("GetFormatter() error = %v", err)
+	}
+
+	entries := []deduplicator.Entry{{URL: "https://example.com/page1", Count: 2}}
+	var buf bytes.Buffer
+	if err := formatter.Format(entries, &buf); err != nil {
+		t.Fatalf("Format() error = %v", err)
+	}
+
+	want := "- https://example.com/page1 (2)\n"
+	if buf.String() != want {
+		t.Errorf("Format() = %q; want %q", buf.String(), want)
+	}
+
+	// Built-ins remain reachable
+	if _, err := output.GetFormatter("json", false); err != nil {
+		t.Errorf("GetFormatter(json) error = %v", err)
+	}
+
+	// Unregistering removes the custom format
+	output.RegisterFormatter("markdown", nil)
+	if _, err := output.GetFormatter("markdown", false); err == nil {
+		t.Error("GetFormatter(markdown) should fail after unregistering")
+	}
+}
+
+func TestSummarizeDomains(t *testing.T) {
+	entries := []deduplicator.Entry{
+		{URL: "https://api.example.com/users", Count: 3},
+		{URL: "https://example.com/login", Count: 1},
+		{URL: "https://cdn.example.com/app.js", Count: 1},
+		{URL: "https://shop.example.co.uk/cart", Count: 2},
+		{URL: "https://www.example.co.uk/", Count: 1},
+		{URL: "https://other.org/", Count: 5},
+		{URL: "/relative/path", Count: 1},
+	}
+
+	summary := output.SummarizeDomains(entries, func(entry string) string {
+		return normalizer.RegisteredDomain(scope.EntryHost(entry, "url"))
+	})
+
+	want := []output.DomainCount{
+		{Domain: "example.com", URLs: 3},
+		{Domain: "example.co.uk", URLs: 2},
+		{Domain: "other.org", URLs: 1},
+	}
+	if len(summary) != len(want) {
+		t.Fatalf("SummarizeDomains() = %v; want %v", summary, want)
+	}
+	for i := range want {
+		if summary[i] != want[i] {
+			t.Errorf("summary[%d] = %v; want %v", i, summary[i], want[i])
+		}
+	}
+
+	var buf bytes.Buffer
+	if err := output.WriteDomainSummary(summary, &buf); err != nil {
+		t.Fatalf("WriteDomainSummary() error = %v", err)
+	}
+	wantText := "3 example.com\n2 example.co.uk\n1 other.org\n"
+	if buf.String() != wantText {
+		t.Errorf("WriteDomainSummary() = %q; want %q", buf.String(), wantText)
+	}
+}
+
+func TestWriteCountsFile(t *testing.T) {
+	entries := []deduplicator.Entry{
+		{URL: "https://example.com/a", Count: 3},
+		{URL: "https://example.com/b", Count: 1},
+	}
+
+	// Main output stays count-free
+	var stdout bytes.Buffer
+	if err := (&output.TextFormatter{}).Format(entries, &stdout); err != nil {
+		t.Fatalf("Format() error = %v", err)
+	}
+	wantStdout := "https://example.com/a\nhttps://example.com/b\n"
+	if stdout.String() != wantStdout {
+		t.Errorf("stdout = %q; want %q", stdout.String(), wantStdout)
+	}
+
+	path := filepath.Join(t.TempDir(), "counts.csv")
+	if err := output.WriteCountsFile(entries, path); err != nil {
+		t.Fatalf("WriteCountsFile() error = %v", err)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("ReadFile() error = %v", err)
+	}
+	wantFile := "url,count\nhttps://example.com/a,3\nhttps://example.com/b,1\n"
+	if string(data) != wantFile {
+		t.Errorf("counts file = %q; want %q", string(data), wantFile)
+	}
+}
+
+func TestOpenFileAppend(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "urls.txt")
+	runs := [][]deduplicator.Entry{
+		{{URL: "https://example.com/a", Count: 1}},
+		{{URL: "https://example.com/b", Count: 1}},
+	}
+
+	for i, entries := range runs {
+		f, existing, err := output.OpenFile(path, true)
+		if err != nil {
+			t.Fatalf("run %d: OpenFile() error = %v", i, err)
+		}
+		if existing != (i > 0) {
+			t.Errorf("run %d: existing = %v; want %v", i, existing, i > 0)
+		}
+		if err := (&output.TextFormatter{}).Format(entries, f); err != nil {
+			t.Fatalf("run %d: Format() error = %v", i, err)
+		}
+		f.Close()
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("ReadFile() error = %v", err)
+	}
+	want := "https://example.com/a\nhttps://example.com/b\n"
+	if string(data) != want {
+		t.Errorf("appended file = %q; want %q", string(data), want)
+	}
+
+	// Without append the file is truncated
+	f, _, err := output.OpenFile(path, false)
+	if err != nil {
+		t.Fatalf("OpenFile() error = %v", err)
+	}
+	f.Close()
+	if data, _ := os.ReadFile(path); len(data) != 0 {
+		t.Errorf("truncated file = %q; want empty", string(data))
+	}
+
+	if output.LineOriented("json") || !output.LineOriented("text") || !output.LineOriented("csv") {
+		t.Error("LineOriented() should accept text and csv only")
+	}
+}
+
+func TestSortEntries(t *testing.T) {
+	entries := []deduplicator.Entry{
+		{URL: "https://example.com/c", Count: 1},
+		{URL: "https://example.com/a", Count: 3},
+		{URL: "https://example.com/d", Count: 1},
+		{URL: "https://example.com/b", Count: 3},
+	}
+
+	tests := []struct {
+		mode string
+		want []string
+	}{
+		{output.SortCount, []string{"a", "b", "c", "d"}},
+		{output.SortCountAsc, []string{"c", "d", "a", "b"}},
+		{output.SortAlpha, []string{"a", "b", "c", "d"}},
+		{"", []string{"c", "a", "d", "b"}},
+	}
+
+	for _, tt := range tests {
+		sorted := output.SortEntries(entries, tt.mode)
+		for i, suffix := range tt.want {
+			if sorted[i].URL != "https://example.com/"+suffix {
+				t.Errorf("SortEntries(%q)[%d] = %q; want .../%s", tt.mode, i, sorted[i].URL, suffix)
+			}
+		}
+	}
+
+	// The caller's slice keeps first-seen order
+	if entries[0].URL != "https://example.com/c" || entries[1].URL != "https://example.com/a" {
+		t.Errorf("SortEntries() mutated its input: %v", entries)
+	}
+}
+
+func TestCSVColumns(t *testing.T) {
+	entries := []deduplicator.Entry{
+		{URL: "https://api.example.com:8443/v1/app.JS?a=1&b=2", Count: 3},
+		{URL: "example.com/users/{id}", Count: 1},
+	}
+
+	formatter := &output.CSVFormatter{Columns: []string{"host", "path", "scheme", "params", "extension"}}
+	var buf bytes.Buffer
+	if err := formatter.Format(entries, &buf); err != nil {
+		t.Fatalf("Format() error = %v", err)
+	}
+
+	want := "url,count,host,path,scheme,params,extension\n" +
+		"https://api.example.com:8443/v1/app.JS?a=1&b=2,3,api.example.com,/v1/app.JS,https,2,js\n" +
+		"example.com/users/{id},1,example.com,/users/{id},,0,\n"
+	if buf.String() != want {
+		t.Errorf("Format() = %q; want %q", buf.String(), want)
+	}
+}
+
+func TestHTMLFormatter(t *testing.T) {
+	entries := []deduplicator.Entry{
+		{URL: "https://example.com/search?q=a&b=1", Count: 3},
+		{URL: `https://example.com/"><script>alert(1)</script>`, Count: 1},
+	}
+
+	formatter, err := output.GetFormatter("html", false)
+	if err != nil {
+		t.Fatalf("GetFormatter(html) error = %v", err)
+	}
+	var buf bytes.Buffer
+	if err := formatter.Format(entries, &buf); err != nil {
+		t.Fatalf("Format() error = %v", err)
+	}
+	got := buf.String()
+
+	for _, want := range []string{
+		"<!DOCTYPE html>",
+		"2 unique URLs (4 occurrences)",
+		`<td class="url">https://example.com/search?q=a&amp;b=1</td><td class="count">3</td>`,
+		"&lt;script&gt;alert(1)&lt;/script&gt;",
+		`<th data-type="number">Count</th>`,
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("Format() output missing %q", want)
+		}
+	}
+	if strings.Contains(got, "<script>alert(1)") {
+		t.Error("Format() output contains an unescaped URL")
+	}
+}
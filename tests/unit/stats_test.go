@@ -2,6 +2,7 @@ package unit
 
 import (
 	"bytes"
+	"encoding/json"
 	"strings"
 	"testing"
 	"time"
@@ -115,3 +116,91 @@ func TestToJSON(t *testing.T) {
 		t.Errorf("JSON unique_urls = %v; want 20", jsonData["unique_urls"])
 	}
 }
+
+func TestPrintOneLine(t *testing.T) {
+	st := stats.NewStatistics()
+	st.TotalProcessed = 100
+	st.UniqueURLs = 20
+	st.Duplicates = 75
+	st.Filtered = 2
+	st.ParseErrors = 3
+	st.Finish()
+
+	var buf bytes.Buffer
+	st.PrintOneLine(&buf)
+
+	output := buf.String()
+	if strings.Count(output, "\n") != 1 || !strings.HasSuffix(output, "\n") {
+		t.Errorf("PrintOneLine() should write exactly one line, got %q", output)
+	}
+
+	for _, field := range []string{"processed=100", "unique=20", "dupes=75", "filtered=2", "errors=3", "time="} {
+		if !strings.Contains(output, field) {
+			t.Errorf("PrintOneLine() missing %q in %q", field, output)
+		}
+	}
+	if !strings.HasSuffix(strings.TrimSpace(output), "ms") {
+		t.Errorf("PrintOneLine() time should be in ms, got %q", output)
+	}
+}
+
+func TestPrintTemplate(t *testing.T) {
+	st := stats.NewStatistics()
+	st.TotalProcessed = 10
+	st.UniqueURLs = 4
+	st.RecordDomain("a.com")
+	st.RecordDomain("b.com")
+	st.RecordDomain("b.com")
+	st.Finish()
+
+	tmpl, err := stats.ParseTemplate(`{{.UniqueURLs}}/{{.TotalProcessed}} unique{{range top .TopDomains 1}} top={{.Key}}:{{.Value}}{{end}}`)
+	if err != nil {
+		t.Fatalf("ParseTemplate() error = %v", err)
+	}
+
+	var buf bytes.Buffer
+	if err := st.PrintTemplate(&buf, tmpl); err != nil {
+		t.Fatalf("PrintTemplate() error = %v", err)
+	}
+
+	if buf.String() != "4/10 unique top=b.com:2\n" {
+		t.Errorf("PrintTemplate() = %q; want %q", buf.String(), "4/10 unique top=b.com:2\n")
+	}
+
+	if _, err := stats.ParseTemplate("{{.UniqueURLs"); err == nil {
+		t.Error("ParseTemplate() should reject an unterminated action")
+	}
+}
+
+func TestPrintJSON(t *testing.T) {
+	st := stats.NewStatistics()
+	st.TotalProcessed = 10
+	st.UniqueURLs = 4
+	st.RecordDomain("a.com")
+	st.RecordDomain("b.com")
+	st.RecordDomain("b.com")
+	st.Finish()
+
+	var buf bytes.Buffer
+	if err := st.PrintJSON(&buf); err != nil {
+		t.Fatalf("PrintJSON() error = %v", err)
+	}
+
+	var got struct {
+		TotalProcessed int `json:"total_processed"`
+		UniqueURLs     int `json:"unique_urls"`
+		TopDomains     []struct {
+			Key   string `json:"key"`
+			Value int    `json:"value"`
+		} `json:"top_domains"`
+	}
+	if err := json.Unmarshal(buf.Bytes(), &got); err != nil {
+		t.Fatalf("PrintJSON() wrote invalid JSON %q: %v", buf.String(), err)
+	}
+	if got.TotalProcessed != 10 || got.UniqueURLs != 4 {
+		t.Errorf("PrintJSON() counters = %+v", got)
+	}
+	if len(got.TopDomains) != 2 || got.TopDomains[0].Key != "b.com" || got.TopDomains[0].Value != 2 {
+		t.Errorf("PrintJSON() top_domains = %+v; want b.com:2 first", got.TopDomains)
+	}
+}
@@ -0,0 +1,243 @@
+package unit
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"runtime"
+	"sync"
+	"testing"
+
+	"github.com/lcalzada-xor/dupdurl/pkg/storage"
+)
+
+func TestSQLiteConcurrentWriters(t *testing.T) {
+	backend, err := storage.NewSQLiteBackend(filepath.Join(t.TempDir(), "urls.db"))
+	if err != nil {
+		t.Fatalf("NewSQLiteBackend() error = %v", err)
+	}
+	defer backend.Close()
+
+	const writers = 8
+	const perWriter = 50
+
+	var wg sync.WaitGroup
+	errs := make(chan error, writers*2)
+	for w := 0; w < writers; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+
+			// Half the writes go through Add, half through AddBatch,
+			// all contending on the same shared keys
+			var batch []storage.Record
+			for i := 0; i < perWriter; i++ {
+				key := fmt.Sprintf("key%d", i)
+				url := fmt.Sprintf("https://example.com/%d", i)
+				if i%2 == 0 {
+					if err := backend.Add(key, url); err != nil {
+						errs <- err
+						return
+					}
+				} else {
+					batch = append(batch, storage.Record{Key: key, URL: url})
+				}
+			}
+			if err := backend.AddBatch(batch); err != nil {
+				errs <- err
+			}
+		}(w)
+	}
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		t.Errorf("concurrent write error = %v", err)
+	}
+
+	if backend.Count() != perWriter {
+		t.Errorf("Count() = %d; want %d", backend.Count(), perWriter)
+	}
+
+	entries, err := backend.GetEntries()
+	if err != nil {
+		t.Fatalf("GetEntries() error = %v", err)
+	}
+	for _, entry := range entries {
+		if entry.Count != writers {
+			t.Errorf("%s count = %d; want %d", entry.URL, entry.Count, writers)
+		}
+	}
+}
+
+func TestStorageAddBatch(t *testing.T) {
+	backends := map[string]func() (storage.Backend, error){
+		"memory": func() (storage.Backend, error) { return storage.NewMemoryBackend(), nil },
+		"sqlite": func() (storage.Backend, error) { return storage.NewSQLiteBackend(":memory:") },
+	}
+
+	for name, open := range backends {
+		t.Run(name, func(t *testing.T) {
+			backend, err := open()
+			if err != nil {
+				t.Fatalf("open error = %v", err)
+			}
+			defer backend.Close()
+
+			if err := backend.Add("a", "https://example.com/a"); err != nil {
+				t.Fatalf("Add() error = %v", err)
+			}
+			err = backend.AddBatch([]storage.Record{
+				{Key: "a", URL: "https://example.com/a?x=1"},
+				{Key: "b", URL: "https://example.com/b", Count: 3},
+			})
+			if err != nil {
+				t.Fatalf("AddBatch() error = %v", err)
+			}
+
+			entries, err := backend.GetEntries()
+			if err != nil {
+				t.Fatalf("GetEntries() error = %v", err)
+			}
+			counts := make(map[string]int)
+			for _, entry := range entries {
+				counts[entry.URL] = entry.Count
+			}
+			if counts["https://example.com/a"] != 2 || counts["https://example.com/b"] != 3 || len(counts) != 2 {
+				t.Errorf("entries = %v; want a=2 b=3", counts)
+			}
+		})
+	}
+}
+
+func TestSQLitePragmaOptions(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "tuned.db")
+	backend, err := storage.NewSQLiteBackendWithOptions(path, storage.SQLiteOptions{
+		JournalMode: "delete",
+		Synchronous: "off",
+		CacheSize:   -4000,
+	})
+	if err != nil {
+		t.Fatalf("NewSQLiteBackendWithOptions() error = %v", err)
+	}
+	defer backend.Close()
+
+	want := map[string]string{
+		"journal_mode": "delete",
+		"synchronous":  "0",
+		"cache_size":   "-4000",
+	}
+	for name, expected := range want {
+		value, err := backend.Pragma(name)
+		if err != nil {
+			t.Fatalf("Pragma(%s) error = %v", name, err)
+		}
+		if value != expected {
+			t.Errorf("Pragma(%s) = %q; want %q", name, value, expected)
+		}
+	}
+
+	// Per-connection pragmas hold on every pooled connection, not just the
+	// first; concurrent queries make the pool open more, even on one CPU
+	defer runtime.GOMAXPROCS(runtime.GOMAXPROCS(8))
+	var wg sync.WaitGroup
+	start := make(chan struct{})
+	errs := make(chan string, 1)
+	for i := 0; i < 32; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			<-start
+			for j := 0; j < 20; j++ {
+				for name, expected := range map[string]string{"busy_timeout": "5000", "cache_size": "-4000"} {
+					if value, _ := backend.Pragma(name); value != expected {
+						select {
+						case errs <- name + " = " + value + "; want " + expected:
+						default:
+						}
+					}
+				}
+			}
+		}()
+	}
+	close(start)
+	wg.Wait()
+	close(errs)
+	for msg := range errs {
+		t.Errorf("pooled connection: %s", msg)
+		break
+	}
+
+	// Defaults keep WAL
+	defaults, err := storage.NewSQLiteBackend(filepath.Join(t.TempDir(), "default.db"))
+	if err != nil {
+		t.Fatalf("NewSQLiteBackend() error = %v", err)
+	}
+	defer defaults.Close()
+	if mode, _ := defaults.Pragma("journal_mode"); mode != "wal" {
+		t.Errorf("default journal_mode = %q; want wal", mode)
+	}
+
+	// Invalid values are rejected before touching the database
+	if _, err := storage.NewSQLiteBackendWithOptions(path, storage.SQLiteOptions{JournalMode: "wal; DROP TABLE urls"}); err == nil {
+		t.Error("invalid journal mode should be rejected")
+	}
+}
+
+func TestSQLiteFirstSeenOrder(t *testing.T) {
+	backend, err := storage.NewSQLiteBackend(":memory:")
+	if err != nil {
+		t.Fatalf("NewSQLiteBackend() error = %v", err)
+	}
+	defer backend.Close()
+
+	// Insert in reverse key order within the same second, with repeats
+	const n = 500
+	for i := n - 1; i >= 0; i-- {
+		key := fmt.Sprintf("key-%03d", i)
+		if err := backend.Add(key, "https://example.com/"+key); err != nil {
+			t.Fatalf("Add() error = %v", err)
+		}
+		if i%7 == 0 {
+			// Re-adding an older key must not move it
+			if err := backend.Add(fmt.Sprintf("key-%03d", n-1), "dup"); err != nil {
+				t.Fatalf("Add() error = %v", err)
+			}
+		}
+	}
+
+	entries, err := backend.GetEntries()
+	if err != nil {
+		t.Fatalf("GetEntries() error = %v", err)
+	}
+	if len(entries) != n {
+		t.Fatalf("GetEntries() length = %d; want %d", len(entries), n)
+	}
+	for i, entry := range entries {
+		want := fmt.Sprintf("https://example.com/key-%03d", n-1-i)
+		if entry.URL != want {
+			t.Fatalf("entry %d = %q; want %q", i, entry.URL, want)
+		}
+	}
+}
+
+func TestSQLiteUnavailable(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "urls.db")
+	backend, err := storage.NewSQLiteBackend(path)
+
+	if storage.SQLiteAvailable() {
+		if err != nil {
+			t.Fatalf("NewSQLiteBackend() error = %v", err)
+		}
+		backend.Close()
+		return
+	}
+
+	// Builds without cgo must fail with the friendly error, not a driver panic
+	if !errors.Is(err, storage.ErrSQLiteUnavailable) {
+		t.Errorf("NewSQLiteBackend() error = %v; want ErrSQLiteUnavailable", err)
+	}
+	if err := storage.ExportSQLite(nil, path, storage.DefaultSQLiteOptions()); !errors.Is(err, storage.ErrSQLiteUnavailable) {
+		t.Errorf("ExportSQLite() error = %v; want ErrSQLiteUnavailable", err)
+	}
+}